@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	blocklistCmd = &cobra.Command{
+		Use:   "blocklist",
+		Short: "Manage pages dropped with --skip-credits",
+		Long: "Manage the blocklist of pages, e.g. scanlator credit and recruitment pages.\n" +
+			"Downloads with --skip-credits drop pages similar to the listed ones.",
+	}
+	blocklistAddCmd = &cobra.Command{
+		Use:   "add <image files...>",
+		Short: "Add page images to the blocklist",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   addToBlocklist,
+	}
+	blocklistListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Print the blocklist",
+		Run:   listBlocklist,
+	}
+	blocklistNote string
+)
+
+func init() {
+	rootCmd.AddCommand(blocklistCmd)
+	blocklistCmd.AddCommand(blocklistAddCmd, blocklistListCmd)
+
+	blocklistAddCmd.Flags().StringVar(&blocklistNote,
+		"note", "", "note saved with the pages, e.g. the group name, file names by default")
+}
+
+func addToBlocklist(cmd *cobra.Command, args []string) {
+	mdx.AddToBlocklist(args, blocklistNote)
+}
+
+func listBlocklist(cmd *cobra.Command, args []string) {
+	mdx.ListBlocklist()
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/arimatakao/mdx/mangadexapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	isCheckJson bool
+
+	checkCmd = &cobra.Command{
+		Use:   "check <url>",
+		Short: "Report gaps, external and scheduled chapters of manga",
+		Long: "Print chapters and volumes of manga in a language. Gaps in chapter numbering,\n" +
+			"chapters translated only to other languages, chapters hosted on external sites\n" +
+			"(MangaPlus and similar) and chapters published in the future are reported.",
+		Args: cobra.ExactArgs(1),
+		Run:  checkManga,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().StringVarP(&language, "language", "l", "en", "specify language")
+	// --json is kept for scripts written before --output-format
+	checkCmd.Flags().BoolVar(&isCheckJson, "json", false, "print the report as JSON")
+	checkCmd.Flags().MarkDeprecated("json", "use --output-format json")
+}
+
+func checkManga(cmd *cobra.Command, args []string) {
+	mangaId := mangadexapi.GetMangaIdFromArgs(args)
+	if mangaId == "" {
+		e.Println("Malformatted URL.")
+		os.Exit(0)
+	}
+	if isCheckJson {
+		mdx.SetOutputFormat(mdx.OUTPUT_FORMAT_JSON)
+	}
+	mdx.NewCheckParam(mangaId, language).RunCheck()
+}
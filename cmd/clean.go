@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanCmd = &cobra.Command{
+		Use:   "clean [output directories...]",
+		Short: "Remove temporary files left by interrupted downloads",
+		Long: "Remove temporary page directories (mdxepubfiles*, mdxdirfiles*) from the system temporary directory.\n" +
+			"If output directories are given, unfinished .mdx-*.tmp files inside them are removed too.\n" +
+			"Files modified in the last hour are kept by default, they may belong to a running download.",
+		Run: clean,
+	}
+	cleanOlderThan time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+
+	cleanCmd.Flags().DurationVar(&cleanOlderThan,
+		"older-than", time.Hour, "remove only files not modified for this duration, 0 removes all of them")
+}
+
+func clean(cmd *cobra.Command, args []string) {
+	mdx.CleanTemp(args, cleanOlderThan)
+}
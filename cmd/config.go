@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/arimatakao/mdx/internal/config"
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage default values of flags",
+		Long: "Manage the config file with default values of flags, it is kept in\n" +
+			mdx.ConfigPath() + ".\n\n" +
+			"Keys are flag names: \"language\" applies to every command with the flag,\n" +
+			"\"download.ext\" to the download command only and \"profiles.kobo.ext\" to every\n" +
+			"command run with --profile kobo. Flags can be set with MDX_* environment variables\n" +
+			"too, e.g. MDX_FILE_NAME for --file-name.\n\n" +
+			"Values are taken from the command line, then environment variables, then the profile,\n" +
+			"then the command table and then keys of all commands. config set rewrites the file\n" +
+			"without its comments.",
+	}
+	configGetCmd = &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the value of a key",
+		Args:  cobra.ExactArgs(1),
+		Run:   getConfig,
+	}
+	configSetCmd = &cobra.Command{
+		Use:   "set <key> <values...>",
+		Short: "Set the value of a key, several values are saved as a list",
+		Args:  cobra.MinimumNArgs(2),
+		Run:   setConfig,
+	}
+	configListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Print keys of the config file",
+		Run:   listConfig,
+	}
+	configPathCmd = &cobra.Command{
+		Use:   "path",
+		Short: "Print the path of the config file",
+		Run:   printConfigPath,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configListCmd, configPathCmd)
+}
+
+func getConfig(cmd *cobra.Command, args []string) {
+	mdx.GetConfig(args[0])
+}
+
+func setConfig(cmd *cobra.Command, args []string) {
+	mdx.SetConfig(checkConfigKey(args[0], args[1:]), args[1:])
+}
+
+func listConfig(cmd *cobra.Command, args []string) {
+	mdx.ListConfig()
+}
+
+func printConfigPath(cmd *cobra.Command, args []string) {
+	mdx.PrintConfigPath()
+}
+
+// checkConfigKey exits if key is not a flag of any command or values are not
+// valid values of the flag. It returns the key with the full command name
+// instead of an alias, e.g. "download.ext" for "dl.ext".
+func checkConfigKey(key string, values []string) string {
+	table, name := config.SplitKey(key)
+	flags := []*pflag.Flag{}
+	switch {
+	case table == "" && name == config.PROFILE_FLAG:
+		return key
+	case table == "" || strings.HasPrefix(table, config.PROFILE_TABLE+"."):
+		flags = findFlags(rootCmd, name)
+	default:
+		c, rest, err := rootCmd.Find(strings.Split(table, "."))
+		if err != nil || len(rest) != 0 || c == rootCmd {
+			e.Printfln("%s is not a command", strings.ReplaceAll(table, ".", " "))
+			os.Exit(0)
+		}
+		key = commandTable(c) + "." + name
+		flags = findFlags(c, name)
+		if len(flags) > 1 {
+			flags = flags[:1]
+		}
+	}
+	if len(flags) == 0 || name == "help" || name == config.PROFILE_FLAG {
+		if table == "" || strings.HasPrefix(table, config.PROFILE_TABLE+".") {
+			e.Printfln("%s is not a flag of any command", name)
+		} else {
+			e.Printfln("%s is not a flag of %s", name, strings.ReplaceAll(table, ".", " "))
+		}
+		os.Exit(0)
+	}
+
+	// keys of all commands are valid if any command accepts the values
+	var err error
+	for _, f := range flags {
+		if err = setFlagValues(f, values); err == nil {
+			return key
+		}
+	}
+	e.Printfln("%s is not a valid value of --%s: %v", strings.Join(values, " "), name, err)
+	os.Exit(0)
+	return key
+}
+
+func setFlagValues(f *pflag.Flag, values []string) error {
+	if _, isSlice := f.Value.(pflag.SliceValue); len(values) > 1 && !isSlice {
+		return errors.New("the flag takes one value")
+	}
+	for _, value := range values {
+		if err := f.Value.Set(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findFlags returns flags named name of c and its subcommands.
+func findFlags(c *cobra.Command, name string) []*pflag.Flag {
+	flags := []*pflag.Flag{}
+	if f := c.Flags().Lookup(name); f != nil {
+		flags = append(flags, f)
+	} else if f := c.InheritedFlags().Lookup(name); f != nil {
+		flags = append(flags, f)
+	}
+	for _, sub := range c.Commands() {
+		flags = append(flags, findFlags(sub, name)...)
+	}
+	return flags
+}
+
+// commandTable returns the config table of c, e.g. "library.missing".
+func commandTable(c *cobra.Command) string {
+	path := strings.TrimPrefix(c.CommandPath(), c.Root().Name()+" ")
+	return strings.ReplaceAll(path, " ", ".")
+}
+
+// applyConfig sets flags of cmd which are not set on the command line from
+// MDX_* environment variables and the config file, see config.Apply.
+func applyConfig(cmd *cobra.Command) {
+	if !cmd.HasParent() || cmd == configCmd || cmd.Parent() == configCmd {
+		return
+	}
+	if err := mdx.LoadConfig().Apply(cmd.Flags(), commandTable(cmd)); err != nil {
+		e.Printfln("While reading config file %s: %v", mdx.ConfigPath(), err)
+		os.Exit(1)
+	}
+}
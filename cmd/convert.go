@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/arimatakao/mdx/downloader"
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	convertCmd = &cobra.Command{
+		Use:   "convert <files...>",
+		Short: "Convert downloaded files into another format",
+		Long: "Convert downloaded cbz, zip, cbt, epub files or page directories into another format.\n" +
+			"Pages are read in natural order with ComicInfo.xml and the ComicBookInfo comment,\n" +
+			"chapter folders of merged files are kept. MangaDex is not used.",
+		Args:   cobra.MinimumNArgs(1),
+		PreRun: checkConvertArgs,
+		Run:    convert,
+	}
+	convertExt       string
+	convertOutputDir string
+)
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringVarP(&convertExt,
+		"ext", "e", filekit.CBZ_EXT, "choose output file format: pdf cbz epub dir cbt zip kindle html")
+	convertCmd.Flags().StringVarP(&convertOutputDir,
+		"output", "o", "", "specify output directory for files, the directory of each input file by default")
+	addOutputFlags(convertCmd)
+	addWebtoonFlag(convertCmd, &offlineWebtoonMode, imaging.WEBTOON_OFF)
+}
+
+func checkConvertArgs(cmd *cobra.Command, args []string) {
+	checkOutputArgs()
+
+	if filekit.IsNotSupported(convertExt) {
+		e.Printfln("%s format of file is not supported", convertExt)
+		os.Exit(0)
+	}
+}
+
+func convert(cmd *cobra.Command, args []string) {
+	params := mdx.NewOfflineParam(convertExt, convertOutputDir,
+		containerOptions(), imageOptions(), offlineWebtoonMode, downloader.Naming{})
+	params.RunConvert(args)
+}
@@ -5,7 +5,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/arimatakao/mdx/downloader"
 	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
 	"github.com/arimatakao/mdx/internal/mdx"
 	"github.com/arimatakao/mdx/mangadexapi"
 	"github.com/pterm/pterm"
@@ -32,11 +34,13 @@ var (
 	highestVolume     int
 	isMergeChapters   bool
 	outputExt         string
-	fileNameTemplate  string
 	isLastChapter     bool
 	isAllChapters     bool
 	isVolume          bool
 	isInteractiveMode bool
+	isUrlShortcut     bool
+	isDryRun          bool
+	isPlanJson        bool
 )
 
 func init() {
@@ -47,11 +51,12 @@ func init() {
 	downloadCmd.Flags().StringVarP(&mangaChapterUrl,
 		"this", "s", "", "specify the direct URL to a specific chapter")
 	downloadCmd.Flags().StringVarP(&outputExt,
-		"ext", "e", "pdf", "choose output file format: pdf cbz epub dir")
+		"ext", "e", "pdf", "choose output file format: pdf cbz epub dir cbt zip kindle html")
 	downloadCmd.Flags().StringVarP(&outputDir,
 		"output", "o", ".", "specify output directory for file")
-	downloadCmd.Flags().StringVar(&fileNameTemplate,
-		"file-name", "", "specify output file name template: %1 language, %2 translator, %3 manga title, %4 volume, %5 chapter/range, %6 chapter title")
+	addNamingFlags(downloadCmd)
+	addOutputFlags(downloadCmd)
+	addWebtoonFlag(downloadCmd, &webtoonMode, imaging.WEBTOON_AUTO)
 	downloadCmd.Flags().StringVarP(&language,
 		"language", "l", "en", "specify language")
 	downloadCmd.Flags().StringVarP(&translateGroup,
@@ -70,10 +75,20 @@ func init() {
 		"last", "", false, "download last chapter")
 	downloadCmd.Flags().BoolVarP(&isInteractiveMode,
 		"interactive", "i", false, "interactive download mode")
+	downloadCmd.Flags().BoolVar(&isUrlShortcut,
+		"url-shortcut", false, "save a .url shortcut to the official source of chapters hosted on external sites")
+	downloadCmd.Flags().BoolVar(&isDryRun,
+		"dry-run", false, "print files, chapters, pages and the estimated size without downloading")
+	downloadCmd.Flags().BoolVar(&isPlanJson,
+		"plan-json", false, "print the download plan as JSON without downloading")
 }
 
 func checkDownloadArgs(cmd *cobra.Command, args []string) {
 	urlErrorMessage := "Malformatted URL."
+
+	checkNamingArgs()
+	checkOutputArgs()
+
 	if isInteractiveMode {
 		return
 	}
@@ -170,14 +185,34 @@ func parseRange(rangeStr string) (low, high int) {
 }
 
 func downloadManga(cmd *cobra.Command, args []string) {
-	params := mdx.NewDownloadParam(
-		chaptersRange, volumesRange, lowestChapter, highestChapter, lowestVolume, highestVolume,
-		language, translateGroup, outputDir, outputExt, fileNameTemplate,
-		isJpgFileFormat, isMergeChapters, isVolume, isAllChapters, isLastChapter)
+	params := mdx.NewDownloadParam(downloader.Request{
+		MangaID:          mangaId,
+		ChapterID:        mangaChapterId,
+		Language:         language,
+		TranslationGroup: translateGroup,
+		LowestChapter:    lowestChapter,
+		HighestChapter:   highestChapter,
+		LowestVolume:     lowestVolume,
+		HighestVolume:    highestVolume,
+		IsVolume:         isVolume,
+		IsAll:            isAllChapters,
+		IsLast:           isLastChapter,
+		ChaptersRange:    chaptersRange,
+		OutputDir:        outputDir,
+		OutputExt:        outputExt,
+		FileNameTemplate: naming().FileNameTemplate,
+		Layout:           naming().Layout,
+		ContainerOptions: containerOptions(),
+		ImageOptions:     imageOptions(),
+		WebtoonMode:      webtoonMode,
+		IsJpg:            isJpgFileFormat,
+		IsMerge:          isMergeChapters,
+		IsUrlShortcut:    isUrlShortcut,
+	}, isDryRun, isPlanJson)
 
 	if isInteractiveMode {
 		params.RunInteractiveDownload()
 	} else {
-		params.RunDownload(mangaId, mangaChapterId)
+		params.RunDownload()
 	}
 }
@@ -9,7 +9,8 @@ import (
 )
 
 var (
-	isRandomInfo bool
+	isRandomInfo   bool
+	isChaptersInfo bool
 
 	infoCmd = &cobra.Command{
 		Use:    "info",
@@ -24,6 +25,9 @@ func init() {
 
 	infoCmd.Flags().StringVarP(&mangaUrl, "url", "u", "", "specify the URL for the manga")
 	infoCmd.Flags().BoolVarP(&isRandomInfo, "random", "r", false, "get information about a random manga")
+	infoCmd.Flags().BoolVarP(&isChaptersInfo, "chapters", "c", false,
+		"print chapters and volumes, gaps in numbering, external and scheduled chapters")
+	infoCmd.Flags().StringVarP(&language, "language", "l", "en", "specify language of chapters")
 }
 
 func checkInfoArgs(cmd *cobra.Command, args []string) {
@@ -49,5 +53,5 @@ func checkInfoArgs(cmd *cobra.Command, args []string) {
 }
 
 func getInfo(cmd *cobra.Command, args []string) {
-	mdx.NewInfoParams(mangaId, isRandomInfo).GetInfo()
+	mdx.NewInfoParams(mangaId, isRandomInfo, isChaptersInfo, language).GetInfo()
 }
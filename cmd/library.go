@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/arimatakao/mdx/mangadexapi"
+	"github.com/spf13/cobra"
+)
+
+var (
+	libraryCmd = &cobra.Command{
+		Use:   "library",
+		Short: "Index downloaded files and compare them with MangaDex",
+		Long: "Index downloaded files by metadata stored in them: ComicInfo.xml, the ComicBookInfo\n" +
+			"comment, the epub package document and the pdf document information.\n" +
+			"The index is kept in " + mdx.LibraryPath() + ".",
+	}
+	libraryScanCmd = &cobra.Command{
+		Use:   "scan <roots...>",
+		Short: "Index cbz, cbt, zip, epub, pdf files and page directories under roots",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   scanLibrary,
+	}
+	libraryListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Print indexed series with their volumes, chapters and groups",
+		Run:   listLibrary,
+	}
+	libraryMissingCmd = &cobra.Command{
+		Use:   "missing <url>",
+		Short: "Print chapters on MangaDex that are not in the library",
+		Args:  cobra.ExactArgs(1),
+		Run:   missingInLibrary,
+	}
+	libraryDuplicatesCmd = &cobra.Command{
+		Use:   "duplicates",
+		Short: "Print chapters stored in more than one file",
+		Run:   libraryDuplicates,
+	}
+	libraryLanguage string
+)
+
+func init() {
+	rootCmd.AddCommand(libraryCmd)
+	libraryCmd.AddCommand(libraryScanCmd, libraryListCmd, libraryMissingCmd, libraryDuplicatesCmd)
+
+	libraryMissingCmd.Flags().StringVarP(&libraryLanguage,
+		"language", "l", "en", "specify language")
+}
+
+func scanLibrary(cmd *cobra.Command, args []string) {
+	mdx.RunLibraryScan(args)
+}
+
+func listLibrary(cmd *cobra.Command, args []string) {
+	mdx.RunLibraryList()
+}
+
+func missingInLibrary(cmd *cobra.Command, args []string) {
+	mangaId := mangadexapi.GetMangaIdFromArgs(args)
+	if mangaId == "" {
+		e.Println("Malformatted URL.")
+		os.Exit(0)
+	}
+	mdx.RunLibraryMissing(mangaId, libraryLanguage)
+}
+
+func libraryDuplicates(cmd *cobra.Command, args []string) {
+	mdx.RunLibraryDuplicates()
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeCmd = &cobra.Command{
+		Use:   "merge <files...> -o <output file> | --by-volume <directory>",
+		Short: "Merge downloaded chapter files into one file",
+		Long: "Merge downloaded chapter files into one file in the given order, chapters are marked in it.\n" +
+			"With --by-volume, chapter files of the directory are merged into a file per volume\n" +
+			"using the volume and chapter numbers of their metadata. MangaDex is not used.",
+		PreRun: checkMergeArgs,
+		Run:    merge,
+	}
+	mergeExt       string
+	mergeOutput    string
+	mergeVolumeDir string
+)
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().StringVarP(&mergeExt,
+		"ext", "e", "", "choose output file format: pdf cbz epub dir cbt zip kindle html, by default the extension of the output file or cbz")
+	mergeCmd.Flags().StringVarP(&mergeOutput,
+		"output", "o", "", "specify output file, or output directory with --by-volume")
+	mergeCmd.Flags().StringVar(&mergeVolumeDir,
+		"by-volume", "", "merge chapter files of the directory into a file per volume, named by --file-name and --layout")
+	addNamingFlags(mergeCmd)
+	addOutputFlags(mergeCmd)
+	addWebtoonFlag(mergeCmd, &offlineWebtoonMode, imaging.WEBTOON_OFF)
+}
+
+func checkMergeArgs(cmd *cobra.Command, args []string) {
+	checkNamingArgs()
+	checkOutputArgs()
+
+	if mergeVolumeDir == "" && (len(args) == 0 || mergeOutput == "") {
+		cmd.Help()
+		os.Exit(0)
+	}
+
+	if mergeExt == "" {
+		mergeExt = strings.TrimPrefix(filepath.Ext(mergeOutput), ".")
+		if mergeVolumeDir != "" || filekit.IsNotSupported(mergeExt) {
+			mergeExt = filekit.CBZ_EXT
+		}
+	}
+
+	if filekit.IsNotSupported(mergeExt) {
+		e.Printfln("%s format of file is not supported", mergeExt)
+		os.Exit(0)
+	}
+}
+
+func merge(cmd *cobra.Command, args []string) {
+	outputDir := ""
+	if mergeVolumeDir != "" {
+		outputDir = mergeOutput
+	}
+	params := mdx.NewOfflineParam(mergeExt, outputDir,
+		containerOptions(), imageOptions(), offlineWebtoonMode, naming())
+
+	if mergeVolumeDir != "" {
+		params.RunMergeByVolume(mergeVolumeDir)
+	} else {
+		params.RunMerge(args, mergeOutput)
+	}
+}
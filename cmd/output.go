@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/arimatakao/mdx/downloader"
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	onExists     string
+	epubLayout   string
+	pdfPageSize  string
+	pdfScale     string
+	pdfMargin    float64
+	pdfCompress  string
+	isNoMedia    bool
+	isHtmlAssets bool
+	device       string
+	isGrayscale  bool
+	gamma        float64
+	contrast     float64
+	isAutoCrop   bool
+	jpegQuality  int
+	spread       string
+	webtoonMode  string
+	// offlineWebtoonMode is --webtoon of commands reading downloaded files
+	offlineWebtoonMode string
+	webtoonHeight      int
+	isSkipCredits      bool
+
+	fileNameTemplate string
+	layoutTemplate   string
+	outputLayout     downloader.Layout
+)
+
+// addNamingFlags adds flags naming output files and their directories.
+func addNamingFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&fileNameTemplate,
+		"file-name", "", "specify output file name template: %1 language, %2 translator, %3 manga title, %4 volume, %5 chapter/range, %6 chapter title")
+	cmd.Flags().StringVar(&layoutTemplate,
+		"layout", "", downloader.LayoutHelp())
+}
+
+// checkNamingArgs exits on a malformatted layout.
+func checkNamingArgs() {
+	layout, err := downloader.ParseLayout(layoutTemplate)
+	if err != nil {
+		e.Printfln("Malformatted layout %q: %v", layoutTemplate, err)
+		os.Exit(0)
+	}
+	outputLayout = layout
+}
+
+// naming collects naming flags.
+func naming() downloader.Naming {
+	return downloader.Naming{Layout: outputLayout, FileNameTemplate: fileNameTemplate}
+}
+
+// addOutputFlags adds flags of output files and page processing shared by
+// commands writing files.
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&onExists,
+		"on-exists", filekit.ON_EXISTS_RENAME, "what to do when output file already exists: rename skip overwrite fail")
+	cmd.Flags().StringVar(&epubLayout,
+		"epub-layout", filekit.EPUB_LAYOUT_FIXED, "page layout of epub files: fixed reflow")
+	cmd.Flags().StringVar(&pdfPageSize,
+		"pdf-page-size", filekit.PDF_PAGE_NATIVE, "page size of pdf files: native a4 a5 letter or a device profile: "+strings.Join(filekit.DeviceNames(), " "))
+	cmd.Flags().StringVar(&pdfScale,
+		"pdf-scale", filekit.PDF_SCALE_FIT, "how images are scaled to pdf pages: fit fill")
+	cmd.Flags().Float64Var(&pdfMargin,
+		"pdf-margin", 0, "margin of pdf pages in points")
+	cmd.Flags().StringVar(&pdfCompress,
+		"pdf-compression", filekit.PDF_COMPRESSION_DEFAULT, "compression of pdf page contents: default none fast best")
+	cmd.Flags().BoolVar(&isNoMedia,
+		"nomedia", false, "add .nomedia file to zip archives to hide pages from Android galleries")
+	cmd.Flags().BoolVar(&isHtmlAssets,
+		"html-assets", false, "save pages of html files into a folder next to the file instead of embedding them")
+	cmd.Flags().StringVar(&device,
+		"device", "", "fit pages to the screen of a device: "+strings.Join(filekit.DeviceNames(), " "))
+	cmd.Flags().BoolVar(&isGrayscale,
+		"grayscale", false, "convert pages to grayscale")
+	cmd.Flags().Float64Var(&gamma,
+		"gamma", 1, "gamma correction of pages, values above 1 darken midtones")
+	cmd.Flags().Float64Var(&contrast,
+		"contrast", 1, "contrast of pages, values above 1 increase contrast")
+	cmd.Flags().BoolVar(&isAutoCrop,
+		"autocrop", false, "remove white and black borders of pages")
+	cmd.Flags().IntVar(&jpegQuality,
+		"jpeg-quality", 0, "quality from 1 to 100 of processed pages, pages are processed when any image option is set")
+	cmd.Flags().StringVar(&spread,
+		"spread", imaging.SPREAD_KEEP, "what to do with double-page spreads: keep split rotate")
+	cmd.Flags().IntVar(&webtoonHeight,
+		"webtoon-height", 0, "height in pixels of webtoon pages, by default it follows the device screen ratio")
+	cmd.Flags().BoolVar(&isSkipCredits,
+		"skip-credits", false, "drop pages similar to the ones in the blocklist, see mdx blocklist")
+}
+
+// addWebtoonFlag adds --webtoon with its default for cmd. Downloads default
+// to auto, commands reading downloaded files default to off, as their pages
+// were sliced already and would be encoded again.
+func addWebtoonFlag(cmd *cobra.Command, mode *string, defaultMode string) {
+	cmd.Flags().StringVar(mode,
+		"webtoon", defaultMode, "stitch pages of a chapter and cut them again at gutters: auto on off, auto is on for Long Strip manga")
+}
+
+// checkOutputArgs exits on malformatted output flags.
+func checkOutputArgs() {
+	if filekit.IsNotSupportedPolicy(onExists) {
+		e.Printfln("%s policy for existing files is not supported", onExists)
+		os.Exit(0)
+	}
+
+	if filekit.IsNotSupportedEpubLayout(epubLayout) {
+		e.Printfln("%s epub layout is not supported", epubLayout)
+		os.Exit(0)
+	}
+
+	if filekit.IsNotSupportedPdfPageSize(pdfPageSize) {
+		e.Printfln("%s pdf page size is not supported", pdfPageSize)
+		os.Exit(0)
+	}
+
+	if filekit.IsNotSupportedPdfScale(pdfScale) {
+		e.Printfln("%s pdf scale is not supported", pdfScale)
+		os.Exit(0)
+	}
+
+	if filekit.IsNotSupportedPdfCompression(pdfCompress) {
+		e.Printfln("%s pdf compression is not supported", pdfCompress)
+		os.Exit(0)
+	}
+
+	if pdfMargin < 0 {
+		e.Printfln("Malformatted pdf margin %v", pdfMargin)
+		os.Exit(0)
+	}
+
+	if _, ok := filekit.DeviceProfile(device); device != "" && !ok {
+		e.Printfln("%s device is not supported", device)
+		os.Exit(0)
+	}
+
+	if gamma <= 0 || contrast <= 0 {
+		e.Printfln("Malformatted gamma %v or contrast %v", gamma, contrast)
+		os.Exit(0)
+	}
+
+	if jpegQuality < 0 || jpegQuality > 100 {
+		e.Printfln("Malformatted jpeg quality %d", jpegQuality)
+		os.Exit(0)
+	}
+
+	if imaging.IsNotSupportedSpread(spread) {
+		e.Printfln("%s spread mode is not supported", spread)
+		os.Exit(0)
+	}
+
+	for _, mode := range []string{webtoonMode, offlineWebtoonMode} {
+		if imaging.IsNotSupportedWebtoonMode(mode) {
+			e.Printfln("%s webtoon mode is not supported", mode)
+			os.Exit(0)
+		}
+	}
+
+	if webtoonHeight < 0 {
+		e.Printfln("Malformatted webtoon height %d", webtoonHeight)
+		os.Exit(0)
+	}
+}
+
+// containerOptions collects output flags for filekit containers.
+func containerOptions() filekit.Options {
+	return filekit.Options{
+		OnExists:       onExists,
+		EpubLayout:     epubLayout,
+		PdfPageSize:    pdfPageSize,
+		PdfScale:       pdfScale,
+		PdfMargin:      pdfMargin,
+		PdfCompression: pdfCompress,
+		NoMedia:        isNoMedia,
+		HtmlAssets:     isHtmlAssets,
+	}
+}
+
+// imageOptions collects image processing flags.
+func imageOptions() imaging.Options {
+	opts := imaging.Options{
+		Grayscale:     isGrayscale,
+		Gamma:         gamma,
+		Contrast:      contrast,
+		AutoCrop:      isAutoCrop,
+		Quality:       jpegQuality,
+		Spread:        spread,
+		WebtoonHeight: webtoonHeight,
+	}
+	if isSkipCredits {
+		opts.Blocklist = mdx.LoadBlocklist()
+	}
+	if d, ok := filekit.DeviceProfile(device); ok {
+		opts.Width, opts.Height = d.Width, d.Height
+		opts.Grayscale = opts.Grayscale || d.Grayscale
+	}
+	return opts
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	retagCmd = &cobra.Command{
+		Use:   "retag <files-or-dirs...>",
+		Short: "Rewrite metadata of downloaded files with current MangaDex information",
+		Long: "Rewrite ComicInfo.xml and the ComicBookInfo comment of cbz and cbt files, the package\n" +
+			"metadata of fixed-layout epub files and the document information of pdf files with\n" +
+			"current information from MangaDex. Files are found by the manga and chapter IDs\n" +
+			"recorded at download time, directories are searched recursively. Pages are not changed.\n" +
+			"Directory outputs (--ext dir) store no metadata and reflowable epub files\n" +
+			"(--epub-layout reflow) record no MangaDex IDs, they can't be retagged.",
+		Args: cobra.MinimumNArgs(1),
+		Run:  retag,
+	}
+	isRetagDryRun bool
+)
+
+func init() {
+	rootCmd.AddCommand(retagCmd)
+
+	retagCmd.Flags().BoolVar(&isRetagDryRun,
+		"dry-run", false, "show changes of metadata without rewriting files")
+}
+
+func retag(cmd *cobra.Command, args []string) {
+	params := mdx.NewRetagParam(isRetagDryRun)
+	params.RunRetag(args)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
 	"github.com/arimatakao/mdx/app"
 	"github.com/arimatakao/mdx/internal/mdx"
@@ -18,13 +19,16 @@ var (
 )
 
 var (
-	versionApp bool
-	versionAPI bool
+	versionApp   bool
+	versionAPI   bool
+	outputFormat string
+	profile      string
 
 	rootCmd = &cobra.Command{
-		Use:   "mdx",
-		Short: app.SHORT_DESCRIPTION,
-		Long:  app.LONG_DESCRIPTION,
+		Use:              "mdx",
+		Short:            app.SHORT_DESCRIPTION,
+		Long:             app.LONG_DESCRIPTION,
+		PersistentPreRun: prepareCommand,
 		Run: func(cmd *cobra.Command, args []string) {
 			if versionApp {
 				mdx.PrintVersion()
@@ -56,4 +60,22 @@ func init() {
 	rootCmd.Flags().BoolP("help", "h", false, "Help message for toggle")
 	rootCmd.Flags().BoolVarP(&versionApp, "version", "v", false, "version of application")
 	rootCmd.Flags().BoolVarP(&versionAPI, "version-api", "a", false, "version of API")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output-format", mdx.OUTPUT_FORMAT_TABLE,
+		"output format of results: "+strings.Join(mdx.OutputFormats(), " ")+
+			", json and ndjson print results to stdout and messages to stderr")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "",
+		"use default flag values of the profile from the config file, see mdx config -h")
+}
+
+func prepareCommand(cmd *cobra.Command, args []string) {
+	applyConfig(cmd)
+	checkOutputFormat()
+}
+
+func checkOutputFormat() {
+	if mdx.IsNotSupportedOutputFormat(outputFormat) {
+		e.Printfln("%s output format is not supported", outputFormat)
+		os.Exit(0)
+	}
+	mdx.SetOutputFormat(outputFormat)
 }
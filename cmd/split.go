@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/internal/mdx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	splitCmd = &cobra.Command{
+		Use:   "split <files...>",
+		Short: "Split merged files into a file per chapter",
+		Long: "Split merged files into a file per chapter. Chapters are found by chapter folders,\n" +
+			"ComicInfo.xml bookmarks or the table of contents of epub files. Chapter files are named\n" +
+			"like downloads with --file-name and --layout. MangaDex is not used.",
+		Args:   cobra.MinimumNArgs(1),
+		PreRun: checkSplitArgs,
+		Run:    split,
+	}
+	splitExt       string
+	splitOutputDir string
+)
+
+func init() {
+	rootCmd.AddCommand(splitCmd)
+
+	splitCmd.Flags().StringVarP(&splitExt,
+		"ext", "e", filekit.CBZ_EXT, "choose output file format: pdf cbz epub dir cbt zip kindle html")
+	splitCmd.Flags().StringVarP(&splitOutputDir,
+		"output", "o", "", "specify output directory for files, the directory of each input file by default")
+	addNamingFlags(splitCmd)
+	addOutputFlags(splitCmd)
+	addWebtoonFlag(splitCmd, &offlineWebtoonMode, imaging.WEBTOON_OFF)
+}
+
+func checkSplitArgs(cmd *cobra.Command, args []string) {
+	checkNamingArgs()
+	checkOutputArgs()
+
+	if filekit.IsNotSupported(splitExt) {
+		e.Printfln("%s format of file is not supported", splitExt)
+		os.Exit(0)
+	}
+}
+
+func split(cmd *cobra.Command, args []string) {
+	params := mdx.NewOfflineParam(splitExt, splitOutputDir,
+		containerOptions(), imageOptions(), offlineWebtoonMode, naming())
+	params.RunSplit(args)
+}
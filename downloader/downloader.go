@@ -0,0 +1,667 @@
+// Package downloader downloads chapters of MangaDex manga into files of
+// filekit formats. It reports progress with events and returns errors
+// instead of printing them, so it can be used outside of the mdx command.
+// Manga to download are looked up with GetInfo and Find.
+//
+//	d := downloader.New(mangadexapi.NewClient(""), nil)
+//	result, err := d.Download(ctx, downloader.Request{
+//		MangaID:        "a3f91d0b-02f5-4a3d-a2d0-f0bde7152370",
+//		Language:       "en",
+//		LowestChapter:  1,
+//		HighestChapter: 3,
+//		OutputDir:      "manga",
+//		OutputExt:      filekit.CBZ_EXT,
+//	})
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/arimatakao/mdx/app"
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+// File statuses of FileResult.
+const (
+	FILE_SAVED = "saved"
+	// FILE_SKIPPED is a file that already exists and is kept by the
+	// filekit.ON_EXISTS_SKIP policy.
+	FILE_SKIPPED = "skipped"
+	FILE_FAILED  = "failed"
+	// FILE_UNAVAILABLE is a chapter with no pages to download, Path is the
+	// .url shortcut if it is saved.
+	FILE_UNAVAILABLE = "unavailable"
+)
+
+var (
+	ErrNoChapters         = errors.New("no chapters found, try another range, language or translation group")
+	ErrNoReadableChapters = errors.New("none of the chapters can be downloaded from MangaDex")
+)
+
+// Request describes chapters to download and files to write.
+type Request struct {
+	MangaID string
+	// ChapterID downloads one chapter, the manga and the selection of
+	// chapters are ignored.
+	ChapterID        string
+	Language         string
+	TranslationGroup string
+
+	// Chapters are selected by whole numbers from LowestChapter to
+	// HighestChapter, by volumes from LowestVolume to HighestVolume if
+	// IsVolume is set, IsAll and IsLast select all chapters or the last one.
+	LowestChapter  int
+	HighestChapter int
+	LowestVolume   int
+	HighestVolume  int
+	IsVolume       bool
+	IsAll          bool
+	IsLast         bool
+	// ChaptersRange is the range of merged chapters recorded in metadata,
+	// e.g. "1-10".
+	ChaptersRange string
+
+	OutputDir string
+	// OutputExt is one of the filekit extensions.
+	OutputExt string
+	// FileNameTemplate has fields %1 language, %2 translator, %3 manga title,
+	// %4 volume, %5 chapter or range and %6 chapter title.
+	FileNameTemplate string
+	Layout           Layout
+	ContainerOptions filekit.Options
+	ImageOptions     imaging.Options
+	// WebtoonMode is one of the imaging.WEBTOON_* modes, empty is off.
+	WebtoonMode string
+	// IsJpg downloads compressed pages.
+	IsJpg bool
+	// IsMerge merges chapters into one file, or into a file per volume with
+	// IsVolume.
+	IsMerge bool
+	// IsUrlShortcut saves a .url shortcut to the official source of chapters
+	// hosted on external sites.
+	IsUrlShortcut bool
+}
+
+// Result is the outcome of a download.
+type Result struct {
+	Files []FileResult
+	// Skipped are selected chapters with no pages to download.
+	Skipped []SkippedChapter
+}
+
+// FileResult is the result of one file of the download.
+type FileResult struct {
+	// Path is the target path, the filekit.ON_EXISTS_RENAME policy may save
+	// the file under another name.
+	Path       string
+	Status     string
+	ChapterIDs []string
+	// Reason is why the file is skipped, failed or unavailable.
+	Reason string
+	// Missing are skipped chapters a merged file is saved without.
+	Missing []SkippedChapter
+}
+
+// SkippedChapter is a selected chapter that has no pages to download.
+type SkippedChapter struct {
+	Chapter mangadexapi.Chapter
+	Reason  string
+}
+
+// Client is the part of the MangaDex API used by downloads,
+// mangadexapi.Clientapi implements it.
+type Client interface {
+	GetMangaInfo(mangaID string) (mangadexapi.MangaInfoResponse, error)
+	GetChapterInfo(chapterID string) (mangadexapi.ResponseChapter, error)
+	GetAllChaptersInfo(mangaID, language, translationGroup string) ([]mangadexapi.Chapter, error)
+	GetChapterImagesInFullInfo(chapter mangadexapi.Chapter) (mangadexapi.ChapterFullInfo, error)
+	DownloadImageContext(ctx context.Context, baseURL, chapterHash, imageFilename string,
+		isJpg bool) ([]byte, bool, error)
+}
+
+// Downloader downloads chapters with the client and reports progress to the
+// handler.
+type Downloader struct {
+	client  Client
+	handler Handler
+	// newContainer creates containers of output files.
+	newContainer func(extension string, opts filekit.Options) (filekit.Container, error)
+}
+
+// New returns a Downloader, handler may be nil.
+func New(client Client, handler Handler) *Downloader {
+	return &Downloader{
+		client:       client,
+		handler:      handler,
+		newContainer: filekit.NewContainer,
+	}
+}
+
+func (d *Downloader) emit(event Event) {
+	if d.handler != nil {
+		d.handler(event)
+	}
+}
+
+// FetchManga returns information about the manga.
+func (d *Downloader) FetchManga(mangaID string) (mangadexapi.MangaInfo, error) {
+	resp, err := d.client.GetMangaInfo(mangaID)
+	if err != nil {
+		return mangadexapi.MangaInfo{}, err
+	}
+	return resp.MangaInfo(), nil
+}
+
+// FetchChapter returns the chapter and its manga.
+func (d *Downloader) FetchChapter(chapterID string) (mangadexapi.MangaInfo, mangadexapi.Chapter, error) {
+	resp, err := d.client.GetChapterInfo(chapterID)
+	if err != nil {
+		return mangadexapi.MangaInfo{}, mangadexapi.Chapter{}, err
+	}
+	chapter := resp.GetChapterInfo()
+
+	manga := mangadexapi.MangaInfo{}
+	if mangaID := chapter.GetMangaId(); mangaID != "" {
+		manga, err = d.FetchManga(mangaID)
+		if err != nil {
+			return mangadexapi.MangaInfo{}, mangadexapi.Chapter{}, err
+		}
+	}
+	return manga, chapter, nil
+}
+
+// FetchChapters returns chapters of the manga in the language and the
+// translation group of req, selected by req.
+func (d *Downloader) FetchChapters(req Request) ([]mangadexapi.Chapter, error) {
+	chapters, err := d.client.GetAllChaptersInfo(req.MangaID, req.Language, req.TranslationGroup)
+	if err != nil {
+		return nil, err
+	}
+	return req.Select(chapters), nil
+}
+
+// Select returns chapters selected by the chapter or volume range of r.
+func (r Request) Select(chapters []mangadexapi.Chapter) []mangadexapi.Chapter {
+	if r.IsAll || len(chapters) == 0 {
+		return chapters
+	}
+
+	if r.IsLast {
+		return []mangadexapi.Chapter{chapters[len(chapters)-1]}
+	}
+
+	selected := []mangadexapi.Chapter{}
+	for _, c := range chapters {
+		number := c.Number()
+		low, high := r.LowestChapter, r.HighestChapter
+		if r.IsVolume {
+			number = c.Volume()
+			low, high = r.LowestVolume, r.HighestVolume
+		}
+		value, err := strconv.Atoi(number)
+		if err != nil || value < low || high < value {
+			continue
+		}
+		selected = append(selected, c)
+		if !r.IsVolume && low == high {
+			break
+		}
+	}
+	return selected
+}
+
+// Download fetches the manga and chapters of req and downloads them, see
+// DownloadChapters.
+func (d *Downloader) Download(ctx context.Context, req Request) (Result, error) {
+	var (
+		manga    mangadexapi.MangaInfo
+		chapters []mangadexapi.Chapter
+		err      error
+	)
+	if req.ChapterID != "" {
+		var chapter mangadexapi.Chapter
+		manga, chapter, err = d.FetchChapter(req.ChapterID)
+		chapters = []mangadexapi.Chapter{chapter}
+	} else {
+		manga, err = d.FetchManga(req.MangaID)
+		if err == nil {
+			chapters, err = d.FetchChapters(req)
+		}
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	if len(chapters) == 0 {
+		return Result{}, ErrNoChapters
+	}
+	return d.DownloadChapters(ctx, req, manga, chapters)
+}
+
+// DownloadChapters downloads chapters of the manga into files described by
+// req. Chapters with no pages to download are skipped with the reason. It
+// stops at the first file that fails or when ctx is done, the result has
+// files handled until then.
+func (d *Downloader) DownloadChapters(ctx context.Context, req Request, manga mangadexapi.MangaInfo,
+	chapters []mangadexapi.Chapter) (Result, error) {
+	j := d.newJob(req, manga, chapters)
+	readable, err := j.skipUnreadable()
+	if err != nil {
+		return j.result, err
+	}
+	if len(readable) == 0 {
+		return j.result, ErrNoReadableChapters
+	}
+
+	// image lists are fetched first, downloads of images change the base
+	// URL of the client
+	for _, chapter := range readable {
+		if err := ctx.Err(); err != nil {
+			return j.result, err
+		}
+		fullInfo, err := d.client.GetChapterImagesInFullInfo(chapter)
+		if err != nil {
+			return j.result, fmt.Errorf("getting images of chapter %s: %w", chapter.Number(), err)
+		}
+		j.chapters = append(j.chapters, fullInfo)
+	}
+
+	for _, file := range j.files() {
+		if len(file.chapters) == 0 {
+			d.emit(VolumeSkipped{Volume: file.volume})
+			continue
+		}
+		if err := j.downloadFile(ctx, file); err != nil {
+			return j.result, err
+		}
+	}
+	return j.result, nil
+}
+
+// job is one run of DownloadChapters.
+type job struct {
+	d     *Downloader
+	req   Request
+	manga mangadexapi.MangaInfo
+	// selected are chapters selected for the download, chapters are the
+	// readable ones among them
+	selected []mangadexapi.Chapter
+	chapters []mangadexapi.ChapterFullInfo
+	result   Result
+	// seriesDirs are looked up series folders by their rendered paths
+	seriesDirs map[string]string
+}
+
+func (d *Downloader) newJob(req Request, manga mangadexapi.MangaInfo,
+	selected []mangadexapi.Chapter) *job {
+	return &job{
+		d:          d,
+		req:        req,
+		manga:      manga,
+		selected:   selected,
+		chapters:   []mangadexapi.ChapterFullInfo{},
+		result:     Result{Files: []FileResult{}, Skipped: []SkippedChapter{}},
+		seriesDirs: map[string]string{},
+	}
+}
+
+// outputFile is a file of the download with its chapters.
+type outputFile struct {
+	dir  string
+	name string
+	// seriesDir is the series folder of the layout, it gets the series
+	// marker once the file is saved
+	seriesDir string
+	// volume is set for files of merged volumes
+	volume   string
+	chapters []mangadexapi.ChapterFullInfo
+	// selected are chapters selected for the file, skipped ones included
+	selected []mangadexapi.Chapter
+	isMerged bool
+	// chapterRange is recorded in metadata of merged files
+	chapterRange string
+}
+
+// files groups readable chapters into output files. Files of merged volumes
+// without readable chapters are kept, so they can be reported.
+func (j *job) files() []outputFile {
+	files := []outputFile{}
+	switch {
+	case len(j.chapters) == 0:
+	case j.req.IsVolume && j.req.IsMerge:
+		volumes := []string{}
+		volumeSelected := map[string][]mangadexapi.Chapter{}
+		for _, chapter := range j.selected {
+			if _, ok := volumeSelected[chapter.Volume()]; !ok {
+				volumes = append(volumes, chapter.Volume())
+			}
+			volumeSelected[chapter.Volume()] = append(volumeSelected[chapter.Volume()], chapter)
+		}
+		for _, volume := range volumes {
+			chapters, chaptersRange := j.volumeChapters(volumeSelected[volume])
+			file := outputFile{
+				volume:   volume,
+				chapters: chapters,
+				selected: volumeSelected[volume],
+				isMerged: true,
+			}
+			file.dir, file.name, file.seriesDir = j.volumeFileName(volume, chaptersRange, volumeSelected[volume][0])
+			files = append(files, file)
+		}
+	case j.req.IsMerge:
+		chaptersRange := j.chapters[0].Number()
+		if len(j.chapters) > 1 {
+			chaptersRange += "-" + j.chapters[len(j.chapters)-1].Number()
+		}
+		file := outputFile{
+			chapters:     j.chapters,
+			selected:     j.selected,
+			isMerged:     true,
+			chapterRange: j.req.ChaptersRange,
+		}
+		file.dir, file.name, file.seriesDir = j.mergeChaptersFileName(chaptersRange)
+		files = append(files, file)
+	default:
+		for _, chapter := range j.chapters {
+			file := outputFile{
+				chapters: []mangadexapi.ChapterFullInfo{chapter},
+				selected: []mangadexapi.Chapter{chapter.Info},
+			}
+			file.dir, file.name, file.seriesDir = j.chapterFileName(chapter)
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// volumeChapters returns readable chapters of volume without chapters of
+// the same number and the range of their numbers.
+func (j *job) volumeChapters(volume []mangadexapi.Chapter) ([]mangadexapi.ChapterFullInfo, string) {
+	volumeChapters := []mangadexapi.ChapterFullInfo{}
+	volumeChaptersRange := []string{}
+	for _, chapter := range volume {
+		for _, chapterFullInfo := range j.chapters {
+			if chapterFullInfo.Info.ID == chapter.ID &&
+				!slices.Contains(volumeChaptersRange, chapterFullInfo.Info.Number()) {
+
+				volumeChaptersRange = append(volumeChaptersRange, chapterFullInfo.Info.Number())
+				volumeChapters = append(volumeChapters, chapterFullInfo)
+				break
+			}
+		}
+	}
+	startChapter := minChapter(volumeChaptersRange)
+	endChapter := maxChapter(volumeChaptersRange)
+	return volumeChapters, startChapter + "-" + endChapter
+}
+
+func (j *job) downloadFile(ctx context.Context, file outputFile) error {
+	result := FileResult{
+		Path:       filekit.OutputPath(file.dir, file.name, j.req.OutputExt),
+		ChapterIDs: chapterIDs(file.chapters),
+		Missing:    j.missingIn(file.selected),
+	}
+	if j.req.ContainerOptions.OnExists == filekit.ON_EXISTS_SKIP &&
+		filekit.OutputExists(file.dir, file.name, j.req.OutputExt) {
+		result.Status, result.Reason = FILE_SKIPPED, "it already exists"
+		j.report(result)
+		return nil
+	}
+
+	opts := j.req.ContainerOptions
+	// in-progress files are kept in the output directory, so finished files
+	// are moved into place by a rename
+	opts.WorkDir = j.req.OutputDir
+	container, err := j.d.newContainer(j.req.OutputExt, opts)
+	if err != nil {
+		return j.fail(result, fmt.Errorf("creating %s: %w", result.Path, err))
+	}
+
+	for _, chapter := range file.chapters {
+		if file.isMerged {
+			err = container.BeginChapter(chapterBoundary(chapter))
+		}
+		if err == nil {
+			err = j.downloadPages(ctx, container, chapter)
+		}
+		if err != nil {
+			container.Abort()
+			return j.fail(result, fmt.Errorf("downloading chapter %s: %w", chapter.Number(), err))
+		}
+	}
+
+	metaInfo := metadata.NewMetadata(app.USER_AGENT, j.manga, file.chapters[0])
+	metaInfo.SetSourceIDs(metadata.SourceIDs{Manga: j.manga.ID, Chapters: result.ChapterIDs})
+	j.d.emit(FileSaving{Path: result.Path})
+	err = container.WriteOnDiskAndClose(file.dir, file.name, metaInfo, file.chapterRange)
+	if errors.Is(err, filekit.ErrOutputSkipped) {
+		result.Status, result.Reason = FILE_SKIPPED, "it already exists"
+		j.report(result)
+		return nil
+	}
+	if err != nil {
+		container.Abort()
+		return j.fail(result, fmt.Errorf("saving %s: %w", result.Path, err))
+	}
+	result.Status = FILE_SAVED
+	j.writeSeriesMarker(file.seriesDir)
+	j.report(result)
+	return nil
+}
+
+// writeSeriesMarker records the manga in its series folder, if the folder
+// doesn't have the marker yet.
+func (j *job) writeSeriesMarker(seriesDir string) {
+	if seriesDir == "" {
+		return
+	}
+	if _, ok := readSeriesMarker(seriesDir); ok {
+		return
+	}
+	err := writeSeriesMarker(seriesDir, nameFields{series: j.manga.Title("en"), mangaId: j.manga.ID})
+	if err != nil {
+		j.d.emit(Warning{Err: fmt.Errorf("writing series metadata: %w", err)})
+	}
+}
+
+func (j *job) downloadPages(ctx context.Context, container filekit.Container,
+	chapter mangadexapi.ChapterFullInfo) error {
+	files := chapter.PngFiles
+	imgExt := "png"
+	if j.req.IsJpg {
+		files = chapter.JpgFiles
+		imgExt = "jpg"
+	}
+	j.d.emit(ChapterStarted{Chapter: chapter, Pages: len(files)})
+
+	imageOpts := j.req.ImageOptions
+	imageOpts.RightToLeft = metadata.IsRightToLeftLanguage(j.manga.OriginalLanguage())
+	imageOpts.Webtoon = imaging.IsWebtoon(j.req.WebtoonMode, j.manga.IsLongStrip())
+	pipeline := NewPagePipeline(container, imageOpts)
+	for i, imageFile := range files {
+		if err := ctx.Err(); err != nil {
+			pipeline.Wait()
+			return err
+		}
+
+		outputImage, isRealJpg, err := j.d.client.DownloadImageContext(ctx, chapter.DownloadBaseURL,
+			chapter.HashId, imageFile, j.req.IsJpg)
+		if errors.Is(err, mangadexapi.ErrNotImageMedia) {
+			j.d.emit(PageUnsupported{Chapter: chapter, File: imageFile})
+			continue
+		} else if err != nil {
+			pipeline.Wait()
+			// an interrupted request fails with a wrapped error
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+
+		if isRealJpg {
+			imgExt = "jpg"
+		}
+
+		if err := pipeline.Add(imgExt, outputImage); err != nil {
+			pipeline.Wait()
+			return err
+		}
+		j.d.emit(PageDownloaded{Chapter: chapter, Page: i + 1, Pages: len(files)})
+	}
+	if err := pipeline.Wait(); err != nil {
+		return err
+	}
+	j.d.emit(ChapterDownloaded{Chapter: chapter, DroppedPages: pipeline.Dropped()})
+	return nil
+}
+
+func (j *job) report(result FileResult) {
+	j.result.Files = append(j.result.Files, result)
+	j.d.emit(FileDone{File: result})
+}
+
+// fail reports the file as failed and returns err.
+func (j *job) fail(result FileResult, err error) error {
+	result.Status, result.Reason = FILE_FAILED, err.Error()
+	j.report(result)
+	return err
+}
+
+// skipUnreadable returns selected chapters with pages to download now.
+// Chapters hosted on other sites and chapters published in the future are
+// reported with the reason, external chapters get a .url shortcut to the
+// official source if it is enabled.
+func (j *job) skipUnreadable() ([]mangadexapi.Chapter, error) {
+	now := time.Now()
+	readable := []mangadexapi.Chapter{}
+	for _, chapter := range j.selected {
+		reason := skipReason(chapter, now)
+		if reason == "" {
+			readable = append(readable, chapter)
+			continue
+		}
+
+		j.result.Skipped = append(j.result.Skipped, SkippedChapter{Chapter: chapter, Reason: reason})
+		shortcutPath := ""
+		if j.req.IsUrlShortcut && chapter.IsExternal() {
+			var err error
+			if shortcutPath, err = j.saveUrlShortcut(chapter); err != nil {
+				return nil, err
+			}
+		}
+		j.d.emit(ChapterSkipped{Chapter: chapter, Reason: reason, Shortcut: shortcutPath})
+		j.report(FileResult{
+			Path:       shortcutPath,
+			Status:     FILE_UNAVAILABLE,
+			ChapterIDs: []string{chapter.ID},
+			Reason:     reason,
+		})
+	}
+	return readable, nil
+}
+
+// skipReason returns why pages of chapter can't be downloaded at now, it is
+// empty for readable chapters.
+func skipReason(chapter mangadexapi.Chapter, now time.Time) string {
+	const timeLayout = "2006-01-02 15:04"
+	attrs := chapter.Attributes
+	switch {
+	case chapter.IsExternal():
+		return "it is hosted on an external site, read it at " + chapter.ExternalUrl()
+	case chapter.IsReadable(now):
+		return ""
+	case attrs.PublishAt.After(now):
+		return "it will be published on " + attrs.PublishAt.Local().Format(timeLayout)
+	default:
+		return "it will be readable on " + attrs.ReadableAt.Local().Format(timeLayout)
+	}
+}
+
+// saveUrlShortcut saves an Internet Shortcut file next to downloaded
+// chapters, it opens the official source of the external chapter. An
+// existing shortcut is handled by the on-exists policy of the request.
+func (j *job) saveUrlShortcut(chapter mangadexapi.Chapter) (string, error) {
+	outputDir, filename, seriesDir := j.chapterFileName(mangadexapi.ChapterFullInfo{Info: chapter})
+	content := "[InternetShortcut]\r\nURL=" + chapter.ExternalUrl() + "\r\n"
+
+	shortcutPath, err := filekit.WriteOutputFile(outputDir, filename, "url", j.req.ContainerOptions.OnExists,
+		func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		})
+	if errors.Is(err, filekit.ErrOutputSkipped) {
+		return filekit.OutputPath(outputDir, filename, "url"), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("saving shortcut of chapter %s: %w", chapter.Number(), err)
+	}
+	j.writeSeriesMarker(seriesDir)
+	return shortcutPath, nil
+}
+
+// missingIn returns skipped chapters among chapters selected for a merged
+// file.
+func (j *job) missingIn(selected []mangadexapi.Chapter) []SkippedChapter {
+	missing := []SkippedChapter{}
+	for _, skipped := range j.result.Skipped {
+		for _, chapter := range selected {
+			if chapter.ID == skipped.Chapter.ID {
+				missing = append(missing, skipped)
+				break
+			}
+		}
+	}
+	return missing
+}
+
+func chapterIDs(chapters []mangadexapi.ChapterFullInfo) []string {
+	ids := []string{}
+	for _, chapter := range chapters {
+		ids = append(ids, chapter.Info.ID)
+	}
+	return ids
+}
+
+// chapterBoundary describes chapter for containers with merged chapters.
+func chapterBoundary(chapter mangadexapi.ChapterFullInfo) filekit.Chapter {
+	return filekit.Chapter{
+		Number: chapter.Number(),
+		Title:  chapter.Title(),
+		Volume: chapter.Volume(),
+		Group:  chapter.Translator(),
+	}
+}
+
+func maxChapter(chapters []string) string {
+	if len(chapters) == 0 {
+		return ""
+	}
+	max := chapters[0]
+	for _, ch := range chapters {
+		if ch > max {
+			max = ch
+		}
+	}
+	return max
+}
+
+func minChapter(chapters []string) string {
+	if len(chapters) == 0 {
+		return ""
+	}
+	min := chapters[0]
+	for _, ch := range chapters {
+		if ch < min {
+			min = ch
+		}
+	}
+	return min
+}
@@ -0,0 +1,344 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+func TestSelect(t *testing.T) {
+	chapters := []mangadexapi.Chapter{}
+	for _, c := range [][2]string{{"1", "1"}, {"1", "2"}, {"1", "2.5"}, {"2", "3"}, {"", "4"}} {
+		chapters = append(chapters, mangadexapi.Chapter{ID: c[1],
+			Attributes: mangadexapi.ChapterAttr{Volume: c[0], Chapter: c[1]}})
+	}
+
+	tests := []struct {
+		name string
+		req  Request
+		want []string
+	}{
+		{"One chapter", Request{LowestChapter: 2, HighestChapter: 2}, []string{"2"}},
+		{"Chapter range", Request{LowestChapter: 2, HighestChapter: 4}, []string{"2", "3", "4"}},
+		{"Volume", Request{IsVolume: true, LowestVolume: 1, HighestVolume: 1}, []string{"1", "2", "2.5"}},
+		{"Last chapter", Request{IsLast: true}, []string{"4"}},
+		{"All chapters", Request{IsAll: true}, []string{"1", "2", "2.5", "3", "4"}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, c := range tt.req.Select(chapters) {
+			got = append(got, c.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Test Case: %s. Expected %v, but got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	past := now.AddDate(0, 0, -1)
+	future := now.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name string
+		attr mangadexapi.ChapterAttr
+		want string
+	}{
+		{"Readable", mangadexapi.ChapterAttr{PublishAt: past, ReadableAt: past}, ""},
+		{"External", mangadexapi.ChapterAttr{ExternalUrl: "https://mangaplus.shueisha.co.jp"},
+			"it is hosted on an external site, read it at https://mangaplus.shueisha.co.jp"},
+		{"Scheduled", mangadexapi.ChapterAttr{PublishAt: future, ReadableAt: future}, "it will be published on"},
+		{"Readable later", mangadexapi.ChapterAttr{PublishAt: past, ReadableAt: future}, "it will be readable on"},
+	}
+
+	for _, tt := range tests {
+		got := skipReason(mangadexapi.Chapter{Attributes: tt.attr}, now)
+		if (tt.want == "") != (got == "") || !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSkipUnreadable(t *testing.T) {
+	chapter := func(id, number string, attr mangadexapi.ChapterAttr) mangadexapi.Chapter {
+		attr.Volume, attr.Chapter = "1", number
+		return mangadexapi.Chapter{ID: id, Attributes: attr}
+	}
+	selected := []mangadexapi.Chapter{
+		chapter("c1", "1", mangadexapi.ChapterAttr{}),
+		chapter("c2", "2", mangadexapi.ChapterAttr{ExternalUrl: "https://mangaplus.shueisha.co.jp/viewer/2"}),
+		chapter("c3", "3", mangadexapi.ChapterAttr{PublishAt: time.Now().AddDate(0, 0, 1)}),
+	}
+	req := Request{OutputDir: t.TempDir(), OutputExt: filekit.CBZ_EXT, IsMerge: true, IsUrlShortcut: true,
+		ContainerOptions: filekit.Options{OnExists: filekit.ON_EXISTS_SKIP}}
+	j := New(fakeClient{}, nil).newJob(req, mangadexapi.MangaInfo{}, selected)
+
+	readable, err := j.skipUnreadable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readable) != 1 || readable[0].ID != "c1" {
+		t.Errorf("Expected readable chapter c1, but got %v", readable)
+	}
+
+	// skipped chapters are reported as unavailable, external ones with a shortcut
+	if len(j.result.Files) != 2 {
+		t.Fatalf("Expected 2 unavailable files, but got %v", j.result.Files)
+	}
+	shortcut := j.result.Files[0]
+	content, err := os.ReadFile(shortcut.Path)
+	if shortcut.Status != FILE_UNAVAILABLE || err != nil ||
+		!strings.Contains(string(content), "URL=https://mangaplus.shueisha.co.jp/viewer/2") {
+		t.Errorf("Expected a shortcut of c2, but got %v (%v)", shortcut, err)
+	}
+	// an existing shortcut is kept by the on-exists policy
+	again := New(fakeClient{}, nil).newJob(req, mangadexapi.MangaInfo{}, selected)
+	if _, err := again.skipUnreadable(); err != nil || again.result.Files[0].Path != shortcut.Path {
+		t.Errorf("Expected the existing shortcut %s, but got %v (%v)", shortcut.Path, again.result.Files, err)
+	}
+	if shortcuts, _ := filepath.Glob(filepath.Join(filepath.Dir(shortcut.Path), "*.url")); len(shortcuts) != 1 {
+		t.Errorf("Expected one shortcut, but got %v", shortcuts)
+	}
+	if file := j.result.Files[1]; file.Status != FILE_UNAVAILABLE || file.Path != "" ||
+		!slices.Equal(file.ChapterIDs, []string{"c3"}) {
+		t.Errorf("Expected c3 unavailable without a shortcut, but got %v", file)
+	}
+
+	// the merged file reports chapters it is saved without
+	j.chapters = []mangadexapi.ChapterFullInfo{{Info: readable[0]}}
+	files := j.files()
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 merged file, but got %d", len(files))
+	}
+	missing := []string{}
+	for _, skipped := range j.missingIn(files[0].selected) {
+		missing = append(missing, skipped.Chapter.ID)
+	}
+	if !slices.Equal(missing, []string{"c2", "c3"}) {
+		t.Errorf("Expected missing chapters c2, c3 in the merged file, but got %v", missing)
+	}
+}
+
+// fakeClient serves a manga with chapters of two pages, image is called for
+// every page.
+type fakeClient struct {
+	manga    mangadexapi.MangaInfo
+	chapters []mangadexapi.Chapter
+	image    func(ctx context.Context, imageFilename string) ([]byte, error)
+}
+
+func (c fakeClient) GetMangaInfo(mangaID string) (mangadexapi.MangaInfoResponse, error) {
+	return mangadexapi.MangaInfoResponse{Data: c.manga}, nil
+}
+
+func (c fakeClient) GetChapterInfo(chapterID string) (mangadexapi.ResponseChapter, error) {
+	for _, chapter := range c.chapters {
+		if chapter.ID == chapterID {
+			return mangadexapi.ResponseChapter{Data: chapter}, nil
+		}
+	}
+	return mangadexapi.ResponseChapter{}, errors.New("chapter not found")
+}
+
+func (c fakeClient) GetAllChaptersInfo(mangaID, language, translationGroup string) ([]mangadexapi.Chapter, error) {
+	return c.chapters, nil
+}
+
+func (c fakeClient) GetChapterImagesInFullInfo(chapter mangadexapi.Chapter) (mangadexapi.ChapterFullInfo, error) {
+	return mangadexapi.ChapterFullInfo{
+		Info:            chapter,
+		DownloadBaseURL: "https://uploads.mangadex.org",
+		HashId:          chapter.ID,
+		PngFiles:        []string{chapter.ID + "-1.png", chapter.ID + "-2.png"},
+	}, nil
+}
+
+func (c fakeClient) DownloadImageContext(ctx context.Context, baseURL, chapterHash, imageFilename string,
+	isJpg bool) ([]byte, bool, error) {
+	page, err := c.image(ctx, imageFilename)
+	return page, false, err
+}
+
+func TestDownload(t *testing.T) {
+	page := new(bytes.Buffer)
+	if err := png.Encode(page, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+	servePage := func(ctx context.Context, imageFilename string) ([]byte, error) {
+		return page.Bytes(), nil
+	}
+	chapters := []mangadexapi.Chapter{
+		{ID: "c1", Attributes: mangadexapi.ChapterAttr{Volume: "1", Chapter: "1"}},
+		{ID: "c2", Attributes: mangadexapi.ChapterAttr{Volume: "1", Chapter: "2",
+			ExternalUrl: "https://mangaplus.shueisha.co.jp/viewer/2"}},
+		{ID: "c3", Attributes: mangadexapi.ChapterAttr{Volume: "1", Chapter: "3"}},
+	}
+	manga := mangadexapi.MangaInfo{ID: "m1"}
+
+	tests := []struct {
+		name  string
+		image func(ctx context.Context, imageFilename string) ([]byte, error)
+		// exists downloads the chapters before
+		exists bool
+		// want are statuses of c2 and c1 and c3 files in this order
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "Saved",
+			image: servePage,
+			want:  []string{FILE_UNAVAILABLE, FILE_SAVED, FILE_SAVED},
+		},
+		{
+			name:   "Skipped",
+			image:  servePage,
+			exists: true,
+			want:   []string{FILE_UNAVAILABLE, FILE_SKIPPED, FILE_SKIPPED},
+		},
+		{
+			name: "Failed",
+			image: func(ctx context.Context, imageFilename string) ([]byte, error) {
+				if imageFilename == "c3-2.png" {
+					return nil, errTestImage
+				}
+				return page.Bytes(), nil
+			},
+			want:    []string{FILE_UNAVAILABLE, FILE_SAVED, FILE_FAILED},
+			wantErr: errTestImage,
+		},
+		{
+			name:    "Canceled",
+			want:    []string{FILE_UNAVAILABLE, FILE_FAILED},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.image == nil {
+			// the first request is interrupted
+			tt.image = func(ctx context.Context, imageFilename string) ([]byte, error) {
+				cancel()
+				<-ctx.Done()
+				return nil, fmt.Errorf("get %s: %w", imageFilename, ctx.Err())
+			}
+		}
+
+		req := Request{MangaID: "m1", Language: "en", IsAll: true, OutputDir: t.TempDir(),
+			OutputExt: filekit.CBZ_EXT, ContainerOptions: filekit.Options{OnExists: filekit.ON_EXISTS_SKIP}}
+		if tt.exists {
+			if _, err := New(fakeClient{manga: manga, chapters: chapters, image: servePage}, nil).
+				Download(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		d := New(fakeClient{manga: manga, chapters: chapters, image: tt.image}, nil)
+		result, err := d.Download(ctx, req)
+		cancel()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Test Case: %s. Expected error %v, but got %v", tt.name, tt.wantErr, err)
+		}
+
+		statuses := []string{}
+		for _, file := range result.Files {
+			statuses = append(statuses, file.Status)
+			_, statErr := os.Stat(file.Path)
+			isWritten := statErr == nil
+			if shouldExist := file.Status == FILE_SAVED || file.Status == FILE_SKIPPED; isWritten != shouldExist {
+				t.Errorf("Test Case: %s. Expected %s file %s to exist %v, but got %v",
+					tt.name, file.Status, file.Path, shouldExist, isWritten)
+			}
+		}
+		if !slices.Equal(statuses, tt.want) {
+			t.Errorf("Test Case: %s. Expected statuses %v, but got %v", tt.name, tt.want, statuses)
+		}
+	}
+}
+
+var errTestImage = errors.New("image not found")
+
+// failingContainer fails to save or to add pages to the container it wraps.
+type failingContainer struct {
+	filekit.Container
+	failAdd bool
+	aborted *bool
+}
+
+func (c failingContainer) AddFile(fileExt string, imageBytes []byte) error {
+	if c.failAdd {
+		return errTestContainer
+	}
+	return c.Container.AddFile(fileExt, imageBytes)
+}
+
+func (c failingContainer) WriteOnDiskAndClose(outputDir, outputFileName string, m metadata.Metadata,
+	chapterRange string) error {
+	return errTestContainer
+}
+
+func (c failingContainer) Abort() error {
+	*c.aborted = true
+	return c.Container.Abort()
+}
+
+func TestDownloadAbortsContainer(t *testing.T) {
+	page := new(bytes.Buffer)
+	if err := png.Encode(page, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+	client := fakeClient{
+		manga:    mangadexapi.MangaInfo{ID: "m1"},
+		chapters: []mangadexapi.Chapter{{ID: "c1", Attributes: mangadexapi.ChapterAttr{Volume: "1", Chapter: "1"}}},
+		image: func(ctx context.Context, imageFilename string) ([]byte, error) {
+			return page.Bytes(), nil
+		},
+	}
+
+	tests := []struct {
+		name    string
+		failAdd bool
+	}{
+		{"Failed page", true},
+		{"Failed saving", false},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		aborted := false
+		d := New(client, nil)
+		d.newContainer = func(extension string, opts filekit.Options) (filekit.Container, error) {
+			c, err := filekit.NewContainer(extension, opts)
+			return failingContainer{Container: c, failAdd: tt.failAdd, aborted: &aborted}, err
+		}
+
+		_, err := d.Download(context.Background(), Request{MangaID: "m1", Language: "en", IsAll: true,
+			OutputDir: dir, OutputExt: filekit.CBZ_EXT})
+		if !errors.Is(err, errTestContainer) {
+			t.Errorf("Test Case: %s. Expected error %v, but got %v", tt.name, errTestContainer, err)
+		}
+		if !aborted {
+			t.Errorf("Test Case: %s. Expected the container aborted", tt.name)
+		}
+		if leftovers, _ := filepath.Glob(filepath.Join(dir, ".mdx-*")); len(leftovers) != 0 {
+			t.Errorf("Test Case: %s. Expected no temporary files, but got %v", tt.name, leftovers)
+		}
+	}
+}
+
+var errTestContainer = errors.New("container failed")
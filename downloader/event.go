@@ -0,0 +1,88 @@
+package downloader
+
+import "github.com/arimatakao/mdx/mangadexapi"
+
+// Event is a step of a download reported to the Handler of a Downloader,
+// it is one of the event types below.
+type Event interface {
+	isEvent()
+}
+
+// Handler receives events of a Downloader. It is called from the goroutine
+// that runs the download, so it must not block for long.
+type Handler func(Event)
+
+// ChapterSkipped is a selected chapter that has no pages to download.
+type ChapterSkipped struct {
+	Chapter mangadexapi.Chapter
+	Reason  string
+	// Shortcut is the saved .url file of an external chapter, it is empty
+	// unless Request.IsUrlShortcut is set.
+	Shortcut string
+}
+
+// VolumeSkipped is a volume none of the chapters of which can be downloaded.
+type VolumeSkipped struct {
+	Volume string
+}
+
+// ChapterStarted is sent before pages of the chapter are downloaded.
+type ChapterStarted struct {
+	Chapter mangadexapi.ChapterFullInfo
+	Pages   int
+}
+
+// PageDownloaded is sent for every downloaded page, Page counts from 1.
+type PageDownloaded struct {
+	Chapter mangadexapi.ChapterFullInfo
+	Page    int
+	Pages   int
+}
+
+// PageUnsupported is a page file of the chapter that is not an image, it
+// is left out.
+type PageUnsupported struct {
+	Chapter mangadexapi.ChapterFullInfo
+	File    string
+}
+
+// ChapterDownloaded is sent once pages of the chapter are added to the file.
+type ChapterDownloaded struct {
+	Chapter mangadexapi.ChapterFullInfo
+	// DroppedPages counts pages dropped by the blocklist.
+	DroppedPages int
+}
+
+// FileSaving is sent before the file is written to disk.
+type FileSaving struct {
+	Path string
+}
+
+// FileDone is sent with the result of every file, the same result is in
+// Result.Files.
+type FileDone struct {
+	File FileResult
+}
+
+// SeriesFolderReused is sent when the layout puts files of a renamed series
+// into the folder of its old title.
+type SeriesFolderReused struct {
+	Folder string
+	Series string
+}
+
+// Warning is a problem that doesn't stop the download.
+type Warning struct {
+	Err error
+}
+
+func (ChapterSkipped) isEvent()     {}
+func (VolumeSkipped) isEvent()      {}
+func (ChapterStarted) isEvent()     {}
+func (PageDownloaded) isEvent()     {}
+func (PageUnsupported) isEvent()    {}
+func (ChapterDownloaded) isEvent()  {}
+func (FileSaving) isEvent()         {}
+func (FileDone) isEvent()           {}
+func (SeriesFolderReused) isEvent() {}
+func (Warning) isEvent()            {}
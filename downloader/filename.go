@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+// chapterFileName returns the output directory, the file name and the series
+// folder for a chapter.
+func (j *job) chapterFileName(chapter mangadexapi.ChapterFullInfo) (string, string, string) {
+	fields := nameFields{
+		language:     j.req.Language,
+		translator:   chapter.Translator(),
+		series:       j.manga.Title("en"),
+		volume:       chapter.Volume(),
+		chapter:      chapter.Number(),
+		chapterTitle: chapter.Title(),
+		mangaId:      j.manga.ID,
+	}
+	return j.naming().resolve(j.req.OutputDir, fields, chapterFlatName(fields), j.seriesDir)
+}
+
+// mergeChaptersFileName returns the output directory, the file name and the
+// series folder for chapters merged into one file.
+func (j *job) mergeChaptersFileName(chaptersRange string) (string, string, string) {
+	fields := nameFields{
+		language:     j.req.Language,
+		translator:   j.chapters[0].Translator(),
+		series:       j.manga.Title("en"),
+		volume:       "",
+		chapter:      chaptersRange,
+		chapterTitle: j.chapters[0].Title(),
+		mangaId:      j.manga.ID,
+	}
+	flatName := fmt.Sprintf("[%s %s] %s ch. %s",
+		fields.language, fields.translator, fields.series, chaptersRange)
+	return j.naming().resolve(j.req.OutputDir, fields, flatName, j.seriesDir)
+}
+
+// volumeFileName returns the output directory, the file name and the series
+// folder for a volume, chapter is the first chapter selected in the volume.
+func (j *job) volumeFileName(volume, chaptersRange string, chapter mangadexapi.Chapter) (string, string, string) {
+	fields := nameFields{
+		language:     j.req.Language,
+		translator:   chapter.GetTranslator(),
+		series:       j.manga.Title("en"),
+		volume:       volume,
+		chapter:      chaptersRange,
+		chapterTitle: chapter.Title(),
+		mangaId:      j.manga.ID,
+	}
+	return j.naming().resolve(j.req.OutputDir, fields, volumeFlatName(fields), j.seriesDir)
+}
+
+// seriesDir looks up the series folder once per job, so a reused folder is
+// reported once.
+func (j *job) seriesDir(parent, rendered string, f nameFields) string {
+	key := filepath.Join(parent, rendered)
+	if name, ok := j.seriesDirs[key]; ok {
+		return name
+	}
+	name, isReused := lookupSeriesDir(parent, rendered, f.mangaId)
+	if isReused {
+		j.d.emit(SeriesFolderReused{Folder: name, Series: f.series})
+	}
+	j.seriesDirs[key] = name
+	return name
+}
+
+func (j *job) naming() Naming {
+	return Naming{Layout: j.req.Layout, FileNameTemplate: j.req.FileNameTemplate}
+}
+
+// Naming names files built from downloaded files, e.g. by merge and split,
+// the same way downloads are named.
+type Naming struct {
+	Layout Layout
+	// FileNameTemplate has fields %1 language, %2 translator, %3 manga title,
+	// %4 volume, %5 chapter or range and %6 chapter title.
+	FileNameTemplate string
+}
+
+// ChapterFile returns the directory inside outputDir and the file name of a
+// chapter file with metadata m.
+func (n Naming) ChapterFile(outputDir string, m metadata.Metadata) (string, string) {
+	fields := metadataNameFields(m)
+	dir, name, _ := n.resolve(outputDir, fields, chapterFlatName(fields), findSeriesDir)
+	return dir, name
+}
+
+// VolumeFile returns the directory inside outputDir and the file name of
+// chapters of a volume merged into one file, m is metadata of the first
+// chapter.
+func (n Naming) VolumeFile(outputDir string, m metadata.Metadata, chaptersRange string) (string, string) {
+	fields := metadataNameFields(m)
+	fields.chapter = chaptersRange
+	dir, name, _ := n.resolve(outputDir, fields, volumeFlatName(fields), findSeriesDir)
+	return dir, name
+}
+
+// resolve renders the file name template or the layout for fields,
+// flatName is used without both.
+func (n Naming) resolve(outputDir string, fields nameFields, flatName string,
+	seriesDir seriesDirFunc) (string, string, string) {
+	fileName := ""
+	if n.FileNameTemplate != "" {
+		fileName = formatFileNameTemplate(n.FileNameTemplate, fields.templateList())
+	} else if n.Layout.isFlat() {
+		fileName = flatName
+	}
+	return n.Layout.resolve(outputDir, fields, fileName, seriesDir)
+}
+
+func metadataNameFields(m metadata.Metadata) nameFields {
+	return nameFields{
+		language:     m.CI.LanguageISO,
+		translator:   m.CI.Translator,
+		series:       m.CI.Series,
+		volume:       m.CI.Volume,
+		chapter:      m.CI.Number,
+		chapterTitle: m.CI.Title,
+		mangaId:      m.SourceIDs().Manga,
+	}
+}
+
+func chapterFlatName(f nameFields) string {
+	return fmt.Sprintf("[%s %s] %s vol. %s ch. %s", f.language, f.translator, f.series, f.volume, f.chapter)
+}
+
+func volumeFlatName(f nameFields) string {
+	return fmt.Sprintf("[%s] %s | vol. %s | ch. %s", f.language, f.series, f.volume, f.chapter)
+}
+
+func formatFileNameTemplate(template string, fields []string) string {
+	fileName := template
+	for i := len(fields); i > 0; i-- {
+		fileName = strings.ReplaceAll(fileName, "%"+strconv.Itoa(i), fields[i-1])
+	}
+	return strings.TrimSpace(fileName)
+}
@@ -0,0 +1,306 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	LAYOUT_GENERIC = "generic"
+	LAYOUT_KOMGA   = "komga"
+	LAYOUT_KAVITA  = "kavita"
+
+	// seriesMarkerFile keeps the MangaDex ID of the series inside its folder,
+	// so renamed titles keep landing in the same folder.
+	seriesMarkerFile = ".mdx-series.json"
+)
+
+// layoutPresets are directory layouts understood by media-server libraries.
+var layoutPresets = map[string]string{
+	LAYOUT_GENERIC: "{series}/Volume {volume:2}/{series} v{volume:2} c{chapter:3}",
+	LAYOUT_KOMGA:   "{series}/{series} v{volume:2} c{chapter:3}",
+	LAYOUT_KAVITA:  "{series}/{series} Vol.{volume:2} Ch.{chapter:3}",
+}
+
+var (
+	ErrLayoutEmptyPart    = errors.New("layout contains an empty path part")
+	ErrLayoutUnknownField = errors.New("layout contains an unknown field")
+
+	layoutFieldRe = regexp.MustCompile(`\{([a-z]+)(?::(\d+))?\}`)
+
+	layoutFieldNames = []string{"series", "volume", "chapter", "language", "group", "title", "id"}
+)
+
+// nameFields are the values available to file name templates and layouts.
+type nameFields struct {
+	language     string
+	translator   string
+	series       string
+	volume       string
+	chapter      string
+	chapterTitle string
+	mangaId      string
+}
+
+// templateList returns fields in the order of the %1..%6 file name template.
+func (f nameFields) templateList() []string {
+	return []string{
+		f.language,
+		f.translator,
+		f.series,
+		f.volume,
+		f.chapter,
+		f.chapterTitle,
+	}
+}
+
+func (f nameFields) value(name string) string {
+	switch name {
+	case "series":
+		return f.series
+	case "volume":
+		return f.volume
+	case "chapter":
+		return f.chapter
+	case "language":
+		return f.language
+	case "group":
+		return f.translator
+	case "title":
+		return f.chapterTitle
+	case "id":
+		return f.mangaId
+	}
+	return ""
+}
+
+// Layout is a parsed directory layout template. Parts are separated by "/",
+// the last part is the file name without extension.
+type Layout struct {
+	parts []string
+}
+
+// LayoutHelp describes the --layout flag values.
+func LayoutHelp() string {
+	return fmt.Sprintf("directory layout: %s, %s, %s or a custom template with fields {%s}, e.g. \"{series}/Volume {volume:2}/{series} c{chapter:3}\"",
+		LAYOUT_GENERIC, LAYOUT_KOMGA, LAYOUT_KAVITA, strings.Join(layoutFieldNames, "} {"))
+}
+
+// ParseLayout parses a preset name or a custom layout template.
+// An empty value means a flat output directory.
+func ParseLayout(value string) (Layout, error) {
+	if value == "" {
+		return Layout{}, nil
+	}
+
+	template := value
+	if preset, ok := layoutPresets[strings.ToLower(value)]; ok {
+		template = preset
+	}
+
+	template = strings.ReplaceAll(template, `\`, "/")
+	parts := strings.Split(strings.Trim(template, "/"), "/")
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			return Layout{}, ErrLayoutEmptyPart
+		}
+		for _, match := range layoutFieldRe.FindAllStringSubmatch(part, -1) {
+			if !slices.Contains(layoutFieldNames, match[1]) {
+				return Layout{}, fmt.Errorf("%w: %s", ErrLayoutUnknownField, match[0])
+			}
+		}
+	}
+
+	return Layout{parts: parts}, nil
+}
+
+func (l Layout) isFlat() bool {
+	return len(l.parts) == 0
+}
+
+// resolve renders the layout for fields inside root. It returns the directory
+// for the file, the file name and the series folder, if the layout has one.
+// If fileName is not empty, it replaces the file name part of the layout. The
+// name of the series folder is looked up with seriesDir. Nothing is written
+// on disk.
+func (l Layout) resolve(root string, f nameFields, fileName string,
+	seriesDir seriesDirFunc) (string, string, string) {
+	if l.isFlat() {
+		return root, fileName, ""
+	}
+
+	dir := root
+	dirParts := l.parts[:len(l.parts)-1]
+	series := ""
+	for _, part := range dirParts {
+		rendered, isEmpty := renderLayoutPart(part, f)
+		if isEmpty {
+			continue
+		}
+		rendered = safeLayoutName(rendered)
+
+		if series == "" && strings.Contains(part, "{series}") && f.mangaId != "" {
+			rendered = seriesDir(dir, rendered, f)
+			dir = filepath.Join(dir, rendered)
+			series = dir
+			continue
+		}
+		dir = filepath.Join(dir, rendered)
+	}
+
+	if fileName == "" {
+		fileName, _ = renderLayoutPart(l.parts[len(l.parts)-1], f)
+	}
+
+	return dir, fileName, series
+}
+
+// renderLayoutPart substitutes fields in part. An empty field removes itself
+// together with the word glued to it, so " v{volume:2}" disappears when the
+// chapter has no volume. isEmpty reports whether every field in part was empty.
+func renderLayoutPart(part string, f nameFields) (string, bool) {
+	matches := layoutFieldRe.FindAllStringSubmatchIndex(part, -1)
+	if len(matches) == 0 {
+		return part, false
+	}
+
+	var b strings.Builder
+	last := 0
+	isEmpty := true
+	for _, m := range matches {
+		literal := part[last:m[0]]
+		name := part[m[2]:m[3]]
+		width := 0
+		if m[4] != -1 {
+			width, _ = strconv.Atoi(part[m[4]:m[5]])
+		}
+		value := padLayoutValue(f.value(name), width)
+
+		if value == "" {
+			literal = strings.TrimRightFunc(literal, func(r rune) bool {
+				return !unicode.IsSpace(r)
+			})
+			literal = strings.TrimRightFunc(literal, unicode.IsSpace)
+		} else {
+			isEmpty = false
+		}
+
+		b.WriteString(literal)
+		b.WriteString(value)
+		last = m[1]
+	}
+	b.WriteString(part[last:])
+
+	return strings.Join(strings.Fields(b.String()), " "), isEmpty
+}
+
+// padLayoutValue pads the integer part of a number or of each side of a
+// range with zeros, e.g. "1.5" -> "001.5" and "1-3" -> "001-003".
+func padLayoutValue(value string, width int) string {
+	if width == 0 || value == "" {
+		return value
+	}
+
+	sides := strings.Split(value, "-")
+	for i, side := range sides {
+		integer, fraction, _ := strings.Cut(side, ".")
+		if _, err := strconv.Atoi(integer); err != nil {
+			return value
+		}
+		for len(integer) < width {
+			integer = "0" + integer
+		}
+		sides[i] = integer
+		if fraction != "" {
+			sides[i] += "." + fraction
+		}
+	}
+	return strings.Join(sides, "-")
+}
+
+func safeLayoutName(name string) string {
+	for _, c := range []string{"/", `\`, "<", ">", ":", `"`, "?", "*"} {
+		name = strings.ReplaceAll(name, c, "_")
+	}
+	name = strings.ReplaceAll(name, "|", "-")
+	return strings.TrimRight(strings.TrimSpace(name), ".")
+}
+
+type seriesMarker struct {
+	MangaId string `json:"mangadexId"`
+	Title   string `json:"title"`
+	Link    string `json:"link"`
+}
+
+func readSeriesMarker(dir string) (seriesMarker, bool) {
+	content, err := os.ReadFile(filepath.Join(dir, seriesMarkerFile))
+	if err != nil {
+		return seriesMarker{}, false
+	}
+	marker := seriesMarker{}
+	if err := json.Unmarshal(content, &marker); err != nil {
+		return seriesMarker{}, false
+	}
+	return marker, marker.MangaId != ""
+}
+
+func writeSeriesMarker(dir string, f nameFields) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(seriesMarker{
+		MangaId: f.mangaId,
+		Title:   f.series,
+		Link:    "https://mangadex.org/title/" + f.mangaId,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, seriesMarkerFile), content, 0644)
+}
+
+// seriesDirFunc returns the folder name for the series rendered inside parent.
+type seriesDirFunc func(parent, rendered string, f nameFields) string
+
+// findSeriesDir is a seriesDirFunc that doesn't report reused folders.
+func findSeriesDir(parent, rendered string, f nameFields) string {
+	name, _ := lookupSeriesDir(parent, rendered, f.mangaId)
+	return name
+}
+
+// lookupSeriesDir returns the folder name for the series inside parent.
+// A folder that already holds the same MangaDex ID wins over the rendered
+// name, so a renamed title keeps using its old folder, isReused reports it.
+// Only existing markers are read.
+func lookupSeriesDir(parent, rendered, mangaId string) (string, bool) {
+	if marker, ok := readSeriesMarker(filepath.Join(parent, rendered)); ok {
+		if marker.MangaId == mangaId {
+			return rendered, false
+		}
+		// another series already uses this title
+		rendered = fmt.Sprintf("%s (%s)", rendered, strings.Split(mangaId, "-")[0])
+	}
+
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return rendered, false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == rendered {
+			continue
+		}
+		marker, ok := readSeriesMarker(filepath.Join(parent, entry.Name()))
+		if ok && marker.MangaId == mangaId {
+			return entry.Name(), true
+		}
+	}
+	return rendered, false
+}
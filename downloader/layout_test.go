@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLayoutResolve(t *testing.T) {
+	fields := nameFields{
+		language:   "en",
+		translator: "Group",
+		series:     "Some: Title",
+		volume:     "1",
+		chapter:    "5.5",
+	}
+	noVolume := fields
+	noVolume.volume = ""
+	merged := fields
+	merged.chapter = "1-12"
+
+	tests := []struct {
+		name     string
+		layout   string
+		fields   nameFields
+		fileName string
+		wantDir  string
+		wantName string
+	}{
+		{
+			name:     "Generic preset",
+			layout:   LAYOUT_GENERIC,
+			fields:   fields,
+			wantDir:  filepath.Join("root", "Some_ Title", "Volume 01"),
+			wantName: "Some: Title v01 c005.5",
+		},
+		{
+			name:     "Generic preset without volume",
+			layout:   LAYOUT_GENERIC,
+			fields:   noVolume,
+			wantDir:  filepath.Join("root", "Some_ Title"),
+			wantName: "Some: Title c005.5",
+		},
+		{
+			name:     "Kavita preset with chapter range",
+			layout:   LAYOUT_KAVITA,
+			fields:   merged,
+			wantDir:  filepath.Join("root", "Some_ Title"),
+			wantName: "Some: Title Vol.01 Ch.001-012",
+		},
+		{
+			name:     "Custom template with file name override",
+			layout:   "{language}/{group}/x",
+			fields:   fields,
+			fileName: "custom",
+			wantDir:  filepath.Join("root", "en", "Group"),
+			wantName: "custom",
+		},
+		{
+			name:     "Flat layout",
+			layout:   "",
+			fields:   fields,
+			fileName: "flat",
+			wantDir:  "root",
+			wantName: "flat",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := ParseLayout(tt.layout)
+			if err != nil {
+				t.Fatalf("Test Case: %s. Unexpected error: %v", tt.name, err)
+			}
+			dir, name, _ := l.resolve("root", tt.fields, tt.fileName, findSeriesDir)
+			if dir != tt.wantDir || name != tt.wantName {
+				t.Errorf("Test Case: %s. Expected %q %q, but got %q %q",
+					tt.name, tt.wantDir, tt.wantName, dir, name)
+			}
+		})
+	}
+}
+
+func TestSeriesDirFollowsRename(t *testing.T) {
+	root := t.TempDir()
+	fields := nameFields{series: "Old Title", volume: "1", chapter: "1", mangaId: "a3f91d0b-02f5"}
+
+	l, err := ParseLayout(LAYOUT_KOMGA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, _, seriesDir := l.resolve(root, fields, "", findSeriesDir)
+	if err := writeSeriesMarker(seriesDir, fields); err != nil {
+		t.Fatal(err)
+	}
+	fields.series = "New Title"
+	newDir, _, _ := l.resolve(root, fields, "", findSeriesDir)
+	if oldDir != newDir {
+		t.Errorf("Expected renamed series in %q, but got %q", oldDir, newDir)
+	}
+
+	fields.mangaId = "b4c6e1aa-11f0"
+	fields.series = "Old Title"
+	otherDir, _, _ := l.resolve(root, fields, "", findSeriesDir)
+	if otherDir == oldDir {
+		t.Errorf("Expected another series to get its own folder, but got %q", otherDir)
+	}
+}
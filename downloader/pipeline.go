@@ -0,0 +1,166 @@
+package downloader
+
+import (
+	"image"
+	"runtime"
+	"sync"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+)
+
+type processedPage struct {
+	pages []imaging.Page
+	// img is a decoded webtoon page to be added to the strip
+	img       image.Image
+	isDropped bool
+	err       error
+}
+
+// PagePipeline processes downloaded pages in parallel with the downloads and
+// adds them to the container in the download order. In webtoon mode pages
+// are decoded in parallel and sliced again in order.
+type PagePipeline struct {
+	container filekit.Container
+	opts      imaging.Options
+	strip     *imaging.Strip
+	dropped   int
+	// queue keeps results in the page order, its size limits the number of
+	// pages held in memory
+	queue   chan chan processedPage
+	workers chan struct{}
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func NewPagePipeline(container filekit.Container, opts imaging.Options) *PagePipeline {
+	workers := runtime.NumCPU()
+	pp := &PagePipeline{
+		container: container,
+		opts:      opts,
+		queue:     make(chan chan processedPage, 2*workers),
+		workers:   make(chan struct{}, workers),
+		done:      make(chan struct{}),
+	}
+	if opts.Webtoon {
+		pp.strip = imaging.NewStrip(opts)
+	}
+	go pp.run()
+	return pp
+}
+
+// Add queues a page. It returns the error of an earlier page, then the
+// download should stop.
+func (pp *PagePipeline) Add(ext string, data []byte) error {
+	if err := pp.failure(); err != nil {
+		return err
+	}
+
+	result := make(chan processedPage, 1)
+	pp.queue <- result
+	go func() {
+		pp.workers <- struct{}{}
+		defer func() { <-pp.workers }()
+
+		if pp.strip != nil {
+			if img, err := imaging.Decode(data); err == nil {
+				if pp.opts.IsBlocked(img) {
+					img = nil
+				}
+				result <- processedPage{img: img, isDropped: img == nil}
+				return
+			}
+			// pages in unknown formats are stored as is
+			result <- processedPage{pages: []imaging.Page{{Data: data, Ext: ext}}}
+			return
+		}
+
+		pages, err := imaging.Process(data, ext, pp.opts)
+		result <- processedPage{pages: pages, isDropped: err == nil && len(pages) == 0, err: err}
+	}()
+	return nil
+}
+
+// Wait adds the remaining pages and returns the first error. The number of
+// dropped pages is final after it.
+func (pp *PagePipeline) Wait() error {
+	close(pp.queue)
+	<-pp.done
+	return pp.failure()
+}
+
+func (pp *PagePipeline) run() {
+	defer close(pp.done)
+	for result := range pp.queue {
+		page := <-result
+		if pp.failure() != nil {
+			continue
+		}
+		pp.setFailure(pp.addPage(page))
+	}
+	if pp.strip != nil && pp.failure() == nil {
+		pp.setFailure(pp.addSlices(pp.strip.Flush()))
+	}
+}
+
+func (pp *PagePipeline) addPage(page processedPage) error {
+	if page.err != nil {
+		return page.err
+	}
+	if page.isDropped {
+		pp.dropped++
+		return nil
+	}
+	if page.img != nil {
+		return pp.addSlices(pp.strip.Add(page.img))
+	}
+	if pp.strip != nil {
+		if err := pp.addSlices(pp.strip.Flush()); err != nil {
+			return err
+		}
+	}
+	for _, p := range page.pages {
+		if err := pp.container.AddFile(p.Ext, p.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSlices encodes webtoon pages and adds them to the container.
+func (pp *PagePipeline) addSlices(slices []image.Image) error {
+	for _, slice := range slices {
+		page, err := imaging.EncodePage(slice, pp.opts)
+		if err != nil {
+			return err
+		}
+		if err := pp.container.AddFile(page.Ext, page.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of pages dropped by the blocklist.
+func (pp *PagePipeline) Dropped() int {
+	return pp.dropped
+}
+
+func (pp *PagePipeline) setFailure(err error) {
+	if err == nil {
+		return
+	}
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if pp.err == nil {
+		pp.err = err
+	}
+}
+
+func (pp *PagePipeline) failure() error {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return pp.err
+}
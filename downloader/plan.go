@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"time"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+// Average sizes of MangaDex pages, they are used to estimate the size of
+// downloaded files before downloading.
+const (
+	averagePageSize           = 600 * 1024
+	averageCompressedPageSize = 200 * 1024
+)
+
+// Plan describes files a download will write.
+type Plan struct {
+	MangaID  string        `json:"mangaId"`
+	Title    string        `json:"title"`
+	Language string        `json:"language"`
+	Format   string        `json:"format"`
+	Files    []PlannedFile `json:"files"`
+	Skipped  []PlannedSkip `json:"skipped"`
+	Pages    int           `json:"pages"`
+	// EstimatedSize is in bytes.
+	EstimatedSize int64 `json:"estimatedSize"`
+}
+
+type PlannedFile struct {
+	// Path is the target path before the on-exists policy is applied.
+	Path     string           `json:"path"`
+	Chapters []PlannedChapter `json:"chapters"`
+	Pages    int              `json:"pages"`
+	// EstimatedSize is in bytes.
+	EstimatedSize int64 `json:"estimatedSize"`
+	// Exists is set if the file is already there.
+	Exists bool `json:"exists"`
+}
+
+type PlannedChapter struct {
+	ID       string `json:"id"`
+	Volume   string `json:"volume,omitempty"`
+	Number   string `json:"number,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Language string `json:"language"`
+	Pages    int    `json:"pages"`
+}
+
+type PlannedSkip struct {
+	ID     string `json:"id"`
+	Volume string `json:"volume,omitempty"`
+	Number string `json:"number,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Plan returns files the download of chapters of the manga would write,
+// grouped like DownloadChapters groups them. Only chapter information is
+// needed, image lists are not fetched.
+func (d *Downloader) Plan(req Request, manga mangadexapi.MangaInfo, chapters []mangadexapi.Chapter) Plan {
+	j := d.newJob(req, manga, chapters)
+	now := time.Now()
+	for _, chapter := range chapters {
+		if reason := skipReason(chapter, now); reason != "" {
+			j.result.Skipped = append(j.result.Skipped, SkippedChapter{Chapter: chapter, Reason: reason})
+			continue
+		}
+		j.chapters = append(j.chapters, mangadexapi.ChapterFullInfo{Info: chapter})
+	}
+
+	plan := Plan{
+		MangaID:  manga.ID,
+		Title:    manga.Title("en"),
+		Language: req.Language,
+		Format:   req.OutputExt,
+		Files:    []PlannedFile{},
+		Skipped:  []PlannedSkip{},
+	}
+	for _, file := range j.files() {
+		if len(file.chapters) != 0 {
+			plan.Files = append(plan.Files, j.plannedFile(file))
+		}
+	}
+	for _, file := range plan.Files {
+		plan.Pages += file.Pages
+		plan.EstimatedSize += file.EstimatedSize
+	}
+	for _, skipped := range j.result.Skipped {
+		plan.Skipped = append(plan.Skipped, PlannedSkip{
+			ID:     skipped.Chapter.ID,
+			Volume: skipped.Chapter.Volume(),
+			Number: skipped.Chapter.Number(),
+			Reason: skipped.Reason,
+		})
+	}
+	return plan
+}
+
+func (j *job) plannedFile(file outputFile) PlannedFile {
+	pageSize := int64(averagePageSize)
+	if j.req.IsJpg {
+		pageSize = averageCompressedPageSize
+	}
+
+	planned := PlannedFile{
+		Path:     filekit.OutputPath(file.dir, file.name, j.req.OutputExt),
+		Chapters: []PlannedChapter{},
+		Exists:   filekit.OutputExists(file.dir, file.name, j.req.OutputExt),
+	}
+	for _, chapter := range file.chapters {
+		planned.Chapters = append(planned.Chapters, PlannedChapter{
+			ID:       chapter.Info.ID,
+			Volume:   chapter.Volume(),
+			Number:   chapter.Number(),
+			Title:    chapter.Title(),
+			Group:    chapter.Translator(),
+			Language: chapter.Language(),
+			Pages:    chapter.PagesCount(),
+		})
+		planned.Pages += chapter.PagesCount()
+	}
+	planned.EstimatedSize = int64(planned.Pages) * pageSize
+	return planned
+}
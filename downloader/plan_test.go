@@ -0,0 +1,134 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+func TestPlan(t *testing.T) {
+	chapter := func(id, number string, pages int) mangadexapi.Chapter {
+		return mangadexapi.Chapter{ID: id,
+			Attributes: mangadexapi.ChapterAttr{Volume: "1", Chapter: number, Pages: pages}}
+	}
+	external := chapter("c3", "3", 0)
+	external.Attributes.ExternalUrl = "https://mangaplus.shueisha.co.jp/viewer/1"
+
+	tests := []struct {
+		name      string
+		isMerge   bool
+		isVolume  bool
+		wantFiles int
+	}{
+		{"Chapter files", false, false, 2},
+		{"Merged file", true, false, 1},
+		{"Merged volume", true, true, 1},
+	}
+
+	for _, tt := range tests {
+		req := Request{
+			Language:  "en",
+			OutputDir: t.TempDir(),
+			OutputExt: filekit.CBZ_EXT,
+			IsMerge:   tt.isMerge,
+			IsVolume:  tt.isVolume,
+			IsJpg:     true,
+		}
+		chapters := []mangadexapi.Chapter{chapter("c1", "1", 10), chapter("c2", "2", 20), external}
+
+		plan := New(mangadexapi.Clientapi{}, nil).Plan(req, mangadexapi.MangaInfo{}, chapters)
+		if len(plan.Files) != tt.wantFiles {
+			t.Fatalf("Test Case: %s. Expected %d files, but got %d", tt.name, tt.wantFiles, len(plan.Files))
+		}
+		if plan.Pages != 30 || plan.EstimatedSize != 30*averageCompressedPageSize {
+			t.Errorf("Test Case: %s. Expected 30 pages, but got %d pages of %d bytes",
+				tt.name, plan.Pages, plan.EstimatedSize)
+		}
+		if filepath.Ext(plan.Files[0].Path) != ".cbz" || plan.Files[0].Exists {
+			t.Errorf("Test Case: %s. Expected a new cbz file, but got %v", tt.name, plan.Files[0])
+		}
+		if len(plan.Skipped) != 1 || plan.Skipped[0].Number != "3" {
+			t.Errorf("Test Case: %s. Expected skipped chapter 3, but got %v", tt.name, plan.Skipped)
+		}
+	}
+}
+
+func TestPlanWithLayout(t *testing.T) {
+	layout, err := ParseLayout(LAYOUT_KOMGA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manga := mangadexapi.MangaInfo{ID: "a3f91d0b-02f5",
+		Attributes: mangadexapi.MangaAttrib{Title: map[string]string{"en": "New Title"}}}
+	chapters := []mangadexapi.Chapter{
+		{ID: "c1", Attributes: mangadexapi.ChapterAttr{Volume: "1", Chapter: "1", Pages: 2}},
+		{ID: "c2", Attributes: mangadexapi.ChapterAttr{Volume: "1", Chapter: "2", Pages: 2}},
+	}
+	req := Request{Language: "en", OutputDir: t.TempDir(), OutputExt: filekit.CBZ_EXT, Layout: layout}
+
+	New(fakeClient{}, nil).Plan(req, manga, chapters)
+	entries, err := os.ReadDir(req.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected the plan to leave the output directory empty, but got %d entries", len(entries))
+	}
+
+	// the series is renamed since the last download
+	oldDir := filepath.Join(req.OutputDir, "Old Title")
+	if err := writeSeriesMarker(oldDir, nameFields{series: "Old Title", mangaId: manga.ID}); err != nil {
+		t.Fatal(err)
+	}
+	reused := 0
+	plan := New(fakeClient{}, func(event Event) {
+		if _, ok := event.(SeriesFolderReused); ok {
+			reused++
+		}
+	}).Plan(req, manga, chapters)
+	if reused != 1 {
+		t.Errorf("Expected the reused series folder to be reported once, but got %d times", reused)
+	}
+	for _, file := range plan.Files {
+		if filepath.Dir(file.Path) != oldDir {
+			t.Errorf("Expected %s in %s, but got %s", file.Path, oldDir, filepath.Dir(file.Path))
+		}
+	}
+}
+
+func TestDownloadWritesSeriesMarker(t *testing.T) {
+	page := new(bytes.Buffer)
+	if err := png.Encode(page, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+	layout, err := ParseLayout(LAYOUT_KOMGA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manga := mangadexapi.MangaInfo{ID: "a3f91d0b-02f5",
+		Attributes: mangadexapi.MangaAttrib{Title: map[string]string{"en": "Title"}}}
+	client := fakeClient{
+		manga:    manga,
+		chapters: []mangadexapi.Chapter{{ID: "c1", Attributes: mangadexapi.ChapterAttr{Chapter: "1"}}},
+		image: func(ctx context.Context, imageFilename string) ([]byte, error) {
+			return page.Bytes(), nil
+		},
+	}
+	req := Request{MangaID: manga.ID, Language: "en", IsAll: true, OutputDir: t.TempDir(),
+		OutputExt: filekit.CBZ_EXT, Layout: layout}
+
+	if _, err := New(client, nil).Download(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	marker, ok := readSeriesMarker(filepath.Join(req.OutputDir, "Title"))
+	if !ok || marker.MangaId != manga.ID {
+		t.Errorf("Expected the series marker of %s, but got %v", manga.ID, marker)
+	}
+}
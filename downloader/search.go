@@ -0,0 +1,66 @@
+package downloader
+
+import (
+	"context"
+
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+// SearchClient is the part of the MangaDex API used by GetInfo and Find,
+// mangadexapi.Clientapi implements it.
+type SearchClient interface {
+	GetMangaInfo(mangaID string) (mangadexapi.MangaInfoResponse, error)
+	GetRandomMangaInfo() (mangadexapi.MangaInfoResponse, error)
+	Find(title string, limit, offset int, isDoujinshiAllow bool) (mangadexapi.ResponseMangaList, error)
+}
+
+// FindRequest describes a search of manga by title.
+type FindRequest struct {
+	Title string
+	// Limit is the number of results of a page, Offset is the number of
+	// results skipped. IsAll fetches all pages from Offset.
+	Limit            int
+	Offset           int
+	IsAll            bool
+	IsDoujinshiAllow bool
+}
+
+// GetInfo returns information about the manga.
+func GetInfo(client SearchClient, mangaID string) (mangadexapi.MangaInfo, error) {
+	resp, err := client.GetMangaInfo(mangaID)
+	if err != nil {
+		return mangadexapi.MangaInfo{}, err
+	}
+	return resp.MangaInfo(), nil
+}
+
+// GetRandomInfo returns information about a random manga.
+func GetRandomInfo(client SearchClient) (mangadexapi.MangaInfo, error) {
+	resp, err := client.GetRandomMangaInfo()
+	if err != nil {
+		return mangadexapi.MangaInfo{}, err
+	}
+	return resp.MangaInfo(), nil
+}
+
+// Find returns manga found by req and the total number of matches. With
+// req.IsAll pages are fetched until ctx is done.
+func Find(ctx context.Context, client SearchClient, req FindRequest) ([]mangadexapi.MangaInfo, int, error) {
+	resp, err := client.Find(req.Title, req.Limit, req.Offset, req.IsDoujinshiAllow)
+	if err != nil {
+		return nil, 0, err
+	}
+	found := resp.List()
+
+	for offset := req.Offset + req.Limit; req.IsAll && offset < resp.Total; offset += req.Limit {
+		if err := ctx.Err(); err != nil {
+			return found, resp.Total, err
+		}
+		page, err := client.Find(req.Title, req.Limit, offset, req.IsDoujinshiAllow)
+		if err != nil {
+			return found, resp.Total, err
+		}
+		found = append(found, page.List()...)
+	}
+	return found, resp.Total, nil
+}
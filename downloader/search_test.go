@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+// fakeSearchClient finds total manga with IDs "0", "1" and so on.
+type fakeSearchClient struct {
+	total int
+	// failOffset fails the search of the page at the offset
+	failOffset int
+}
+
+var errTestSearch = errors.New("search failed")
+
+func (c fakeSearchClient) GetMangaInfo(mangaID string) (mangadexapi.MangaInfoResponse, error) {
+	return mangadexapi.MangaInfoResponse{Data: mangadexapi.MangaInfo{ID: mangaID}}, nil
+}
+
+func (c fakeSearchClient) GetRandomMangaInfo() (mangadexapi.MangaInfoResponse, error) {
+	return mangadexapi.MangaInfoResponse{Data: mangadexapi.MangaInfo{ID: "random"}}, nil
+}
+
+func (c fakeSearchClient) Find(title string, limit, offset int,
+	isDoujinshiAllow bool) (mangadexapi.ResponseMangaList, error) {
+	if offset != 0 && offset == c.failOffset {
+		return mangadexapi.ResponseMangaList{}, errTestSearch
+	}
+	list := mangadexapi.ResponseMangaList{Limit: limit, Offset: offset, Total: c.total}
+	for i := offset; i < min(offset+limit, c.total); i++ {
+		list.Data = append(list.Data, mangadexapi.MangaInfo{ID: strconv.Itoa(i)})
+	}
+	return list, nil
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name      string
+		client    fakeSearchClient
+		req       FindRequest
+		want      []string
+		wantTotal int
+		wantErr   error
+	}{
+		{"First page", fakeSearchClient{total: 5}, FindRequest{Limit: 2}, []string{"0", "1"}, 5, nil},
+		{"Offset", fakeSearchClient{total: 5}, FindRequest{Limit: 2, Offset: 2}, []string{"2", "3"}, 5, nil},
+		{"All pages", fakeSearchClient{total: 5}, FindRequest{Limit: 2, IsAll: true},
+			[]string{"0", "1", "2", "3", "4"}, 5, nil},
+		{"Failed page", fakeSearchClient{total: 5, failOffset: 4}, FindRequest{Limit: 2, IsAll: true},
+			[]string{"0", "1", "2", "3"}, 5, errTestSearch},
+		{"Nothing found", fakeSearchClient{}, FindRequest{Limit: 2, IsAll: true}, []string{}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		found, total, err := Find(context.Background(), tt.client, tt.req)
+		ids := []string{}
+		for _, m := range found {
+			ids = append(ids, m.ID)
+		}
+		if !slices.Equal(ids, tt.want) || total != tt.wantTotal || !errors.Is(err, tt.wantErr) {
+			t.Errorf("Test Case: %s. Expected %v of %d (%v), but got %v of %d (%v)",
+				tt.name, tt.want, tt.wantTotal, tt.wantErr, ids, total, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	found, _, err := Find(ctx, fakeSearchClient{total: 5}, FindRequest{Limit: 2, IsAll: true})
+	if !errors.Is(err, context.Canceled) || len(found) != 2 {
+		t.Errorf("Test Case: Canceled. Expected the first page and %v, but got %d manga (%v)",
+			context.Canceled, len(found), err)
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	manga, err := GetInfo(fakeSearchClient{}, "m1")
+	if err != nil || manga.ID != "m1" {
+		t.Errorf("Expected manga m1, but got %q (%v)", manga.ID, err)
+	}
+	manga, err = GetRandomInfo(fakeSearchClient{})
+	if err != nil || manga.ID != "random" {
+		t.Errorf("Expected a random manga, but got %q (%v)", manga.ID, err)
+	}
+}
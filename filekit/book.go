@@ -0,0 +1,637 @@
+package filekit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// ErrBookNotSupported is returned when pages of a file can't be read back.
+var ErrBookNotSupported = errors.New("file format can't be read")
+
+const comicInfoFileName = "ComicInfo.xml"
+
+// pageExtensions are extensions of page images read from files.
+var pageExtensions = []string{"jpg", "jpeg", "png", "gif", "webp"}
+
+// Book is a file written by a container opened for reading, e.g. to convert
+// it into another format. Pages are read on demand.
+type Book struct {
+	// Format is the container extension of the file.
+	Format   string
+	Metadata metadata.Metadata
+	// Chapters of merged files, other files have a single chapter without
+	// a boundary.
+	Chapters []BookChapter
+	closer   io.Closer
+}
+
+// BookChapter is a run of pages of the same chapter.
+type BookChapter struct {
+	Chapter Chapter
+	// HasBoundary is true for chapters of merged files, their start is
+	// marked with Container.BeginChapter.
+	HasBoundary bool
+	Pages       []BookPage
+}
+
+// BookPage is a page image inside a book.
+type BookPage struct {
+	// Name is the path of the page inside the book.
+	Name string
+	Ext  string
+	read func() ([]byte, error)
+}
+
+// Read returns the page image.
+func (p BookPage) Read() ([]byte, error) {
+	return p.read()
+}
+
+// PageCount returns the number of pages in all chapters.
+func (b *Book) PageCount() int {
+	count := 0
+	for _, c := range b.Chapters {
+		count += len(c.Pages)
+	}
+	return count
+}
+
+// ChapterRange returns the range of chapter numbers of merged books, e.g.
+// "1-5", or an empty string for a single chapter.
+func (b *Book) ChapterRange() string {
+	numbers := []string{}
+	for _, c := range b.Chapters {
+		if c.HasBoundary && c.Chapter.Number != "" {
+			numbers = append(numbers, c.Chapter.Number)
+		}
+	}
+	if len(numbers) < 2 {
+		return ""
+	}
+	return numbers[0] + "-" + numbers[len(numbers)-1]
+}
+
+func (b *Book) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+// OpenBook opens a cbz, zip, cbt, epub file or a directory of pages.
+func OpenBook(bookPath string) (*Book, error) {
+	info, err := os.Stat(bookPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return openDirBook(bookPath)
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(bookPath), ".")) {
+	case CBZ_EXT, ZIP_EXT:
+		return openZipBook(bookPath)
+	case CBT_EXT:
+		return openTarBook(bookPath)
+	case EPUB_EXT:
+		return openEpubBook(bookPath)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrBookNotSupported, filepath.Base(bookPath))
+}
+
+// bookEntry is a file inside a book before pages are sorted.
+type bookEntry struct {
+	name string
+	// ext is set when the name has no image extension
+	ext  string
+	read func() ([]byte, error)
+}
+
+func openZipBook(bookPath string) (*Book, error) {
+	reader, err := zip.OpenReader(bookPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []bookEntry{}
+	var comicInfo []byte
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if file.Name == comicInfoFileName {
+			if comicInfo, err = readZipFile(file); err != nil {
+				reader.Close()
+				return nil, err
+			}
+			continue
+		}
+		entries = append(entries, bookEntry{name: file.Name, read: func() ([]byte, error) {
+			return readZipFile(file)
+		}})
+	}
+
+	book := &Book{Format: CBZ_EXT, closer: reader}
+	if comicInfo == nil {
+		book.Format = ZIP_EXT
+	}
+	if err := book.readComicInfo(comicInfo, reader.Comment); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	book.Chapters = chaptersFromEntries(entries)
+	book.markChapters(book.bookmarks())
+	return book, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// openTarBook indexes offsets of pages, they are read from the file when
+// needed.
+func openTarBook(bookPath string) (*Book, error) {
+	file, err := os.Open(bookPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []bookEntry{}
+	var comicInfo []byte
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Name == comicInfoFileName {
+			if comicInfo, err = io.ReadAll(reader); err != nil {
+				file.Close()
+				return nil, err
+			}
+			continue
+		}
+		// the reader stops at the start of the entry content after a header
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		content := io.NewSectionReader(file, offset, header.Size)
+		entries = append(entries, bookEntry{name: header.Name, read: func() ([]byte, error) {
+			return io.ReadAll(io.NewSectionReader(content, 0, content.Size()))
+		}})
+	}
+
+	book := &Book{Format: CBT_EXT, closer: file}
+	if err := book.readComicInfo(comicInfo, ""); err != nil {
+		file.Close()
+		return nil, err
+	}
+	book.Chapters = chaptersFromEntries(entries)
+	book.markChapters(book.bookmarks())
+	return book, nil
+}
+
+// IsBookDir reports whether dir is a directory of pages, e.g. written by the
+// dir container: it has ComicInfo.xml, page images or chapter folders.
+func IsBookDir(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			if chapterDirIndexRe.MatchString(name) {
+				return true
+			}
+			continue
+		}
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+		if name == comicInfoFileName || slices.Contains(pageExtensions, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func openDirBook(dir string) (*Book, error) {
+	entries := []bookEntry{}
+	var comicInfo []byte
+	err := filepath.WalkDir(dir, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if name == comicInfoFileName {
+			comicInfo, err = os.ReadFile(filePath)
+			return err
+		}
+		entries = append(entries, bookEntry{name: name, read: func() ([]byte, error) {
+			return os.ReadFile(filePath)
+		}})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	book := &Book{Format: DIR_EXT}
+	if err := book.readComicInfo(comicInfo, ""); err != nil {
+		return nil, err
+	}
+	book.Chapters = chaptersFromEntries(entries)
+	return book, nil
+}
+
+// epubPackage is the part of the EPUB package document read back.
+type epubPackage struct {
+	Metadata struct {
+		Identifiers []string `xml:"identifier"`
+		Title       string   `xml:"title"`
+		Language    string   `xml:"language"`
+		Creators    []string `xml:"creator"`
+		Description string   `xml:"description"`
+		Meta        []struct {
+			Name     string `xml:"name,attr"`
+			Content  string `xml:"content,attr"`
+			Property string `xml:"property,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Direction string `xml:"page-progression-direction,attr"`
+	} `xml:"spine"`
+}
+
+var epubRootfileRe = regexp.MustCompile(`full-path="([^"]+)"`)
+
+// openEpubBook reads image items of the manifest as pages.
+func openEpubBook(bookPath string) (*Book, error) {
+	reader, err := zip.OpenReader(bookPath)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	pkg, pkgPath, err := readEpubPackage(files)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	entries := []bookEntry{}
+	for _, item := range pkg.Manifest {
+		ext, ok := imageExtensions[item.MediaType]
+		if !ok {
+			continue
+		}
+		file, ok := files[path.Join(path.Dir(pkgPath), item.Href)]
+		if !ok {
+			continue
+		}
+		entries = append(entries, bookEntry{name: item.Href, ext: ext, read: func() ([]byte, error) {
+			return readZipFile(file)
+		}})
+	}
+
+	book := &Book{Format: EPUB_EXT, closer: reader}
+	book.Metadata = metadataFromEpub(pkg)
+	book.Chapters = chaptersFromEntries(entries)
+	book.markChapters(readEpubChapters(files, pkg, pkgPath))
+	return book, nil
+}
+
+// epubNcx is the table of contents of EPUB 2, fixed-layout books keep it
+// for old readers.
+type epubNcx struct {
+	NavPoints []struct {
+		Label   string `xml:"navLabel>text"`
+		Content struct {
+			Src string `xml:"src,attr"`
+		} `xml:"content"`
+	} `xml:"navMap>navPoint"`
+}
+
+var epubPageRe = regexp.MustCompile(`page-(\d+)\.xhtml`)
+
+// readEpubChapters returns chapter starts from the table of contents of
+// fixed-layout books. A single entry is the book title, not a chapter.
+func readEpubChapters(files map[string]*zip.File, pkg epubPackage, pkgPath string) map[int]string {
+	marks := map[int]string{}
+	for _, item := range pkg.Manifest {
+		if item.MediaType != "application/x-dtbncx+xml" {
+			continue
+		}
+		file, ok := files[path.Join(path.Dir(pkgPath), item.Href)]
+		if !ok {
+			return marks
+		}
+		content, err := readZipFile(file)
+		if err != nil {
+			return marks
+		}
+		ncx := epubNcx{}
+		if err := xml.Unmarshal(content, &ncx); err != nil || len(ncx.NavPoints) < 2 {
+			return marks
+		}
+		for _, point := range ncx.NavPoints {
+			match := epubPageRe.FindStringSubmatch(point.Content.Src)
+			if match == nil {
+				continue
+			}
+			page, _ := strconv.Atoi(match[1])
+			marks[page-1] = point.Label
+		}
+	}
+	return marks
+}
+
+func readEpubPackage(files map[string]*zip.File) (epubPackage, string, error) {
+	pkg := epubPackage{}
+	container, ok := files["META-INF/container.xml"]
+	if !ok {
+		return pkg, "", fmt.Errorf("%w: no META-INF/container.xml in epub", ErrBookNotSupported)
+	}
+	content, err := readZipFile(container)
+	if err != nil {
+		return pkg, "", err
+	}
+	match := epubRootfileRe.FindSubmatch(content)
+	if match == nil {
+		return pkg, "", fmt.Errorf("%w: no package document in epub", ErrBookNotSupported)
+	}
+	pkgPath := string(match[1])
+	pkgFile, ok := files[pkgPath]
+	if !ok {
+		return pkg, "", fmt.Errorf("%w: no %s in epub", ErrBookNotSupported, pkgPath)
+	}
+	content, err = readZipFile(pkgFile)
+	if err != nil {
+		return pkg, "", err
+	}
+	if err := xml.Unmarshal(content, &pkg); err != nil {
+		return pkg, "", err
+	}
+	return pkg, pkgPath, nil
+}
+
+// imageExtensions maps media types of images to file extensions.
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+func metadataFromEpub(pkg epubPackage) metadata.Metadata {
+	ci := metadata.ComicInfoMetadata{
+		XMLName:     xml.Name{Local: "ComicInfo"},
+		Series:      pkg.Metadata.Title,
+		Summary:     pkg.Metadata.Description,
+		Writer:      strings.Join(pkg.Metadata.Creators, ", "),
+		LanguageISO: pkg.Metadata.Language,
+		Manga:       metadata.MANGA_NO,
+	}
+	if pkg.Spine.Direction == "rtl" {
+		ci.Manga = metadata.MANGA_RIGHT_TO_LEFT
+	}
+	for _, meta := range pkg.Metadata.Meta {
+		switch meta.Property {
+		case "belongs-to-collection":
+			ci.Series = strings.TrimSpace(meta.Value)
+		case "group-position":
+			ci.Number = strings.TrimSpace(meta.Value)
+		}
+	}
+	m := metadataFromComicInfo(ci)
+	m.SetSourceIDs(metadata.ParseSourceIDs(strings.Join(pkg.Metadata.Identifiers, " ")))
+	return m
+}
+
+// readComicInfo sets metadata from ComicInfo.xml and the ComicBookInfo
+// comment. Missing ComicBookInfo fields are taken from ComicInfo.
+func (b *Book) readComicInfo(comicInfo []byte, comment string) error {
+	ci := metadata.ComicInfoMetadata{XMLName: xml.Name{Local: "ComicInfo"}}
+	if comicInfo != nil {
+		if err := xml.Unmarshal(comicInfo, &ci); err != nil {
+			return fmt.Errorf("malformed %s: %w", comicInfoFileName, err)
+		}
+	}
+	b.Metadata = metadataFromComicInfo(ci)
+
+	if comment != "" {
+		cbi := metadata.ComicBookMetadata{}
+		if err := json.Unmarshal([]byte(comment), &cbi); err == nil {
+			b.Metadata.CBI = cbi
+		}
+	}
+	return nil
+}
+
+func metadataFromComicInfo(ci metadata.ComicInfoMetadata) metadata.Metadata {
+	credits := []metadata.Credit{}
+	for _, person := range splitList(ci.Writer) {
+		credits = append(credits, metadata.Credit{Person: person, Role: "Writer"})
+	}
+	for _, person := range splitList(ci.Penciller) {
+		credits = append(credits, metadata.Credit{Person: person, Role: "Artist"})
+	}
+
+	return metadata.Metadata{
+		CBI: metadata.ComicBookMetadata{
+			ComicBookInfoData: metadata.ComicBookInfo{
+				Series:    ci.Series,
+				Title:     ci.Title,
+				Publisher: ci.Publisher,
+				Issue:     ci.Number,
+				Volume:    ci.Volume,
+				Language:  ci.LanguageISO,
+				Credits:   credits,
+				Tags:      splitList(ci.Tags),
+			},
+		},
+		CI: ci,
+		P: metadata.PlainMetadata{
+			Authors: ci.Writer,
+			Artists: ci.Penciller,
+			Tags:    ci.Tags,
+		},
+	}
+}
+
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// chaptersFromEntries sorts page images in natural order and groups them by
+// folders, merged files keep a folder per chapter.
+func chaptersFromEntries(entries []bookEntry) []BookChapter {
+	slices.SortFunc(entries, func(a, b bookEntry) int {
+		return naturalCompare(a.name, b.name)
+	})
+
+	chapters := []BookChapter{}
+	currentDir := "."
+	for _, entry := range entries {
+		ext := entry.ext
+		if ext == "" {
+			ext = strings.ToLower(strings.TrimPrefix(path.Ext(entry.name), "."))
+		}
+		if !slices.Contains(pageExtensions, ext) {
+			continue
+		}
+		if ext == "jpeg" {
+			ext = "jpg"
+		}
+
+		dir := path.Dir(entry.name)
+		if len(chapters) == 0 || dir != currentDir {
+			chapter := BookChapter{}
+			if dir != "." {
+				chapter.Chapter = parseChapterDirName(path.Base(dir))
+				chapter.HasBoundary = true
+			}
+			chapters = append(chapters, chapter)
+			currentDir = dir
+		}
+		last := &chapters[len(chapters)-1]
+		last.Pages = append(last.Pages, BookPage{Name: entry.name, Ext: ext, read: entry.read})
+	}
+
+	// pages in a single folder, e.g. images/ of epub files, are not merged
+	// chapters
+	if len(chapters) == 1 {
+		chapters[0].HasBoundary = false
+		chapters[0].Chapter = Chapter{}
+	}
+	return chapters
+}
+
+var (
+	chapterDirIndexRe = regexp.MustCompile(`^\d+ `)
+	chapterLabelRe    = regexp.MustCompile(`^(?:Vol\. (\S+))? ?(?:Ch\. (\S+))?(?:(?::| -) (.*))?$`)
+)
+
+// parseChapterDirName reverses chapterDirName, e.g. "002 Vol. 1 Ch. 5 -
+// Title" is chapter 5 of volume 1.
+func parseChapterDirName(name string) Chapter {
+	return parseChapterLabel(chapterDirIndexRe.ReplaceAllString(name, ""))
+}
+
+// parseChapterLabel reverses Chapter.Label.
+func parseChapterLabel(label string) Chapter {
+	match := chapterLabelRe.FindStringSubmatch(label)
+	if match == nil || (match[1] == "" && match[2] == "") {
+		if label == "Chapter" {
+			label = ""
+		}
+		return Chapter{Title: label}
+	}
+	return Chapter{Volume: match[1], Number: match[2], Title: match[3]}
+}
+
+// markChapters splits pages of a book without chapter folders at marks, a
+// map of page indexes to labels of chapters starting there.
+func (b *Book) markChapters(marks map[int]string) {
+	if len(marks) == 0 || len(b.Chapters) != 1 || b.Chapters[0].HasBoundary {
+		return
+	}
+
+	chapters := []BookChapter{}
+	for i, page := range b.Chapters[0].Pages {
+		if label, ok := marks[i]; ok || i == 0 {
+			chapters = append(chapters, BookChapter{Chapter: parseChapterLabel(label), HasBoundary: ok})
+		}
+		last := &chapters[len(chapters)-1]
+		last.Pages = append(last.Pages, page)
+	}
+	b.Chapters = chapters
+}
+
+// bookmarks returns chapter starts recorded in ComicInfo pages.
+func (b *Book) bookmarks() map[int]string {
+	marks := map[int]string{}
+	for _, page := range b.Metadata.CI.Pages {
+		if page.Bookmark != "" {
+			marks[page.Image] = page.Bookmark
+		}
+	}
+	return marks
+}
+
+// naturalCompare compares strings with digit runs compared as numbers, so
+// "2.jpg" comes before "10.jpg".
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			aNum, bNum := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+			if len(aNum) != len(bNum) {
+				return len(aNum) - len(bNum)
+			}
+			if c := strings.Compare(aNum, bNum); c != 0 {
+				return c
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
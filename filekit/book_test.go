@@ -0,0 +1,129 @@
+package filekit
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+func TestOpenBook(t *testing.T) {
+	m := metadata.Metadata{
+		CI: metadata.ComicInfoMetadata{Series: "Series", Number: "1", Volume: "1",
+			LanguageISO: "en", Manga: metadata.MANGA_RIGHT_TO_LEFT},
+	}
+	m.CBI.ComicBookInfoData.Series = "Series"
+
+	testCases := []struct {
+		ext    string
+		name   string
+		series string
+	}{
+		{CBZ_EXT, "out.cbz", "Series"},
+		{CBT_EXT, "out.cbt", "Series"},
+		{DIR_EXT, "out", ""},
+		{EPUB_EXT, "out.epub", "Series"},
+	}
+
+	for _, tc := range testCases {
+		dir := t.TempDir()
+		writeMergedTestContainer(t, tc.ext, dir, Options{}, m)
+
+		book, err := OpenBook(filepath.Join(dir, tc.name))
+		if err != nil {
+			t.Fatalf("Test Case: %s. Unexpected error: %v", tc.ext, err)
+		}
+		defer book.Close()
+
+		if book.PageCount() != 4 {
+			t.Errorf("Test Case: %s. Expected 4 pages, but got %d", tc.ext, book.PageCount())
+		}
+		if book.Metadata.CI.Series != tc.series {
+			t.Errorf("Test Case: %s. Expected series %q, but got %q", tc.ext, tc.series, book.Metadata.CI.Series)
+		}
+		if tc.series != "" && !book.Metadata.IsRightToLeft() {
+			t.Errorf("Test Case: %s. Expected right to left reading", tc.ext)
+		}
+
+		expected := []Chapter{{Number: "1", Volume: "1"}, {Number: "2", Volume: "1", Title: "End"}}
+		if len(book.Chapters) != len(expected) {
+			t.Fatalf("Test Case: %s. Expected %d chapters, but got %d", tc.ext, len(expected), len(book.Chapters))
+		}
+		for i, chapter := range book.Chapters {
+			if chapter.Chapter != expected[i] || !chapter.HasBoundary || len(chapter.Pages) != 2 {
+				t.Errorf("Test Case: %s. Expected chapter %v with 2 pages, but got %v with %d pages",
+					tc.ext, expected[i], chapter.Chapter, len(chapter.Pages))
+			}
+		}
+		if book.ChapterRange() != "1-2" {
+			t.Errorf("Test Case: %s. Expected chapter range 1-2, but got %q", tc.ext, book.ChapterRange())
+		}
+		// the last page is read after the others were indexed
+		if data, err := book.Chapters[1].Pages[1].Read(); err != nil || !bytes.Equal(data, testPage(t)) {
+			t.Errorf("Test Case: %s. Expected page content, but got %d bytes, error %v", tc.ext, len(data), err)
+		}
+	}
+}
+
+func TestOpenBookmarkedBook(t *testing.T) {
+	dir := t.TempDir()
+	bookPath := filepath.Join(dir, "flat.cbz")
+	file, err := os.Create(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(file)
+	for _, name := range []string{"1.png", "2.png", "10.png"} {
+		entry, _ := w.Create(name)
+		entry.Write(testPage(t))
+	}
+	ci := metadata.ComicInfoMetadata{Series: "Series", Pages: []metadata.ComicPageInfo{
+		{Image: 0, Bookmark: "Ch. 1"}, {Image: 1}, {Image: 2, Bookmark: "Vol. 2 Ch. 3: End"},
+	}}
+	content, _ := ci.MarshalComicInfo()
+	entry, _ := w.Create(comicInfoFileName)
+	entry.Write(content)
+	w.Close()
+	file.Close()
+
+	book, err := OpenBook(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer book.Close()
+
+	expected := []Chapter{{Number: "1"}, {Volume: "2", Number: "3", Title: "End"}}
+	if len(book.Chapters) != len(expected) {
+		t.Fatalf("Expected %d chapters, but got %d", len(expected), len(book.Chapters))
+	}
+	for i, chapter := range book.Chapters {
+		if chapter.Chapter != expected[i] || !chapter.HasBoundary {
+			t.Errorf("Expected chapter %v, but got %v", expected[i], chapter.Chapter)
+		}
+	}
+	if book.Chapters[0].Pages[1].Name != "2.png" {
+		t.Errorf("Expected pages in natural order, but got %s", book.Chapters[0].Pages[1].Name)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2.jpg", "10.jpg", -1},
+		{"page-010.png", "page-9.png", 1},
+		{"002 Ch. 2/01.png", "002 Ch. 2/01.png", 0},
+		{"a1", "b0", -1},
+	}
+
+	for _, tc := range testCases {
+		result := naturalCompare(tc.a, tc.b)
+		if (result < 0 && tc.expected >= 0) || (result > 0 && tc.expected <= 0) || (result == 0 && tc.expected != 0) {
+			t.Errorf("Test Case: %s vs %s. Expected %d, but got %d", tc.a, tc.b, tc.expected, result)
+		}
+	}
+}
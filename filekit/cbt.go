@@ -0,0 +1,96 @@
+package filekit
+
+import (
+	"archive/tar"
+	"os"
+	"time"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// cbtArchive is a tar archive of pages with ComicInfo.xml. Like cbzArchive,
+// it streams pages into a temporary file.
+type cbtArchive struct {
+	opts   Options
+	file   *os.File
+	writer *tar.Writer
+	archivePages
+}
+
+func newCBTArchive(opts Options) (*cbtArchive, error) {
+	file, err := createWorkFile(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cbtArchive{
+		opts:         opts,
+		file:         file,
+		writer:       tar.NewWriter(file),
+		archivePages: newArchivePages(),
+	}, nil
+}
+
+func (c *cbtArchive) WriteOnDiskAndClose(outputDir, outputFileName string,
+	m metadata.Metadata, chapterRange string) error {
+	if err := c.writeMetadata(m); err != nil {
+		c.Abort()
+		return err
+	}
+
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, CBT_EXT, c.opts.OnExists)
+	if err != nil {
+		c.Abort()
+		return err
+	}
+
+	return commitTempFile(c.file, outputPath)
+}
+
+// writeMetadata adds ComicInfo.xml and finishes the archive. Tar has no
+// archive comment, so ComicBookInfo metadata is not written.
+func (c *cbtArchive) writeMetadata(m metadata.Metadata) error {
+	m.SetPages(c.pageInfo())
+	comicInfoContent, err := m.CI.MarshalComicInfo()
+	if err != nil {
+		return err
+	}
+	if err := c.addEntry("ComicInfo.xml", comicInfoContent); err != nil {
+		return err
+	}
+
+	return c.writer.Close()
+}
+
+func (c *cbtArchive) Abort() error {
+	_ = c.file.Close()
+	err := os.Remove(c.file.Name())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *cbtArchive) BeginChapter(chapter Chapter) error {
+	c.beginChapter(chapter)
+	return nil
+}
+
+func (c *cbtArchive) AddFile(fileExt string, src []byte) error {
+	return c.addEntry(c.nextPage(fileExt, src), src)
+}
+
+func (c *cbtArchive) addEntry(fileName string, src []byte) error {
+	err := c.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     fileName,
+		Mode:     0644,
+		Size:     int64(len(src)),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.writer.Write(src)
+	return err
+}
@@ -4,89 +4,152 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/json"
-	"encoding/xml"
-	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/arimatakao/mdx/filekit/metadata"
 )
 
+// storedExtensions are already compressed, deflating them only costs CPU.
+var storedExtensions = []string{"jpg", "jpeg", "png", "gif", "webp", "avif"}
+
+// nomediaFile hides a folder from Android galleries.
+const nomediaFile = ".nomedia"
+
+// cbzArchive streams pages into a temporary file, so memory usage doesn't
+// depend on the archive size.
 type cbzArchive struct {
-	buf         *bytes.Buffer
-	writer      *zip.Writer
-	pageCounter int
+	opts      Options
+	extension string
+	// isPlain archives have only images, without metadata
+	isPlain bool
+	file    *os.File
+	writer  *zip.Writer
+	archivePages
 }
 
 // fileName without extension
-func newCBZArchive() (*cbzArchive, error) {
-	buf := new(bytes.Buffer)
+func newCBZArchive(opts Options) (*cbzArchive, error) {
+	file, err := createWorkFile(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	zipWriter := zip.NewWriter(buf)
+	zipWriter := zip.NewWriter(file)
 
 	c := cbzArchive{
-		buf:         buf,
-		writer:      zipWriter,
-		pageCounter: 1,
+		opts:         opts,
+		extension:    CBZ_EXT,
+		file:         file,
+		writer:       zipWriter,
+		archivePages: newArchivePages(),
 	}
 
 	return &c, nil
 }
 
+// newZipArchive creates a zip archive with images only. With
+// Options.NoMedia it also has a .nomedia file.
+func newZipArchive(opts Options) (*cbzArchive, error) {
+	c, err := newCBZArchive(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.extension = ZIP_EXT
+	c.isPlain = true
+	return c, nil
+}
+
 // ALWAYS close archive after all operations
 func (c *cbzArchive) WriteOnDiskAndClose(outputDir, outputFileName string,
 	m metadata.Metadata, chapterRange string) error {
-	// ComicBookInfo metadata
-	comment, err := json.Marshal(m.CBI)
-	if err != nil {
+	if err := c.writeMetadata(m); err != nil {
+		c.Abort()
 		return err
 	}
-	err = c.writer.SetComment(string(comment))
+
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, c.extension, c.opts.OnExists)
 	if err != nil {
+		c.Abort()
 		return err
 	}
 
-	// ComicRack metadata
-	w, err := c.writer.Create("ComicInfo.xml")
-	if err != nil {
-		return err
+	return commitTempFile(c.file, outputPath)
+}
+
+// writeMetadata adds metadata and finishes the archive.
+func (c *cbzArchive) writeMetadata(m metadata.Metadata) error {
+	if c.isPlain {
+		if c.opts.NoMedia {
+			if err := c.addEntry(nomediaFile, []byte{}); err != nil {
+				return err
+			}
+		}
+		return c.writer.Close()
 	}
 
-	comicInfoContent, err := xml.Marshal(m.CI)
+	// ComicBookInfo metadata
+	comment, err := json.Marshal(m.CBI)
 	if err != nil {
 		return err
 	}
-
-	cireader := bytes.NewReader(comicInfoContent)
-	if _, err := io.Copy(w, cireader); err != nil {
+	err = c.writer.SetComment(string(comment))
+	if err != nil {
 		return err
 	}
 
-	outputPath := safeOutputPath(outputDir, outputFileName, CBZ_EXT)
-
-	err = c.writer.Close()
+	// ComicRack metadata
+	m.SetPages(c.pageInfo())
+	comicInfoContent, err := m.CI.MarshalComicInfo()
 	if err != nil {
 		return err
 	}
-
-	err = os.WriteFile(outputPath, c.buf.Bytes(), os.ModePerm)
-	if err != nil {
+	if err := c.addEntry("ComicInfo.xml", comicInfoContent); err != nil {
 		return err
 	}
 
+	return c.writer.Close()
+}
+
+func (c *cbzArchive) Abort() error {
+	_ = c.file.Close()
+	err := os.Remove(c.file.Name())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *cbzArchive) BeginChapter(chapter Chapter) error {
+	c.beginChapter(chapter)
 	return nil
 }
 
 func (c *cbzArchive) AddFile(fileExt string, src []byte) error {
-	fileName := fmt.Sprintf("%02d.%s", c.pageCounter, fileExt)
-	buf := bytes.NewBuffer(src)
-	w, err := c.writer.Create(fileName)
-	if err != nil {
-		return err
+	return c.addEntry(c.nextPage(fileExt, src), src)
+}
+
+// addEntry writes an archive entry. Images are stored as is, other files are
+// deflated.
+func (c *cbzArchive) addEntry(fileName string, src []byte) error {
+	header := &zip.FileHeader{
+		Name:     fileName,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	if slices.Contains(storedExtensions, ext) {
+		header.Method = zip.Store
 	}
-	if _, err := io.Copy(w, buf); err != nil {
+
+	w, err := c.writer.CreateHeader(header)
+	if err != nil {
 		return err
 	}
-	c.pageCounter++
-	return nil
+	_, err = io.Copy(w, bytes.NewReader(src))
+	return err
 }
@@ -0,0 +1,68 @@
+package filekit
+
+import (
+	"archive/zip"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCBZEntryCompression(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeTestContainer(t, CBZ_EXT, dir, Options{WorkDir: dir}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	r, err := zip.OpenReader(filepath.Join(dir, "out.cbz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	expected := map[string]uint16{
+		"01.png":        zip.Store,
+		"ComicInfo.xml": zip.Deflate,
+	}
+	for _, f := range r.File {
+		method, ok := expected[f.Name]
+		if !ok {
+			t.Errorf("Unexpected entry %s", f.Name)
+			continue
+		}
+		if f.Method != method {
+			t.Errorf("Entry %s: expected method %d, but got %d", f.Name, method, f.Method)
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, tempPattern))
+	if len(matches) != 0 {
+		t.Errorf("Expected no temporary files, but got %v", matches)
+	}
+}
+
+func TestZipNoMedia(t *testing.T) {
+	for _, isNoMedia := range []bool{false, true} {
+		dir := t.TempDir()
+		if err := writeTestContainer(t, ZIP_EXT, dir, Options{NoMedia: isNoMedia}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		r, err := zip.OpenReader(filepath.Join(dir, "out.zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+		r.Close()
+
+		expected := []string{"01.png"}
+		if isNoMedia {
+			expected = append(expected, nomediaFile)
+		}
+		if !slices.Equal(names, expected) {
+			t.Errorf("Test Case: NoMedia %v. Expected entries %v, but got %v", isNoMedia, expected, names)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package filekit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// Chapter describes a chapter boundary inside a merged container.
+type Chapter struct {
+	Number string
+	Title  string
+	Volume string
+	Group  string
+}
+
+// Label returns a human readable chapter name, e.g. "Vol. 1 Ch. 5: Title".
+func (c Chapter) Label() string {
+	parts := []string{}
+	if c.Volume != "" {
+		parts = append(parts, "Vol. "+c.Volume)
+	}
+	if c.Number != "" {
+		parts = append(parts, "Ch. "+c.Number)
+	}
+	label := strings.Join(parts, " ")
+	if c.Title != "" {
+		if label != "" {
+			label += ": "
+		}
+		label += c.Title
+	}
+	if label == "" {
+		label = "Chapter"
+	}
+	return label
+}
+
+// chapterDirName returns a folder name for the chapter with sequence number
+// index, so folders sort in reading order.
+func chapterDirName(index int, c Chapter) string {
+	label := strings.Replace(c.Label(), ": ", " - ", 1)
+	return safeOutputName(fmt.Sprintf("%03d %s", index, label))
+}
+
+// bookTitle is the title of a whole file: the series with the volume and
+// chapter, or with chapterRange for merged files.
+func bookTitle(m metadata.Metadata, chapterRange string) string {
+	info := m.CBI.ComicBookInfoData
+	title := m.CI.Series
+	if chapterRange != "" {
+		return fmt.Sprintf("%s ch%s", title, chapterRange)
+	}
+	if info.Volume != "" {
+		title += " vol" + info.Volume
+	}
+	if info.Issue != "" {
+		title += " ch" + info.Issue
+	}
+	return title
+}
@@ -0,0 +1,127 @@
+package filekit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+func writeMergedTestContainer(t *testing.T, ext, dir string, opts Options, m metadata.Metadata) {
+	t.Helper()
+	opts.WorkDir = dir
+	c, err := NewContainer(ext, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chapter := range []Chapter{{Number: "1", Volume: "1"}, {Number: "2", Volume: "1", Title: "End"}} {
+		if err := c.BeginChapter(chapter); err != nil {
+			t.Fatal(err)
+		}
+		for range 2 {
+			if err := c.AddFile("png", testPage(t)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := c.WriteOnDiskAndClose(dir, "out", m, "1-2"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestMergedChapters(t *testing.T) {
+	for _, ext := range []string{CBZ_EXT, PDF_EXT, EPUB_EXT, DIR_EXT, CBT_EXT, ZIP_EXT, HTML_EXT} {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMergedTestContainer(t, ext, dir, Options{}, metadata.Metadata{})
+
+			expected := []string{
+				"001 Vol. 1 Ch. 1/01.png",
+				"001 Vol. 1 Ch. 1/02.png",
+				"002 Vol. 1 Ch. 2 - End/01.png",
+				"002 Vol. 1 Ch. 2 - End/02.png",
+			}
+
+			switch ext {
+			case CBZ_EXT, ZIP_EXT:
+				r, err := zip.OpenReader(filepath.Join(dir, "out."+ext))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer r.Close()
+				for i, name := range expected {
+					if r.File[i].Name != name {
+						t.Errorf("Expected entry %s, but got %s", name, r.File[i].Name)
+					}
+				}
+			case CBT_EXT:
+				f, err := os.Open(filepath.Join(dir, "out.cbt"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				r := tar.NewReader(f)
+				for _, name := range append(expected, "ComicInfo.xml") {
+					header, err := r.Next()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if header.Name != name {
+						t.Errorf("Expected entry %s, but got %s", name, header.Name)
+					}
+				}
+			case DIR_EXT:
+				for _, name := range expected {
+					if _, err := os.Stat(filepath.Join(dir, "out", name)); err != nil {
+						t.Errorf("Expected page %s: %v", name, err)
+					}
+				}
+			case PDF_EXT:
+				content, err := os.ReadFile(filepath.Join(dir, "out.pdf"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, title := range []string{"Volume 1", "Ch. 1", "Ch. 2: End"} {
+					if !bytes.Contains(content, []byte("/Title "+pdfString(title))) {
+						t.Errorf("Expected outline entry %q", title)
+					}
+				}
+			case EPUB_EXT:
+				r, err := zip.OpenReader(filepath.Join(dir, "out.epub"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer r.Close()
+				nav := readZipEntry(t, r, "OEBPS/nav.xhtml")
+				for _, entry := range []string{
+					`<a href="pages/page-001.xhtml">Vol. 1 Ch. 1</a>`,
+					`<a href="pages/page-003.xhtml">Vol. 1 Ch. 2: End</a>`,
+				} {
+					if !strings.Contains(nav, entry) {
+						t.Errorf("Expected nav entry %s in:\n%s", entry, nav)
+					}
+				}
+			case HTML_EXT:
+				content, err := os.ReadFile(filepath.Join(dir, "out.html"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, anchor := range []string{
+					`<option value="1">Vol. 1 Ch. 1</option>`,
+					`<option value="3">Vol. 1 Ch. 2: End</option>`,
+					`id="p1"`,
+					`id="p3"`,
+				} {
+					if !bytes.Contains(content, []byte(anchor)) {
+						t.Errorf("Expected chapter anchor %s", anchor)
+					}
+				}
+			}
+		})
+	}
+}
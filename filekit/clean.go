@@ -0,0 +1,104 @@
+package filekit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// EPUB_TEMP_PREFIX starts names of temporary directories with EPUB pages.
+	EPUB_TEMP_PREFIX = "mdxepubfiles"
+	// DIR_TEMP_PREFIX starts names of temporary directories with pages of
+	// the dir container.
+	DIR_TEMP_PREFIX = "mdxdirfiles"
+	// HTML_TEMP_PREFIX starts names of temporary directories with pages of
+	// HTML files.
+	HTML_TEMP_PREFIX = "mdxhtmlfiles"
+)
+
+var tempPrefixes = []string{EPUB_TEMP_PREFIX, DIR_TEMP_PREFIX, HTML_TEMP_PREFIX}
+
+// CleanStale removes temporary files and directories left by interrupted
+// runs: page directories in the system temporary directory and in-progress
+// outputs found anywhere inside outputDirs. Entries modified less than
+// olderThan ago are kept because they may belong to a running download.
+// It returns paths of removed entries. A directory that fails to be cleaned
+// doesn't stop the others, errors of all directories are joined.
+func CleanStale(outputDirs []string, olderThan time.Duration) ([]string, error) {
+	removed := []string{}
+	errs := []error{}
+	deadline := time.Now().Add(-olderThan)
+
+	isStale := func(info fs.FileInfo) bool {
+		return info.ModTime().Before(deadline)
+	}
+
+	tempDir := os.TempDir()
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", tempDir, err))
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		isTemp := slices.ContainsFunc(tempPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(name, prefix)
+		})
+		if !entry.IsDir() || !isTemp {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !isStale(info) {
+			continue
+		}
+		path := filepath.Join(tempDir, name)
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, path)
+	}
+
+	for _, outputDir := range outputDirs {
+		dirErrs := []error{}
+		err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+			// entries removed by a running download are gone already
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				dirErrs = append(dirErrs, err)
+				return nil
+			}
+			if isMatched, _ := filepath.Match(tempPattern, d.Name()); !isMatched {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil || !isStale(info) {
+				return nil
+			}
+			if err := os.RemoveAll(path); err != nil {
+				dirErrs = append(dirErrs, err)
+				return nil
+			}
+			removed = append(removed, path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			dirErrs = append(dirErrs, err)
+		}
+		if len(dirErrs) > 0 {
+			errs = append(errs, fmt.Errorf("%s: %w", outputDir, errors.Join(dirErrs...)))
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
@@ -0,0 +1,39 @@
+package filekit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCleanStale(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "Series", ".mdx-1.tmp")
+	fresh := filepath.Join(dir, ".mdx-2.tmp")
+	for _, path := range []string{stale, fresh} {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("page"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// a missing directory doesn't stop cleaning of the others
+	removed, err := CleanStale([]string{filepath.Join(dir, "missing"), dir}, time.Hour)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !slices.Contains(removed, stale) || slices.Contains(removed, fresh) {
+		t.Errorf("Expected %s removed and %s kept, but got %v", stale, fresh, removed)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("Expected %s kept, but got %v", fresh, err)
+	}
+}
@@ -0,0 +1,51 @@
+package filekit
+
+import (
+	"slices"
+	"strings"
+)
+
+// Device is a reader screen that output pages are fitted to.
+type Device struct {
+	Name string
+	// Width and Height are the portrait screen size in pixels.
+	Width  int
+	Height int
+	// DPI is the screen density, it converts pixels to physical sizes.
+	DPI int
+	// Grayscale is true for e-ink screens without color.
+	Grayscale bool
+}
+
+// Devices are the supported device profiles.
+var Devices = []Device{
+	{Name: "kindle-paperwhite", Width: 1236, Height: 1648, DPI: 300, Grayscale: true},
+	{Name: "kobo-libra", Width: 1264, Height: 1680, DPI: 300, Grayscale: true},
+	{Name: "boox", Width: 1404, Height: 1872, DPI: 227, Grayscale: true},
+	{Name: "phone", Width: 1080, Height: 2340, DPI: 400},
+}
+
+// DeviceProfile returns the device profile by name.
+func DeviceProfile(name string) (Device, bool) {
+	i := slices.IndexFunc(Devices, func(d Device) bool {
+		return d.Name == strings.ToLower(name)
+	})
+	if i == -1 {
+		return Device{}, false
+	}
+	return Devices[i], true
+}
+
+// DeviceNames returns names of the supported device profiles.
+func DeviceNames() []string {
+	names := make([]string, 0, len(Devices))
+	for _, d := range Devices {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+// pageSizePt returns the physical screen size in points.
+func (d Device) pageSizePt() (float64, float64) {
+	return float64(d.Width) * 72 / float64(d.DPI), float64(d.Height) * 72 / float64(d.DPI)
+}
@@ -10,17 +10,23 @@ import (
 )
 
 type dirContainer struct {
+	opts      Options
 	tempDir   string
 	pageIndex int
+	// chapterDir is a subdirectory for pages of the current chapter in
+	// merged directories
+	chapterDir   string
+	chapterCount int
 }
 
-func newDirContainer() (*dirContainer, error) {
-	tempDir, err := os.MkdirTemp("", "mdxdirfiles")
+func newDirContainer(opts Options) (*dirContainer, error) {
+	tempDir, err := os.MkdirTemp("", DIR_TEMP_PREFIX)
 	if err != nil {
 		return nil, err
 	}
 
 	return &dirContainer{
+		opts:      opts,
 		tempDir:   tempDir,
 		pageIndex: 1,
 	}, nil
@@ -28,38 +34,52 @@ func newDirContainer() (*dirContainer, error) {
 
 func (d *dirContainer) WriteOnDiskAndClose(outputDir, outputFileName string,
 	m metadata.Metadata, chapterRange string) error {
-	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+	defer d.Abort()
+
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, DIR_EXT, d.opts.OnExists)
+	if err != nil {
 		return err
 	}
 
-	outputPath := safeOutputDirPath(outputDir, outputFileName)
-	if err := os.MkdirAll(outputPath, os.ModePerm); err != nil {
+	// the directory is assembled next to the output and renamed into place
+	stageDir, err := os.MkdirTemp(outputDir, tempPattern)
+	if err != nil {
 		return err
 	}
 
-	entries, err := os.ReadDir(d.tempDir)
-	if err != nil {
+	if err := copyDir(d.tempDir, stageDir); err != nil {
+		_ = os.RemoveAll(stageDir)
 		return err
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
+	if err := os.Chmod(stageDir, 0755); err != nil {
+		_ = os.RemoveAll(stageDir)
+		return err
+	}
+	syncDir(stageDir)
 
-		srcPath := filepath.Join(d.tempDir, entry.Name())
-		dstPath := filepath.Join(outputPath, entry.Name())
-		if err := copyFile(srcPath, dstPath); err != nil {
-			return err
-		}
+	if err := commitTempDir(stageDir, outputPath); err != nil {
+		_ = os.RemoveAll(stageDir)
+		return err
 	}
 
+	return nil
+}
+
+func (d *dirContainer) Abort() error {
 	return os.RemoveAll(d.tempDir)
 }
 
+func (d *dirContainer) BeginChapter(chapter Chapter) error {
+	d.chapterCount++
+	d.chapterDir = chapterDirName(d.chapterCount, chapter)
+	d.pageIndex = 1
+	return os.MkdirAll(filepath.Join(d.tempDir, d.chapterDir), os.ModePerm)
+}
+
 func (d *dirContainer) AddFile(fileExt string, imageBytes []byte) error {
 	fileName := fmt.Sprintf("%02d.%s", d.pageIndex, fileExt)
-	filePath := filepath.Join(d.tempDir, fileName)
+	filePath := filepath.Join(d.tempDir, d.chapterDir, fileName)
 	if err := os.WriteFile(filePath, imageBytes, os.ModePerm); err != nil {
 		return err
 	}
@@ -68,6 +88,35 @@ func (d *dirContainer) AddFile(fileExt string, imageBytes []byte) error {
 	return nil
 }
 
+// copyDir copies files and subdirectories of srcDir into existing dstDir.
+func copyDir(srcDir, dstDir string) error {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(srcDir, entry.Name())
+		dstPath := filepath.Join(dstDir, entry.Name())
+
+		if entry.IsDir() {
+			if err := os.Mkdir(dstPath, os.ModePerm); err != nil {
+				return err
+			}
+			if err := copyDir(srcPath, dstPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := copyFile(srcPath, dstPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func copyFile(srcPath, dstPath string) error {
 	srcFile, err := os.Open(srcPath)
 	if err != nil {
@@ -85,6 +134,11 @@ func copyFile(srcPath, dstPath string) error {
 		return err
 	}
 
+	if err := dstFile.Sync(); err != nil {
+		_ = dstFile.Close()
+		return err
+	}
+
 	if err := dstFile.Close(); err != nil {
 		return err
 	}
@@ -1,8 +1,11 @@
 // Package filekit provides abstractions for writing manga chapter pages into
-// different output containers such as CBZ, PDF, EPUB, or a plain directory.
+// different output containers such as CBZ, CBT, PDF, EPUB, a plain zip
+// archive, a Kindle EPUB, an HTML reader or a plain directory.
 //
 // A typical flow is:
 //  1. Create a container with NewContainer.
-//  2. Add pages with Container.AddFile.
-//  3. Finalize output with Container.WriteOnDiskAndClose.
+//  2. Add pages with Container.AddFile. Merged containers call
+//     Container.BeginChapter before the pages of each chapter.
+//  3. Finalize output with Container.WriteOnDiskAndClose, or discard it with
+//     Container.Abort on error.
 package filekit
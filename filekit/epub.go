@@ -2,8 +2,11 @@ package filekit
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 
 	"github.com/arimatakao/mdx/filekit/metadata"
 	"github.com/go-shiori/go-epub"
@@ -11,59 +14,110 @@ import (
 
 const imageSectionTemplate = `<img src="%s" alt="%s" />`
 
+// defaultPageWidth and defaultPageHeight size the viewport of pages with an
+// unknown image format.
+const (
+	defaultPageWidth  = 800
+	defaultPageHeight = 1200
+)
+
 type epubArchive struct {
-	b          *epub.Epub
-	tempDir    string
-	filesPaths []string
-	pageIndex  int
+	opts      Options
+	b         *epub.Epub
+	tempDir   string
+	pages     []fixedLayoutPage
+	pageIndex int
+	// chapterStarts maps an index in pages to the chapter starting there
+	chapterStarts map[int]Chapter
 }
 
-func newEpubArchive() (*epubArchive, error) {
+func newEpubArchive(opts Options) (*epubArchive, error) {
 	book, err := epub.NewEpub("")
 	if err != nil {
 		return &epubArchive{}, err
 	}
 
-	dir, err := os.MkdirTemp("", "mdxepubfiles")
+	dir, err := os.MkdirTemp("", EPUB_TEMP_PREFIX)
 	if err != nil {
 		return &epubArchive{}, err
 	}
 
 	return &epubArchive{
-		b:          book,
-		tempDir:    dir,
-		filesPaths: []string{},
-		pageIndex:  1,
+		opts:          opts,
+		b:             book,
+		tempDir:       dir,
+		pages:         []fixedLayoutPage{},
+		pageIndex:     1,
+		chapterStarts: map[int]Chapter{},
 	}, nil
 }
 
 func (e *epubArchive) WriteOnDiskAndClose(outputDir string, outputFileName string,
 	m metadata.Metadata, chapterRange string) error {
+	defer e.Abort()
+
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, EPUB_EXT, e.opts.OnExists)
+	if err != nil {
+		return err
+	}
+
+	if e.opts.EpubLayout == EPUB_LAYOUT_REFLOW {
+		return e.writeReflowable(outputPath, m, chapterRange)
+	}
+
+	book := e.fixedLayoutBook(m, chapterRange)
+	book.Meta = append(book.Meta, book.koboMeta()...)
+	return writeFileAtomic(outputPath, book.write)
+}
 
-	for i, filePath := range e.filesPaths {
+func (e *epubArchive) fixedLayoutBook(m metadata.Metadata, chapterRange string) fixedLayoutBook {
+	book := newFixedLayoutBook(bookTitle(m, chapterRange), e.pages, e.chapterStarts)
+	book.setMetadata(m, chapterRange)
+	return book
+}
+
+// setMetadata sets entries of the package metadata from m.
+func (b *fixedLayoutBook) setMetadata(m metadata.Metadata, chapterRange string) {
+	b.Title = bookTitle(m, chapterRange)
+	b.Identifiers = m.SourceIDs().URNs()
+	b.Series = m.CI.Series
+	// merged books have no single position in the series
+	if _, err := strconv.ParseFloat(m.CI.Number, 64); err == nil && chapterRange == "" {
+		b.SeriesIndex = m.CI.Number
+	}
+	b.Language = m.CI.LanguageISO
+	b.Authors = creditPersons(m)
+	b.Description = m.CI.Summary
+	b.RightToLeft = m.IsRightToLeft()
+}
+
+// writeReflowable writes pages as images inside reflowable sections.
+func (e *epubArchive) writeReflowable(outputPath string, m metadata.Metadata,
+	chapterRange string) error {
+	// pages of a chapter are nested under its first page, so the table of
+	// contents has an entry per chapter
+	chapterSection := ""
+	for i, page := range e.pages {
 		indexPage := fmt.Sprintf("%02d", i+1)
-		imageEpubPath, err := e.b.AddImage(filePath, indexPage)
+		imageEpubPath, err := e.b.AddImage(page.Path, indexPage)
 		if err != nil {
-			if err = os.RemoveAll(e.tempDir); err != nil {
-				return err
-			}
 			return err
 		}
 		sectionStr := fmt.Sprintf(imageSectionTemplate, imageEpubPath, indexPage)
-		_, err = e.b.AddSection(sectionStr, indexPage, "", "")
+
+		if chapter, ok := e.chapterStarts[i]; ok {
+			chapterSection, err = e.b.AddSection(sectionStr, chapter.Label(), "", "")
+		} else if chapterSection != "" {
+			_, err = e.b.AddSubSection(chapterSection, sectionStr, indexPage, "", "")
+		} else {
+			_, err = e.b.AddSection(sectionStr, indexPage, "", "")
+		}
 		if err != nil {
-			if err = os.RemoveAll(e.tempDir); err != nil {
-				return err
-			}
 			return err
 		}
 	}
 
-	bookTitle := fmt.Sprintf("%s vol%s ch%s", m.CI.Title, m.CI.Volume, m.CI.Number)
-	if chapterRange != "" {
-		bookTitle = fmt.Sprintf("%s ch%s", m.CI.Title, chapterRange)
-	}
-	e.b.SetTitle(bookTitle)
+	e.b.SetTitle(bookTitle(m, chapterRange))
 
 	authors := m.P.Authors + " | " + m.P.Artists
 	e.b.SetAuthor(authors)
@@ -72,31 +126,56 @@ func (e *epubArchive) WriteOnDiskAndClose(outputDir string, outputFileName strin
 
 	e.b.SetDescription(m.CI.Summary)
 
-	err := os.MkdirAll(outputDir, os.ModePerm)
-	if err != nil {
+	return writeFileAtomic(outputPath, func(w io.Writer) error {
+		_, err := e.b.WriteTo(w)
 		return err
-	}
+	})
+}
 
-	outputPath := safeOutputPath(outputDir, outputFileName, EPUB_EXT)
+func (e *epubArchive) Abort() error {
+	return os.RemoveAll(e.tempDir)
+}
 
-	err = e.b.Write(outputPath)
+func (e *epubArchive) BeginChapter(chapter Chapter) error {
+	e.chapterStarts[len(e.pages)] = chapter
+	return nil
+}
+
+func (e *epubArchive) AddFile(fileExt string, imageBytes []byte) error {
+	fileExt, err := pageExt(fileExt, imageBytes)
 	if err != nil {
 		return err
 	}
 
-	return os.RemoveAll(e.tempDir)
-}
-
-func (e *epubArchive) AddFile(fileExt string, imageBytes []byte) error {
 	fileName := fmt.Sprintf("%02d.%s", e.pageIndex, fileExt)
 	filePath := filepath.Join(e.tempDir, fileName)
-	err := os.WriteFile(filePath, imageBytes, os.ModePerm)
+	err = os.WriteFile(filePath, imageBytes, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
-	e.filesPaths = append(e.filesPaths, filePath)
+	page := fixedLayoutPage{
+		Path:   filePath,
+		Ext:    fileExt,
+		Width:  defaultPageWidth,
+		Height: defaultPageHeight,
+	}
+	if width, height, err := getImageDimensions(imageBytes); err == nil {
+		page.Width, page.Height = int(width), int(height)
+	}
+	e.pages = append(e.pages, page)
 
 	e.pageIndex++
 	return nil
 }
+
+// creditPersons returns writers and artists without repeats.
+func creditPersons(m metadata.Metadata) []string {
+	persons := []string{}
+	for _, credit := range m.CBI.ComicBookInfoData.Credits {
+		if !slices.Contains(persons, credit.Person) {
+			persons = append(persons, credit.Person)
+		}
+	}
+	return persons
+}
@@ -0,0 +1,380 @@
+package filekit
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// EPUB_LAYOUT_FIXED writes a pre-paginated EPUB3, every page fills the
+	// screen. It is the default.
+	EPUB_LAYOUT_FIXED = "fixed"
+	// EPUB_LAYOUT_REFLOW writes pages as images inside reflowable sections.
+	// Such files record no MangaDex IDs and can't be retagged.
+	EPUB_LAYOUT_REFLOW = "reflow"
+)
+
+// IsNotSupportedEpubLayout reports whether layout is not one of the
+// EPUB_LAYOUT_* values.
+func IsNotSupportedEpubLayout(layout string) bool {
+	return layout != EPUB_LAYOUT_FIXED && layout != EPUB_LAYOUT_REFLOW
+}
+
+var imageMediaTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+}
+
+// fixedLayoutPage is a page image stored in a temporary file.
+type fixedLayoutPage struct {
+	Path   string
+	Ext    string
+	Width  int
+	Height int
+}
+
+func (p fixedLayoutPage) MediaType() string {
+	return imageMediaTypes[p.Ext]
+}
+
+// ErrImageNotSupported is returned for pages that are not in a format
+// e-readers can show.
+var ErrImageNotSupported = errors.New("image format is not supported")
+
+// pageExt returns the lowercase extension of a page. Unknown extensions are
+// replaced by the one of the detected format, so the media type of every
+// page in the manifest is valid.
+func pageExt(fileExt string, imageBytes []byte) (string, error) {
+	fileExt = strings.ToLower(fileExt)
+	if _, ok := imageMediaTypes[fileExt]; ok {
+		return fileExt, nil
+	}
+	if ext, ok := imageExtensions[http.DetectContentType(imageBytes)]; ok {
+		return ext, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrImageNotSupported, fileExt)
+}
+
+// navEntry is a table of contents entry pointing to a page.
+type navEntry struct {
+	Title string
+	Page  int
+}
+
+// fixedLayoutBook is a pre-paginated EPUB3 with a page per image.
+type fixedLayoutBook struct {
+	Identifier string
+	// Identifiers are additional identifiers, e.g. URNs of MangaDex IDs.
+	Identifiers []string
+	Title       string
+	Series      string
+	// SeriesIndex is the number of the book in the series.
+	SeriesIndex string
+	Language    string
+	Authors     []string
+	Description string
+	Modified    string
+	RightToLeft bool
+	Pages       []fixedLayoutPage
+	Nav         []navEntry
+	// Meta are additional <meta name content> entries, e.g. for Kindle.
+	Meta [][2]string
+}
+
+func newFixedLayoutBook(title string, pages []fixedLayoutPage, chapters map[int]Chapter) fixedLayoutBook {
+	nav := []navEntry{}
+	for i := range pages {
+		if chapter, ok := chapters[i]; ok {
+			nav = append(nav, navEntry{Title: chapter.Label(), Page: i + 1})
+		}
+	}
+	if len(nav) == 0 && len(pages) > 0 {
+		nav = append(nav, navEntry{Title: title, Page: 1})
+	}
+
+	return fixedLayoutBook{
+		Identifier: newUUID(),
+		Title:      title,
+		Modified:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Pages:      pages,
+		Nav:        nav,
+	}
+}
+
+// koboMeta returns the entries Kobo readers use to show pages of comics
+// full screen in the reading direction.
+func (b fixedLayoutBook) koboMeta() [][2]string {
+	writingMode := "horizontal-lr"
+	if b.RightToLeft {
+		writingMode = "horizontal-rl"
+	}
+	meta := [][2]string{
+		{"book-type", "comic"},
+		{"primary-writing-mode", writingMode},
+		{"zero-gutter", "true"},
+		{"zero-margin", "true"},
+		{"orientation-lock", "none"},
+	}
+	if len(b.Pages) > 0 {
+		meta = append(meta, [2]string{"original-resolution",
+			fmt.Sprintf("%dx%d", b.Pages[0].Width, b.Pages[0].Height)})
+	}
+	return meta
+}
+
+func (b fixedLayoutBook) PageProgression() string {
+	if b.RightToLeft {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// write writes the EPUB container. mimetype goes first and uncompressed, as
+// the specification requires.
+func (b fixedLayoutBook) write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name     string
+		template *template.Template
+		data     any
+	}{
+		{"META-INF/container.xml", epubContainerTemplate, b},
+		{"META-INF/com.apple.ibooks.display-options.xml", epubAppleOptionsTemplate, b},
+		{"OEBPS/content.opf", epubPackageTemplate, b},
+		{"OEBPS/nav.xhtml", epubNavTemplate, b},
+		{"OEBPS/toc.ncx", epubNcxTemplate, b},
+		{"OEBPS/style.css", epubStyleTemplate, b},
+	}
+	for i, page := range b.Pages {
+		files = append(files, struct {
+			name     string
+			template *template.Template
+			data     any
+		}{fmt.Sprintf("OEBPS/pages/page-%03d.xhtml", i+1), epubPageTemplate, map[string]any{
+			"Book":   b,
+			"Page":   page,
+			"Number": i + 1,
+		}})
+	}
+
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if err := file.template.Execute(fw, file.data); err != nil {
+			return err
+		}
+	}
+
+	for i, page := range b.Pages {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   fmt.Sprintf("OEBPS/images/page-%03d.%s", i+1, page.Ext),
+			Method: zip.Store,
+		})
+		if err != nil {
+			return err
+		}
+		if err := copyFileTo(fw, page.Path); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func escapeXML(value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+var epubFuncs = template.FuncMap{
+	"xml": escapeXML,
+	"add": func(a, b int) int { return a + b },
+}
+
+func newEpubTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(epubFuncs).Parse(text))
+}
+
+var epubContainerTemplate = newEpubTemplate("container", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`)
+
+var epubAppleOptionsTemplate = newEpubTemplate("apple", `<?xml version="1.0" encoding="UTF-8"?>
+<display_options>
+  <platform name="*">
+    <option name="fixed-layout">true</option>
+    <option name="open-to-spread">false</option>
+  </platform>
+</display_options>
+`)
+
+// epubMetadataTemplate is the metadata element of the package document, it
+// is rendered alone to retag books.
+var epubMetadataTemplate = newEpubTemplate("metadata", `<metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:identifier id="book-id">urn:uuid:{{.Identifier}}</dc:identifier>
+{{- range .Identifiers}}
+    <dc:identifier>{{xml .}}</dc:identifier>
+{{- end}}
+    <dc:title>{{xml .Title}}</dc:title>
+    <dc:language>{{if .Language}}{{xml .Language}}{{else}}en{{end}}</dc:language>
+{{- range .Authors}}
+    <dc:creator>{{xml .}}</dc:creator>
+{{- end}}
+{{- if .Description}}
+    <dc:description>{{xml .Description}}</dc:description>
+{{- end}}
+{{- if .Series}}
+    <meta property="belongs-to-collection" id="series">{{xml .Series}}</meta>
+    <meta refines="#series" property="collection-type">series</meta>
+{{- if .SeriesIndex}}
+    <meta refines="#series" property="group-position">{{xml .SeriesIndex}}</meta>
+{{- end}}
+{{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:orientation">auto</meta>
+    <meta property="rendition:spread">landscape</meta>
+{{- if .Pages}}
+    <meta name="cover" content="image-001"/>
+{{- end}}
+    <meta name="fixed-layout" content="true"/>
+{{- range .Meta}}
+    <meta name="{{xml (index . 0)}}" content="{{xml (index . 1)}}"/>
+{{- end}}
+  </metadata>`)
+
+var epubPackageTemplate = template.Must(template.Must(epubMetadataTemplate.Clone()).
+	New("package").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" prefix="rendition: http://www.idpf.org/vocab/rendition/#">
+  {{template "metadata" .}}
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+{{- range $i, $page := .Pages}}
+    <item id="image-{{printf "%03d" (add $i 1)}}" href="images/page-{{printf "%03d" (add $i 1)}}.{{$page.Ext}}" media-type="{{$page.MediaType}}"{{if eq $i 0}} properties="cover-image"{{end}}/>
+    <item id="page-{{printf "%03d" (add $i 1)}}" href="pages/page-{{printf "%03d" (add $i 1)}}.xhtml" media-type="application/xhtml+xml"/>
+{{- end}}
+  </manifest>
+  <spine toc="ncx" page-progression-direction="{{.PageProgression}}">
+{{- range $i, $page := .Pages}}
+    <itemref idref="page-{{printf "%03d" (add $i 1)}}"/>
+{{- end}}
+  </spine>
+</package>
+`))
+
+var epubNavTemplate = newEpubTemplate("nav", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <title>{{xml .Title}}</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <ol>
+{{- range .Nav}}
+      <li><a href="pages/page-{{printf "%03d" .Page}}.xhtml">{{xml .Title}}</a></li>
+{{- end}}
+    </ol>
+  </nav>
+  <nav epub:type="landmarks" hidden="">
+    <ol>
+      <li><a epub:type="cover" href="pages/page-001.xhtml">Cover</a></li>
+      <li><a epub:type="bodymatter" href="pages/page-001.xhtml">Start</a></li>
+    </ol>
+  </nav>
+</body>
+</html>
+`)
+
+var epubNcxTemplate = newEpubTemplate("ncx", `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="urn:uuid:{{.Identifier}}"/>
+  </head>
+  <docTitle><text>{{xml .Title}}</text></docTitle>
+  <navMap>
+{{- range $i, $entry := .Nav}}
+    <navPoint id="nav-{{add $i 1}}" playOrder="{{add $i 1}}">
+      <navLabel><text>{{xml $entry.Title}}</text></navLabel>
+      <content src="pages/page-{{printf "%03d" $entry.Page}}.xhtml"/>
+    </navPoint>
+{{- end}}
+  </navMap>
+</ncx>
+`)
+
+var epubStyleTemplate = newEpubTemplate("style", `html, body {
+  margin: 0;
+  padding: 0;
+  width: 100%;
+  height: 100%;
+}
+img {
+  display: block;
+  width: 100%;
+  height: 100%;
+}
+`)
+
+var epubPageTemplate = newEpubTemplate("page", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <title>{{xml .Book.Title}} - {{.Number}}</title>
+  <meta name="viewport" content="width={{.Page.Width}}, height={{.Page.Height}}"/>
+  <link rel="stylesheet" type="text/css" href="../style.css"/>
+</head>
+<body style="width: {{.Page.Width}}px; height: {{.Page.Height}}px;">
+  <img src="../images/page-{{printf "%03d" .Number}}.{{.Page.Ext}}" alt="{{.Number}}"/>
+</body>
+</html>
+`)
@@ -0,0 +1,111 @@
+package filekit
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+func readZipEntry(t *testing.T, r *zip.ReadCloser, name string) string {
+	t.Helper()
+	f, err := r.Open(name)
+	if err != nil {
+		t.Fatalf("Expected entry %s: %v", name, err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestFixedLayoutEpub(t *testing.T) {
+	dir := t.TempDir()
+	m := metadata.Metadata{CI: metadata.ComicInfoMetadata{
+		Series: "Series",
+		Manga:  metadata.MANGA_RIGHT_TO_LEFT,
+	}}
+	writeMergedTestContainer(t, EPUB_EXT, dir, Options{}, m)
+
+	r, err := zip.OpenReader(filepath.Join(dir, "out.epub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if r.File[0].Name != "mimetype" || r.File[0].Method != zip.Store {
+		t.Errorf("Expected stored mimetype first, but got %s", r.File[0].Name)
+	}
+
+	opf := readZipEntry(t, r, "OEBPS/content.opf")
+	for _, expected := range []string{
+		`<meta property="rendition:layout">pre-paginated</meta>`,
+		`page-progression-direction="rtl"`,
+		`properties="cover-image"`,
+		`<meta name="cover" content="image-001"/>`,
+		`<dc:title>Series ch1-2</dc:title>`,
+		`<meta name="book-type" content="comic"/>`,
+		`<meta name="primary-writing-mode" content="horizontal-rl"/>`,
+		`<meta name="original-resolution" content="4x6"/>`,
+	} {
+		if !strings.Contains(opf, expected) {
+			t.Errorf("Expected %s in content.opf", expected)
+		}
+	}
+
+	nav := readZipEntry(t, r, "OEBPS/nav.xhtml")
+	for _, expected := range []string{
+		`<a href="pages/page-001.xhtml">Vol. 1 Ch. 1</a>`,
+		`<a href="pages/page-003.xhtml">Vol. 1 Ch. 2: End</a>`,
+	} {
+		if !strings.Contains(nav, expected) {
+			t.Errorf("Expected %s in nav.xhtml", expected)
+		}
+	}
+
+	page := readZipEntry(t, r, "OEBPS/pages/page-004.xhtml")
+	if !strings.Contains(page, `<meta name="viewport" content="width=4, height=6"/>`) {
+		t.Errorf("Expected viewport of the image size, but got %s", page)
+	}
+}
+
+func TestEpubMetadataWithoutPages(t *testing.T) {
+	var b strings.Builder
+	if err := epubMetadataTemplate.Execute(&b, fixedLayoutBook{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), `name="cover"`) {
+		t.Errorf("Expected no cover without pages, but got:\n%s", b.String())
+	}
+}
+
+func TestPageExt(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	testCases := []struct {
+		name        string
+		ext         string
+		image       []byte
+		expectedExt string
+		expectedErr bool
+	}{
+		{"known extension", "jpg", png, "jpg", false},
+		{"uppercase extension", "PNG", png, "png", false},
+		{"unknown extension of a png", "bin", png, "png", false},
+		{"unknown format", "bmp", []byte("BM"), "", true},
+	}
+
+	for _, tc := range testCases {
+		ext, err := pageExt(tc.ext, tc.image)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("Test Case: %s. Expected error %v, but got %v", tc.name, tc.expectedErr, err)
+		}
+		if ext != tc.expectedExt {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", tc.name, tc.expectedExt, ext)
+		}
+	}
+}
@@ -19,8 +19,30 @@ const (
 	EPUB_EXT = "epub"
 	// DIR_EXT stores chapter pages in a plain directory.
 	DIR_EXT = "dir"
+	// CBT_EXT is a CBT (tar) archive container extension.
+	CBT_EXT = "cbt"
+	// ZIP_EXT is a zip archive with images only.
+	ZIP_EXT = "zip"
+	// KINDLE_EXT is a fixed-layout EPUB for Kindle with pages resized to the
+	// screen and converted to grayscale. Files have the .epub extension.
+	KINDLE_EXT = "kindle"
+	// HTML_EXT is a single HTML file with a built-in reader.
+	HTML_EXT = "html"
 )
 
+// fileExtensions are file extensions of formats named differently.
+var fileExtensions = map[string]string{
+	KINDLE_EXT: EPUB_EXT,
+}
+
+// FileExtension returns the extension of files written in format.
+func FileExtension(format string) string {
+	if ext, ok := fileExtensions[format]; ok {
+		return ext
+	}
+	return format
+}
+
 // ErrExtensionNotSupport is returned when a requested output container
 // extension is unknown.
 var ErrExtensionNotSupport = errors.New("extension container is not supported")
@@ -31,33 +53,57 @@ func IsNotSupported(fileFormat string) bool {
 	return fileFormat != CBZ_EXT &&
 		fileFormat != PDF_EXT &&
 		fileFormat != EPUB_EXT &&
-		fileFormat != DIR_EXT
+		fileFormat != DIR_EXT &&
+		fileFormat != CBT_EXT &&
+		fileFormat != ZIP_EXT &&
+		fileFormat != KINDLE_EXT &&
+		fileFormat != HTML_EXT
 }
 
 // Container describes a writable chapter output that accepts page images and
 // then persists itself to disk.
 type Container interface {
 	// WriteOnDiskAndClose finalizes container content and writes it into
-	// outputDir using outputFileName as a base name.
+	// outputDir using outputFileName as a base name. The output appears
+	// atomically; if it already exists, Options.OnExists decides what happens.
 	WriteOnDiskAndClose(outputDir string, outputFileName string, m metadata.Metadata, chapterRange string) error
+	// BeginChapter marks the start of a chapter in a merged container. Pages
+	// added after it belong to c. Containers without chapters keep a flat
+	// list of pages.
+	BeginChapter(c Chapter) error
 	// AddFile appends a new page represented by imageBytes with fileExt format.
 	AddFile(fileExt string, imageBytes []byte) error
+	// Abort discards the container and removes its temporary files. It is
+	// safe to call after WriteOnDiskAndClose and more than once.
+	Abort() error
 }
 
 // NewContainer creates a container by file extension.
 //
-// Supported extensions are CBZ_EXT, PDF_EXT, EPUB_EXT and DIR_EXT.
-func NewContainer(extension string) (Container, error) {
+// Supported extensions are CBZ_EXT, PDF_EXT, EPUB_EXT, DIR_EXT, CBT_EXT,
+// ZIP_EXT, KINDLE_EXT and HTML_EXT.
+func NewContainer(extension string, opts Options) (Container, error) {
+	if opts.OnExists == "" {
+		opts.OnExists = ON_EXISTS_RENAME
+	}
 
 	switch extension {
 	case CBZ_EXT:
-		return newCBZArchive()
+		return newCBZArchive(opts)
 	case PDF_EXT:
-		return newPdfFile()
+		return newPdfFile(opts)
 	case EPUB_EXT:
-		return newEpubArchive()
+		return newEpubArchive(opts)
 	case DIR_EXT:
-		return newDirContainer()
+		return newDirContainer(opts)
+	case CBT_EXT:
+		return newCBTArchive(opts)
+	case ZIP_EXT:
+		return newZipArchive(opts)
+	case KINDLE_EXT:
+		return newKindleArchive(opts)
+	case HTML_EXT:
+		return newHtmlArchive(opts)
 	}
 
 	return nil, ErrExtensionNotSupport
@@ -0,0 +1,308 @@
+package filekit
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// htmlAssetsSuffix names the folder with pages next to the HTML file, like
+// browsers do for saved pages.
+const htmlAssetsSuffix = "_files"
+
+// htmlArchive is a single HTML page with a built-in reader. Pages are kept
+// in a temporary directory and embedded as data URIs when the file is
+// written, or copied into a sibling assets folder with Options.HtmlAssets.
+type htmlArchive struct {
+	opts    Options
+	tempDir string
+	pages   []fixedLayoutPage
+	// chapterStarts maps an index in pages to the chapter starting there
+	chapterStarts map[int]Chapter
+}
+
+func newHtmlArchive(opts Options) (*htmlArchive, error) {
+	dir, err := os.MkdirTemp("", HTML_TEMP_PREFIX)
+	if err != nil {
+		return nil, err
+	}
+
+	return &htmlArchive{
+		opts:          opts,
+		tempDir:       dir,
+		pages:         []fixedLayoutPage{},
+		chapterStarts: map[int]Chapter{},
+	}, nil
+}
+
+func (h *htmlArchive) WriteOnDiskAndClose(outputDir, outputFileName string,
+	m metadata.Metadata, chapterRange string) error {
+	defer h.Abort()
+
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, HTML_EXT, h.opts.OnExists)
+	if err != nil {
+		return err
+	}
+
+	assetsDir := ""
+	if h.opts.HtmlAssets {
+		assetsDir = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + htmlAssetsSuffix
+		if err := h.writeAssets(outputDir, assetsDir); err != nil {
+			return err
+		}
+	}
+
+	reader := htmlReader{
+		Title:       bookTitle(m, chapterRange),
+		Language:    m.CI.LanguageISO,
+		RightToLeft: m.IsRightToLeft(),
+	}
+	for i := range h.pages {
+		if chapter, ok := h.chapterStarts[i]; ok {
+			reader.Chapters = append(reader.Chapters, navEntry{Title: chapter.Label(), Page: i + 1})
+		}
+	}
+
+	return writeFileAtomic(outputPath, func(w io.Writer) error {
+		if err := htmlHeadTemplate.Execute(w, reader); err != nil {
+			return err
+		}
+		for i, page := range h.pages {
+			if err := h.writePage(w, i, page, assetsDir); err != nil {
+				return err
+			}
+		}
+		return htmlTailTemplate.Execute(w, reader)
+	})
+}
+
+// writeAssets copies pages into assetsDir. The folder is assembled next to
+// the output and renamed into place.
+func (h *htmlArchive) writeAssets(outputDir, assetsDir string) error {
+	stageDir, err := os.MkdirTemp(outputDir, tempPattern)
+	if err != nil {
+		return err
+	}
+	if err := copyDir(h.tempDir, stageDir); err != nil {
+		_ = os.RemoveAll(stageDir)
+		return err
+	}
+	if err := os.Chmod(stageDir, 0755); err != nil {
+		_ = os.RemoveAll(stageDir)
+		return err
+	}
+	syncDir(stageDir)
+
+	if err := commitTempDir(stageDir, assetsDir); err != nil {
+		_ = os.RemoveAll(stageDir)
+		return err
+	}
+	return nil
+}
+
+// writePage writes an <img> of the page. The image is streamed as base64,
+// so the whole file is never kept in memory.
+func (h *htmlArchive) writePage(w io.Writer, index int, page fixedLayoutPage, assetsDir string) error {
+	_, err := fmt.Fprintf(w, `<img class="page" id="p%d" width="%d" height="%d" alt="%d" loading="lazy" src="`,
+		index+1, page.Width, page.Height, index+1)
+	if err != nil {
+		return err
+	}
+
+	if assetsDir != "" {
+		src := filepath.ToSlash(filepath.Join(filepath.Base(assetsDir), filepath.Base(page.Path)))
+		if _, err := io.WriteString(w, template.HTMLEscapeString(src)); err != nil {
+			return err
+		}
+	} else {
+		if _, err := fmt.Fprintf(w, "data:%s;base64,", page.MediaType()); err != nil {
+			return err
+		}
+		encoder := base64.NewEncoder(base64.StdEncoding, w)
+		if err := copyFileTo(encoder, page.Path); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, "\">\n")
+	return err
+}
+
+func (h *htmlArchive) Abort() error {
+	return os.RemoveAll(h.tempDir)
+}
+
+func (h *htmlArchive) BeginChapter(chapter Chapter) error {
+	h.chapterStarts[len(h.pages)] = chapter
+	return nil
+}
+
+func (h *htmlArchive) AddFile(fileExt string, imageBytes []byte) error {
+	fileExt, err := pageExt(fileExt, imageBytes)
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%03d.%s", len(h.pages)+1, fileExt)
+	filePath := filepath.Join(h.tempDir, fileName)
+	if err := os.WriteFile(filePath, imageBytes, 0644); err != nil {
+		return err
+	}
+
+	page := fixedLayoutPage{
+		Path:   filePath,
+		Ext:    fileExt,
+		Width:  defaultPageWidth,
+		Height: defaultPageHeight,
+	}
+	if width, height, err := getImageDimensions(imageBytes); err == nil {
+		page.Width, page.Height = int(width), int(height)
+	}
+	h.pages = append(h.pages, page)
+	return nil
+}
+
+// htmlReader is the data of the reader page.
+type htmlReader struct {
+	Title       string
+	Language    string
+	RightToLeft bool
+	Chapters    []navEntry
+}
+
+var htmlHeadTemplate = template.Must(template.New("head").Parse(`<!DOCTYPE html>
+<html lang="{{if .Language}}{{.Language}}{{else}}en{{end}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+html, body { margin: 0; padding: 0; background: #111; color: #ddd; font: 14px sans-serif; }
+#bar { position: fixed; top: 0; left: 0; right: 0; z-index: 2; display: flex; gap: 8px; align-items: center;
+  padding: 6px 10px; background: rgba(17, 17, 17, .9); }
+#bar .title { flex: 1; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+#bar button, #bar select { background: #333; color: #ddd; border: 1px solid #555; border-radius: 4px; padding: 3px 8px; }
+#pages { padding-top: 40px; text-align: center; }
+.page { display: block; margin: 0 auto; max-width: 100%; height: auto; }
+body:not(.webtoon) .page { max-height: calc(100vh - 40px); width: auto; cursor: pointer; }
+body:not(.webtoon) .page:not(.current) { display: none; }
+body.webtoon .page { width: 800px; }
+#help { color: #888; }
+</style>
+</head>
+<body{{if .RightToLeft}} class="rtl"{{end}}>
+<div id="bar">
+<span class="title">{{.Title}}</span>
+{{- if .Chapters}}
+<select id="chapters" title="Chapters">
+{{- range .Chapters}}
+<option value="{{.Page}}">{{.Title}}</option>
+{{- end}}
+</select>
+{{- end}}
+<button id="direction" title="Reading direction (d)"></button>
+<button id="mode" title="Webtoon mode (w)">Webtoon</button>
+<span id="counter"></span>
+<span id="help" title="Arrows, Space, Home and End turn pages">?</span>
+</div>
+<div id="pages">
+`))
+
+var htmlTailTemplate = template.Must(template.New("tail").Parse(`</div>
+<script>
+(function () {
+  var body = document.body;
+  var pages = Array.prototype.slice.call(document.querySelectorAll(".page"));
+  var chapters = document.getElementById("chapters");
+  var current = 0;
+
+  function isWebtoon() { return body.classList.contains("webtoon"); }
+  function isRtl() { return body.classList.contains("rtl"); }
+
+  function show(index, isScrolled) {
+    if (pages.length === 0) { return; }
+    current = Math.max(0, Math.min(pages.length - 1, index));
+    pages.forEach(function (page, i) { page.classList.toggle("current", i === current); });
+    if (isWebtoon()) {
+      if (isScrolled) { pages[current].scrollIntoView(); }
+    } else {
+      window.scrollTo(0, 0);
+    }
+    document.getElementById("counter").textContent = (current + 1) + " / " + pages.length;
+    if (chapters) {
+      var options = Array.prototype.slice.call(chapters.options);
+      options.forEach(function (option) {
+        if (Number(option.value) <= current + 1) { chapters.value = option.value; }
+      });
+    }
+    history.replaceState(null, "", "#p" + (current + 1));
+  }
+
+  function updateButtons() {
+    document.getElementById("direction").textContent = isRtl() ? "Right to left" : "Left to right";
+    document.getElementById("mode").textContent = isWebtoon() ? "Pages" : "Webtoon";
+  }
+
+  function forward() { show(current + 1, true); }
+  function back() { show(current - 1, true); }
+
+  function toggleDirection() { body.classList.toggle("rtl"); updateButtons(); }
+  function toggleMode() { body.classList.toggle("webtoon"); updateButtons(); show(current, true); }
+
+  document.addEventListener("keydown", function (event) {
+    if (event.target.tagName === "SELECT" || event.ctrlKey || event.metaKey || event.altKey) { return; }
+    switch (event.key) {
+    case "ArrowRight": isRtl() ? back() : forward(); break;
+    case "ArrowLeft": isRtl() ? forward() : back(); break;
+    case " ": case "PageDown": if (isWebtoon()) { return; } forward(); break;
+    case "PageUp": if (isWebtoon()) { return; } back(); break;
+    case "Home": show(0, true); break;
+    case "End": show(pages.length - 1, true); break;
+    case "d": toggleDirection(); break;
+    case "w": toggleMode(); break;
+    default: return;
+    }
+    event.preventDefault();
+  });
+
+  pages.forEach(function (page) {
+    page.addEventListener("click", function (event) {
+      if (isWebtoon()) { return; }
+      var isLeft = event.offsetX < page.clientWidth / 2;
+      isLeft === isRtl() ? forward() : back();
+    });
+  });
+
+  window.addEventListener("scroll", function () {
+    if (!isWebtoon()) { return; }
+    for (var i = 0; i < pages.length; i++) {
+      if (pages[i].getBoundingClientRect().bottom > 40) {
+        if (i !== current) { show(i, false); }
+        return;
+      }
+    }
+  });
+
+  if (chapters) {
+    chapters.addEventListener("change", function () { show(Number(chapters.value) - 1, true); });
+  }
+  document.getElementById("direction").addEventListener("click", toggleDirection);
+  document.getElementById("mode").addEventListener("click", toggleMode);
+
+  updateButtons();
+  var match = /^#p(\d+)$/.exec(location.hash);
+  show(match ? Number(match[1]) - 1 : 0, true);
+})();
+</script>
+</body>
+</html>
+`))
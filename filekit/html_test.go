@@ -0,0 +1,51 @@
+package filekit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+func TestHtmlReader(t *testing.T) {
+	m := metadata.Metadata{CI: metadata.ComicInfoMetadata{
+		Series: "Series",
+		Manga:  metadata.MANGA_RIGHT_TO_LEFT,
+	}}
+
+	for _, isAssets := range []bool{false, true} {
+		dir := t.TempDir()
+		writeMergedTestContainer(t, HTML_EXT, dir, Options{HtmlAssets: isAssets}, m)
+
+		content, err := os.ReadFile(filepath.Join(dir, "out.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		html := string(content)
+
+		expected := []string{
+			`<body class="rtl">`,
+			`<option value="1">Vol. 1 Ch. 1</option>`,
+			`<option value="3">Vol. 1 Ch. 2: End</option>`,
+			`id="p4" width="4" height="6"`,
+		}
+		if isAssets {
+			expected = append(expected, `src="out_files/004.png"`)
+			if _, err := os.Stat(filepath.Join(dir, "out_files", "004.png")); err != nil {
+				t.Errorf("Test Case: assets. Expected page in assets folder: %v", err)
+			}
+		} else {
+			expected = append(expected, `src="data:image/png;base64,`)
+		}
+		for _, e := range expected {
+			if !strings.Contains(html, e) {
+				t.Errorf("Test Case: assets %v. Expected %s in html", isAssets, e)
+			}
+		}
+		if count := strings.Count(html, `class="page"`); count != 4 {
+			t.Errorf("Test Case: assets %v. Expected 4 pages, but got %d", isAssets, count)
+		}
+	}
+}
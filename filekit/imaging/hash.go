@@ -0,0 +1,193 @@
+package imaging
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxHashDistance is the number of differing bits of both hashes up to which
+// pages are taken as the same. It tolerates re-encoding and resizing.
+const maxHashDistance = 10
+
+// PageHash is a perceptual hash of a page, similar pages have hashes with few
+// differing bits.
+type PageHash struct {
+	// DHash compares brightness of neighbouring pixels.
+	DHash uint64
+	// PHash compares low frequencies of the discrete cosine transform.
+	PHash uint64
+}
+
+// Hash returns the perceptual hash of img.
+func Hash(img image.Image) PageHash {
+	return PageHash{DHash: dHash(img), PHash: pHash(img)}
+}
+
+// IsSimilar reports whether both hashes of h and other are close.
+func (h PageHash) IsSimilar(other PageHash) bool {
+	return bits.OnesCount64(h.DHash^other.DHash) <= maxHashDistance &&
+		bits.OnesCount64(h.PHash^other.PHash) <= maxHashDistance
+}
+
+func (h PageHash) String() string {
+	return fmt.Sprintf("%016x %016x", h.DHash, h.PHash)
+}
+
+// ParsePageHash parses a hash in the String format.
+func ParsePageHash(s string) (PageHash, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return PageHash{}, fmt.Errorf("malformed page hash %q", s)
+	}
+	d, err := strconv.ParseUint(fields[0], 16, 64)
+	if err != nil {
+		return PageHash{}, fmt.Errorf("malformed page hash %q: %w", s, err)
+	}
+	p, err := strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		return PageHash{}, fmt.Errorf("malformed page hash %q: %w", s, err)
+	}
+	return PageHash{DHash: d, PHash: p}, nil
+}
+
+func dHash(img image.Image) uint64 {
+	small := Resize(Grayscale(img), 9, 8).(*image.Gray)
+	var hash uint64
+	for y := range 8 {
+		for x := range 8 {
+			hash <<= 1
+			if small.Pix[y*small.Stride+x] < small.Pix[y*small.Stride+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func pHash(img image.Image) uint64 {
+	const size = 32
+	small := Resize(Grayscale(img), size, size).(*image.Gray)
+
+	var cosines [8][size]float64
+	for u := range 8 {
+		for x := range size {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+
+	var coefficients [64]float64
+	for v := range 8 {
+		for u := range 8 {
+			sum := 0.0
+			for y := range size {
+				for x := range size {
+					sum += float64(small.Pix[y*small.Stride+x]) * cosines[u][x] * cosines[v][y]
+				}
+			}
+			coefficients[v*8+u] = sum
+		}
+	}
+
+	// the first coefficient is the average brightness, it is left out of
+	// the median
+	sorted := slices.Clone(coefficients[1:])
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for _, c := range coefficients {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// Blocklist is a list of hashes of pages to drop, e.g. scanlator credit and
+// recruitment pages. It is saved as a text file with a hash and a note per
+// line, lines starting with # are comments.
+type Blocklist struct {
+	Entries []BlocklistEntry
+}
+
+type BlocklistEntry struct {
+	Hash PageHash
+	Note string
+}
+
+// LoadBlocklist reads the blocklist at path, a missing file is an empty
+// blocklist.
+func LoadBlocklist(path string) (*Blocklist, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Blocklist{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadBlocklist(file)
+}
+
+func ReadBlocklist(r io.Reader) (*Blocklist, error) {
+	b := &Blocklist{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("malformed blocklist line %q", line)
+		}
+		hash, err := ParsePageHash(fields[0] + " " + fields[1])
+		if err != nil {
+			return nil, err
+		}
+		entry := BlocklistEntry{Hash: hash}
+		if len(fields) == 3 {
+			entry.Note = strings.TrimSpace(fields[2])
+		}
+		b.Entries = append(b.Entries, entry)
+	}
+	return b, scanner.Err()
+}
+
+// Add appends hash unless a similar one is already listed and reports
+// whether it was added.
+func (b *Blocklist) Add(hash PageHash, note string) bool {
+	if b.Contains(hash) {
+		return false
+	}
+	b.Entries = append(b.Entries, BlocklistEntry{Hash: hash, Note: note})
+	return true
+}
+
+// Contains reports whether a page with hash is blocked.
+func (b *Blocklist) Contains(hash PageHash) bool {
+	return slices.ContainsFunc(b.Entries, func(e BlocklistEntry) bool {
+		return e.Hash.IsSimilar(hash)
+	})
+}
+
+// Write writes the blocklist in the format ReadBlocklist reads.
+func (b *Blocklist) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# mdx blocklist: dhash phash note\n")
+	for _, e := range b.Entries {
+		fmt.Fprintf(bw, "%s %s\n", e.Hash, e.Note)
+	}
+	return bw.Flush()
+}
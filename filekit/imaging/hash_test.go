@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	newPage := func(width, height int, pattern func(x, y int) uint8) image.Image {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := range height {
+			for x := range width {
+				img.SetGray(x, y, color.Gray{Y: pattern(x*600/width, y*900/height)})
+			}
+		}
+		return img
+	}
+	credits := func(x, y int) uint8 {
+		return uint8(128 + 100*math.Sin(float64(x)/50)*math.Cos(float64(y)/70))
+	}
+	story := func(x, y int) uint8 {
+		return uint8(128 + 100*math.Cos(float64(x+y)/90))
+	}
+
+	page := newPage(600, 900, credits)
+	reencoded, err := EncodeJPEG(page, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(reencoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocklist := &Blocklist{}
+	if !blocklist.Add(Hash(page), "group credits") {
+		t.Fatal("Expected the page to be added to an empty blocklist")
+	}
+
+	content := new(bytes.Buffer)
+	if err := blocklist.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadBlocklist(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		img       image.Image
+		isBlocked bool
+	}{
+		{"same page", page, true},
+		{"resized and re-encoded page", Fit(decoded, 400, 600), true},
+		{"other page", newPage(600, 900, story), false},
+	}
+
+	for _, tc := range testCases {
+		if isBlocked := (Options{Blocklist: loaded}).IsBlocked(tc.img); isBlocked != tc.isBlocked {
+			t.Errorf("Test Case: %s. Expected blocked %v, but got %v", tc.name, tc.isBlocked, isBlocked)
+		}
+	}
+
+	if len(loaded.Entries) != 1 || loaded.Entries[0].Note != "group credits" {
+		t.Errorf("Expected the entry to be saved with the note, but got %v", loaded.Entries)
+	}
+	if loaded.Add(Hash(decoded), "again") {
+		t.Errorf("Expected a similar page is not added twice")
+	}
+
+	pages, err := Process(reencoded, "jpg", Options{Blocklist: &Blocklist{}})
+	if err != nil || len(pages) != 1 || !bytes.Equal(pages[0].Data, reencoded) {
+		t.Errorf("Expected page as is with an empty blocklist, but got %d pages, error %v", len(pages), err)
+	}
+}
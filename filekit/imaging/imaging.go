@@ -0,0 +1,116 @@
+// Package imaging prepares page images for reader devices: it scales them
+// down, converts them to grayscale and encodes them again. It only uses the
+// standard library image packages.
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+)
+
+// DEFAULT_JPEG_QUALITY is used when no quality is set.
+const DEFAULT_JPEG_QUALITY = 85
+
+// Decode decodes a JPEG, PNG or GIF image.
+func Decode(src []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(src))
+	return img, err
+}
+
+// EncodeJPEG encodes img as JPEG with quality from 1 to 100.
+func EncodeJPEG(img image.Image, quality int) ([]byte, error) {
+	if quality <= 0 || quality > 100 {
+		quality = DEFAULT_JPEG_QUALITY
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Grayscale converts img to 8-bit grayscale.
+func Grayscale(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+	return gray
+}
+
+// Fit scales img down to fit into maxWidth x maxHeight keeping the aspect
+// ratio. Smaller images are returned as is, readers scale them up better
+// than a box filter.
+func Fit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return img
+	}
+
+	scale := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	return Resize(img, max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale)))
+}
+
+// Resize scales img to width x height with a box filter, every destination
+// pixel is the average of the source pixels it covers.
+func Resize(img image.Image, width, height int) image.Image {
+	if gray, ok := img.(*image.Gray); ok {
+		dst := image.NewGray(image.Rect(0, 0, width, height))
+		resizePix(gray.Pix, gray.Stride, gray.Rect.Dx(), gray.Rect.Dy(), 1,
+			dst.Pix, dst.Stride, width, height)
+		return dst
+	}
+
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	resizePix(src.Pix, src.Stride, src.Rect.Dx(), src.Rect.Dy(), 4,
+		dst.Pix, dst.Stride, width, height)
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resizePix resizes pixels with channels bytes per pixel.
+func resizePix(src []uint8, srcStride, srcWidth, srcHeight, channels int,
+	dst []uint8, dstStride, dstWidth, dstHeight int) {
+	sums := make([]int, channels)
+	for dy := range dstHeight {
+		y0 := dy * srcHeight / dstHeight
+		y1 := max(y0+1, (dy+1)*srcHeight/dstHeight)
+		for dx := range dstWidth {
+			x0 := dx * srcWidth / dstWidth
+			x1 := max(x0+1, (dx+1)*srcWidth/dstWidth)
+
+			clear(sums)
+			for y := y0; y < y1; y++ {
+				row := src[y*srcStride:]
+				for x := x0; x < x1; x++ {
+					for c := range channels {
+						sums[c] += int(row[x*channels+c])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			offset := dy*dstStride + dx*channels
+			for c := range channels {
+				dst[offset+c] = uint8((sums[c] + count/2) / count)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	testCases := []struct {
+		name           string
+		width, height  int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{"smaller image is kept", 100, 200, 100, 200},
+		{"tall image", 2000, 3000, 1000, 1500},
+		{"wide image", 4000, 1000, 1000, 250},
+	}
+
+	for _, tc := range testCases {
+		img := Fit(image.NewGray(image.Rect(0, 0, tc.width, tc.height)), 1000, 1500)
+		bounds := img.Bounds()
+		if bounds.Dx() != tc.expectedWidth || bounds.Dy() != tc.expectedHeight {
+			t.Errorf("Test Case: %s. Expected %dx%d, but got %dx%d",
+				tc.name, tc.expectedWidth, tc.expectedHeight, bounds.Dx(), bounds.Dy())
+		}
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 2, 2))
+	src.SetGray(0, 0, color.Gray{Y: 0})
+	src.SetGray(1, 0, color.Gray{Y: 100})
+	src.SetGray(0, 1, color.Gray{Y: 100})
+	src.SetGray(1, 1, color.Gray{Y: 200})
+
+	dst := Resize(src, 1, 1).(*image.Gray)
+	if dst.Pix[0] != 100 {
+		t.Errorf("Expected average 100, but got %d", dst.Pix[0])
+	}
+
+	rgba := Grayscale(image.NewRGBA(image.Rect(0, 0, 3, 3)))
+	if rgba.Bounds().Dx() != 3 {
+		t.Errorf("Expected grayscale image of the same size, but got %v", rgba.Bounds())
+	}
+}
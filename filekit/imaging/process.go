@@ -0,0 +1,266 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+)
+
+const (
+	// cropTolerance is how far a pixel may differ from the border color.
+	cropTolerance = 24
+	// cropNoise is the share of pixels in a border line that may differ,
+	// e.g. scan dust or page numbers.
+	cropNoise = 0.005
+)
+
+// Options configures Process. The zero value keeps pages as is.
+type Options struct {
+	// Width and Height are the screen size pages are fitted to, 0 keeps the
+	// size.
+	Width  int
+	Height int
+	// Grayscale converts pages to grayscale.
+	Grayscale bool
+	// Gamma above 1 darkens midtones, which makes line art more readable on
+	// e-ink. 0 and 1 keep pages as is.
+	Gamma float64
+	// Contrast multiplies the distance from middle gray. 0 and 1 keep pages
+	// as is.
+	Contrast float64
+	// AutoCrop removes white and black borders.
+	AutoCrop bool
+	// Quality of re-encoded JPEG pages, 0 means DEFAULT_JPEG_QUALITY. Any
+	// quality encodes processed pages as JPEG.
+	Quality int
+	// Spread is what to do with double-page spreads, empty means SPREAD_KEEP.
+	Spread string
+	// RightToLeft is the reading direction of split and rotated spreads.
+	RightToLeft bool
+	// Webtoon stitches pages of a chapter and slices them again, see Strip.
+	Webtoon bool
+	// WebtoonHeight is the height of webtoon pages, 0 takes the screen
+	// ratio.
+	WebtoonHeight int
+	// Blocklist drops listed pages, e.g. scanlator credits, nil keeps all.
+	Blocklist *Blocklist
+}
+
+// Page is an encoded page image.
+type Page struct {
+	Data []byte
+	Ext  string
+}
+
+// IsBlocked reports whether img is in the blocklist.
+func (o Options) IsBlocked(img image.Image) bool {
+	return o.hasBlocklist() && o.Blocklist.Contains(Hash(img))
+}
+
+// hasBlocklist reports whether pages are checked against a blocklist, an
+// empty one blocks nothing.
+func (o Options) hasBlocklist() bool {
+	return o.Blocklist != nil && len(o.Blocklist.Entries) > 0
+}
+
+// IsEnabled reports whether Process changes pages other than spreads.
+func (o Options) IsEnabled() bool {
+	return o.Width > 0 || o.Height > 0 || o.Grayscale || o.AutoCrop || o.Quality > 0 ||
+		(o.Gamma != 0 && o.Gamma != 1) || (o.Contrast != 0 && o.Contrast != 1)
+}
+
+func (o Options) isSpreadChanged() bool {
+	return o.Spread != "" && o.Spread != SPREAD_KEEP
+}
+
+// Process applies opts to a page and encodes the resulting pages, see
+// encode. A split spread gives two pages, a blocked page gives none. Pages
+// in unknown formats and pages not changed by opts are returned as is.
+func Process(src []byte, ext string, opts Options) ([]Page, error) {
+	original := []Page{{Data: src, Ext: ext}}
+	if !opts.IsEnabled() && !opts.isSpreadChanged() && !opts.hasBlocklist() {
+		return original, nil
+	}
+	img, format, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return original, nil
+	}
+	if opts.IsBlocked(img) {
+		return nil, nil
+	}
+	bounds := img.Bounds()
+	if !opts.IsEnabled() && !(opts.isSpreadChanged() && IsSpread(bounds.Dx(), bounds.Dy())) {
+		return original, nil
+	}
+
+	pages := []Page{}
+	for _, part := range Spread(img, opts.Spread, opts.RightToLeft) {
+		page, err := encode(processImage(part, opts), format, opts)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// encode encodes a processed page in the format of its source. JPEG
+// sources and pages resized or given a quality are encoded as JPEG, other
+// pages as PNG, so no quality and no transparency is lost.
+func encode(img image.Image, format string, opts Options) (Page, error) {
+	if format != "jpeg" && opts.Quality == 0 && opts.Width == 0 && opts.Height == 0 {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, img); err != nil {
+			return Page{}, err
+		}
+		return Page{Data: buf.Bytes(), Ext: "png"}, nil
+	}
+
+	data, err := EncodeJPEG(img, opts.Quality)
+	if err != nil {
+		return Page{}, err
+	}
+	return Page{Data: data, Ext: "jpg"}, nil
+}
+
+// processImage applies opts other than spread modes.
+func processImage(img image.Image, opts Options) image.Image {
+	if opts.AutoCrop {
+		img = Crop(img)
+	}
+	if opts.Width > 0 || opts.Height > 0 {
+		width, height := opts.Width, opts.Height
+		if width == 0 {
+			width = math.MaxInt32
+		}
+		if height == 0 {
+			height = math.MaxInt32
+		}
+		img = Fit(img, width, height)
+	}
+	if opts.Grayscale {
+		img = Grayscale(img)
+	}
+	return Adjust(img, opts.Gamma, opts.Contrast)
+}
+
+// Adjust applies gamma and contrast to every channel.
+func Adjust(img image.Image, gamma, contrast float64) image.Image {
+	if (gamma == 0 || gamma == 1) && (contrast == 0 || contrast == 1) {
+		return img
+	}
+	if gamma == 0 {
+		gamma = 1
+	}
+	if contrast == 0 {
+		contrast = 1
+	}
+
+	var table [256]uint8
+	for i := range table {
+		v := math.Pow(float64(i)/255, gamma) * 255
+		v = (v-127.5)*contrast + 127.5
+		table[i] = uint8(math.Round(min(255, max(0, v))))
+	}
+
+	if gray, ok := img.(*image.Gray); ok {
+		dst := image.NewGray(image.Rect(0, 0, gray.Rect.Dx(), gray.Rect.Dy()))
+		for y := range dst.Rect.Dy() {
+			src := gray.Pix[y*gray.Stride : y*gray.Stride+dst.Stride]
+			for x, v := range src {
+				dst.Pix[y*dst.Stride+x] = table[v]
+			}
+		}
+		return dst
+	}
+
+	rgba := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, rgba.Rect.Dx(), rgba.Rect.Dy()))
+	for y := range dst.Rect.Dy() {
+		src := rgba.Pix[y*rgba.Stride : y*rgba.Stride+dst.Stride]
+		for x, v := range src {
+			if x%4 == 3 {
+				dst.Pix[y*dst.Stride+x] = v
+				continue
+			}
+			dst.Pix[y*dst.Stride+x] = table[v]
+		}
+	}
+	return dst
+}
+
+// Crop removes uniform white or black borders. Every side is cropped
+// separately and at most a quarter of the page is removed from each side.
+func Crop(img image.Image) image.Image {
+	gray := Grayscale(img)
+	bounds := gray.Rect
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 8 || height < 8 {
+		return img
+	}
+
+	row := func(y int) []uint8 {
+		return gray.Pix[y*gray.Stride : y*gray.Stride+width]
+	}
+	column := func(x int) []uint8 {
+		pixels := make([]uint8, height)
+		for y := range height {
+			pixels[y] = gray.Pix[y*gray.Stride+x]
+		}
+		return pixels
+	}
+
+	top := cropSide(height/4, func(i int) []uint8 { return row(i) })
+	bottom := cropSide(height/4, func(i int) []uint8 { return row(height - 1 - i) })
+	left := cropSide(width/4, func(i int) []uint8 { return column(i) })
+	right := cropSide(width/4, func(i int) []uint8 { return column(width - 1 - i) })
+	if top+bottom+left+right == 0 {
+		return img
+	}
+
+	return subImage(img, image.Rect(left, top, width-right, height-bottom).Add(img.Bounds().Min))
+}
+
+// cropSide returns the number of border lines from a side. The border color
+// is taken from the outermost line.
+func cropSide(limit int, line func(i int) []uint8) int {
+	border, ok := borderColor(line(0))
+	if !ok {
+		return 0
+	}
+	count := 0
+	for count < limit && isBorderLine(line(count), border) {
+		count++
+	}
+	return count
+}
+
+func borderColor(pixels []uint8) (uint8, bool) {
+	sum := 0
+	for _, v := range pixels {
+		sum += int(v)
+	}
+	mean := sum / len(pixels)
+	switch {
+	case mean >= 255-cropTolerance:
+		return 255, true
+	case mean <= cropTolerance:
+		return 0, true
+	}
+	return 0, false
+}
+
+func isBorderLine(pixels []uint8, border uint8) bool {
+	allowed := int(float64(len(pixels)) * cropNoise)
+	different := 0
+	for _, v := range pixels {
+		if diff := int(v) - int(border); diff > cropTolerance || diff < -cropTolerance {
+			different++
+			if different > allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
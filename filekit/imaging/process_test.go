@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestCrop(t *testing.T) {
+	testCases := []struct {
+		name           string
+		border         color.Gray
+		content        image.Rectangle
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{"white border", color.Gray{Y: 255}, image.Rect(10, 20, 90, 180), 80, 160},
+		{"black border", color.Gray{Y: 0}, image.Rect(5, 5, 95, 195), 90, 190},
+		{"crop is limited", color.Gray{Y: 255}, image.Rect(40, 90, 60, 110), 50, 100},
+	}
+
+	for _, tc := range testCases {
+		img := image.NewGray(image.Rect(0, 0, 100, 200))
+		draw.Draw(img, img.Bounds(), image.NewUniform(tc.border), image.Point{}, draw.Src)
+		draw.Draw(img, tc.content, image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+
+		bounds := Crop(img).Bounds()
+		if bounds.Dx() != tc.expectedWidth || bounds.Dy() != tc.expectedHeight {
+			t.Errorf("Test Case: %s. Expected %dx%d, but got %dx%d",
+				tc.name, tc.expectedWidth, tc.expectedHeight, bounds.Dx(), bounds.Dy())
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 600))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 200, G: 50, B: 50, A: 255}), image.Point{}, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := Process(buf.Bytes(), "png", Options{Spread: SPREAD_SPLIT})
+	if err != nil || len(pages) != 1 || pages[0].Ext != "png" || !bytes.Equal(pages[0].Data, buf.Bytes()) {
+		t.Errorf("Expected page as is without options, but got %d pages, error %v", len(pages), err)
+	}
+
+	pages, err = Process(buf.Bytes(), "png",
+		Options{Width: 200, Height: 200, Grayscale: true, Gamma: 1.2, Contrast: 1.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0].Ext != "jpg" {
+		t.Fatalf("Expected a jpg page, but got %v", pages)
+	}
+	result, format, err := image.Decode(bytes.NewReader(pages[0].Data))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("Expected jpeg page, but got %s, error %v", format, err)
+	}
+	if bounds := result.Bounds(); bounds.Dx() != 133 || bounds.Dy() != 200 {
+		t.Errorf("Expected 133x200 page, but got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+	if _, ok := result.(*image.Gray); !ok {
+		t.Errorf("Expected grayscale page, but got %T", result)
+	}
+
+	// a transparent png with a white border is cropped and stays a png
+	img = image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(10, 10, 90, 90), image.NewUniform(color.RGBA{R: 100, A: 100}), image.Point{}, draw.Src)
+	buf.Reset()
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	pages, err = Process(buf.Bytes(), "png", Options{AutoCrop: true})
+	if err != nil || len(pages) != 1 || pages[0].Ext != "png" {
+		t.Fatalf("Expected a png page, but got %v, error %v", pages, err)
+	}
+	result, err = png.Decode(bytes.NewReader(pages[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := result.Bounds(); bounds.Dx() != 80 {
+		t.Errorf("Expected cropped page of width 80, but got %d", bounds.Dx())
+	}
+	if _, _, _, a := result.At(40, 40).RGBA(); a>>8 != 100 {
+		t.Errorf("Expected transparency kept, but got alpha %d", a>>8)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	// the left half is black, the right half is white
+	img := image.NewGray(image.Rect(0, 0, 400, 300))
+	draw.Draw(img, image.Rect(200, 0, 400, 300), image.NewUniform(color.Gray{Y: 255}), image.Point{}, draw.Src)
+
+	testCases := []struct {
+		name        string
+		mode        string
+		rightToLeft bool
+		// expectedFirst is the color of the top left pixel of each page
+		expectedFirst []uint8
+		expectedWidth int
+	}{
+		{"keep", SPREAD_KEEP, false, []uint8{0}, 400},
+		{"split left to right", SPREAD_SPLIT, false, []uint8{0, 255}, 200},
+		{"split right to left", SPREAD_SPLIT, true, []uint8{255, 0}, 200},
+		{"rotate left to right", SPREAD_ROTATE, false, []uint8{0}, 300},
+		{"rotate right to left", SPREAD_ROTATE, true, []uint8{255}, 300},
+	}
+
+	for _, tc := range testCases {
+		pages := Spread(img, tc.mode, tc.rightToLeft)
+		if len(pages) != len(tc.expectedFirst) {
+			t.Errorf("Test Case: %s. Expected %d pages, but got %d",
+				tc.name, len(tc.expectedFirst), len(pages))
+			continue
+		}
+		for i, page := range pages {
+			bounds := page.Bounds()
+			first := Grayscale(page).GrayAt(bounds.Min.X, bounds.Min.Y).Y
+			if first != tc.expectedFirst[i] || bounds.Dx() != tc.expectedWidth {
+				t.Errorf("Test Case: %s. Expected page %d of width %d starting with %d, but got width %d starting with %d",
+					tc.name, i, tc.expectedWidth, tc.expectedFirst[i], bounds.Dx(), first)
+			}
+		}
+	}
+
+	if pages := Spread(image.NewGray(image.Rect(0, 0, 300, 400)), SPREAD_SPLIT, false); len(pages) != 1 {
+		t.Errorf("Expected a single page is kept, but got %d pages", len(pages))
+	}
+}
@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"image"
+	"slices"
+)
+
+// Modes of double-page spreads.
+const (
+	// SPREAD_KEEP stores spreads as is, containers mark them as double pages.
+	SPREAD_KEEP = "keep"
+	// SPREAD_SPLIT cuts spreads into two pages in reading order.
+	SPREAD_SPLIT = "split"
+	// SPREAD_ROTATE turns spreads to landscape, so they fill a portrait
+	// screen.
+	SPREAD_ROTATE = "rotate"
+)
+
+var spreadModes = []string{SPREAD_KEEP, SPREAD_SPLIT, SPREAD_ROTATE}
+
+func IsNotSupportedSpread(mode string) bool {
+	return !slices.Contains(spreadModes, mode)
+}
+
+// spreadRatio is the width to height ratio above which a page is a spread.
+// Single pages are about 0.7, spreads about 1.4.
+const spreadRatio = 1.0
+
+// IsSpread reports whether a page of width x height is a double-page spread.
+func IsSpread(width, height int) bool {
+	return height > 0 && float64(width)/float64(height) > spreadRatio
+}
+
+// Spread applies mode to img. Pages which are not spreads and the keep mode
+// return img as is. rightToLeft puts the right half first when splitting and
+// on top when rotating.
+func Spread(img image.Image, mode string, rightToLeft bool) []image.Image {
+	bounds := img.Bounds()
+	if !IsSpread(bounds.Dx(), bounds.Dy()) {
+		return []image.Image{img}
+	}
+
+	switch mode {
+	case SPREAD_SPLIT:
+		middle := bounds.Min.X + bounds.Dx()/2
+		left := subImage(img, image.Rect(bounds.Min.X, bounds.Min.Y, middle, bounds.Max.Y))
+		right := subImage(img, image.Rect(middle, bounds.Min.Y, bounds.Max.X, bounds.Max.Y))
+		if rightToLeft {
+			return []image.Image{right, left}
+		}
+		return []image.Image{left, right}
+	case SPREAD_ROTATE:
+		return []image.Image{Rotate(img, !rightToLeft)}
+	}
+	return []image.Image{img}
+}
+
+// Rotate turns img by 90 degrees clockwise or counterclockwise.
+func Rotate(img image.Image, clockwise bool) image.Image {
+	if gray, ok := img.(*image.Gray); ok {
+		dst := image.NewGray(image.Rect(0, 0, gray.Rect.Dy(), gray.Rect.Dx()))
+		rotatePix(gray.Pix, gray.Stride, gray.Rect.Dx(), gray.Rect.Dy(), 1,
+			dst.Pix, dst.Stride, clockwise)
+		return dst
+	}
+
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, src.Rect.Dy(), src.Rect.Dx()))
+	rotatePix(src.Pix, src.Stride, src.Rect.Dx(), src.Rect.Dy(), 4,
+		dst.Pix, dst.Stride, clockwise)
+	return dst
+}
+
+// rotatePix rotates pixels with channels bytes per pixel into a destination
+// of srcHeight x srcWidth.
+func rotatePix(src []uint8, srcStride, srcWidth, srcHeight, channels int,
+	dst []uint8, dstStride int, clockwise bool) {
+	for y := range srcHeight {
+		for x := range srcWidth {
+			dx, dy := srcHeight-1-y, x
+			if !clockwise {
+				dx, dy = y, srcWidth-1-x
+			}
+			copy(dst[dy*dstStride+dx*channels:][:channels], src[y*srcStride+x*channels:][:channels])
+		}
+	}
+}
+
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	return toRGBA(img).SubImage(rect.Sub(img.Bounds().Min))
+}
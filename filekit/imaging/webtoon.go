@@ -0,0 +1,172 @@
+package imaging
+
+import (
+	"image"
+	"slices"
+)
+
+// Webtoon modes, auto turns it on for manga tagged "Long Strip".
+const (
+	WEBTOON_AUTO = "auto"
+	WEBTOON_ON   = "on"
+	WEBTOON_OFF  = "off"
+)
+
+var webtoonModes = []string{WEBTOON_AUTO, WEBTOON_ON, WEBTOON_OFF}
+
+func IsNotSupportedWebtoonMode(mode string) bool {
+	return !slices.Contains(webtoonModes, mode)
+}
+
+// IsWebtoon reports whether pages are stitched and sliced again, in auto
+// mode only pages of Long Strip manga are.
+func IsWebtoon(mode string, isLongStrip bool) bool {
+	switch mode {
+	case WEBTOON_ON:
+		return true
+	case WEBTOON_AUTO:
+		return isLongStrip
+	}
+	return false
+}
+
+const (
+	// defaultSliceRatio is the height to width ratio of webtoon pages when
+	// there is no screen size.
+	defaultSliceRatio = 1.5
+	// minSliceShare is the shortest page as a share of the slice height,
+	// cuts are searched between it and the full height.
+	minSliceShare = 0.6
+	// flatRowDetail is the detail per pixel of a row that is taken as a
+	// gutter without looking further.
+	flatRowDetail = 2
+)
+
+// SliceHeight returns the height of webtoon pages for strips of width.
+func (o Options) SliceHeight(width int) int {
+	if o.WebtoonHeight > 0 {
+		return o.WebtoonHeight
+	}
+	ratio := defaultSliceRatio
+	if o.Width > 0 && o.Height > 0 {
+		ratio = float64(o.Height) / float64(o.Width)
+	}
+	return max(1, int(float64(width)*ratio))
+}
+
+// Strip stitches webtoon images vertically and slices them into pages.
+// Only the rows of the unfinished page are kept in memory.
+type Strip struct {
+	opts Options
+	// width is the width of the first image, other images are scaled to it
+	width       int
+	sliceHeight int
+	pix         []uint8
+	rows        int
+}
+
+func NewStrip(opts Options) *Strip {
+	return &Strip{opts: opts}
+}
+
+// Add appends img to the bottom of the strip and returns finished pages.
+func (s *Strip) Add(img image.Image) []image.Image {
+	bounds := img.Bounds()
+	if s.width == 0 {
+		s.width = bounds.Dx()
+		s.sliceHeight = s.opts.SliceHeight(s.width)
+	}
+	if bounds.Dx() != s.width {
+		height := max(1, bounds.Dy()*s.width/bounds.Dx())
+		img = Resize(img, s.width, height)
+	}
+
+	rgba := toRGBA(img)
+	rowSize := s.width * 4
+	for y := range rgba.Rect.Dy() {
+		s.pix = append(s.pix, rgba.Pix[y*rgba.Stride:y*rgba.Stride+rowSize]...)
+	}
+	s.rows += rgba.Rect.Dy()
+
+	pages := []image.Image{}
+	for s.rows >= s.sliceHeight {
+		pages = append(pages, s.cut(s.findCut()))
+	}
+	return pages
+}
+
+// Flush returns the rest of the strip as the last page.
+func (s *Strip) Flush() []image.Image {
+	if s.rows == 0 {
+		return nil
+	}
+	return []image.Image{s.cut(s.rows)}
+}
+
+// findCut returns the row to cut the next page at. It is the flattest row,
+// e.g. whitespace or a gutter between panels, near the slice height, so
+// speech bubbles and panels are not cut in half.
+func (s *Strip) findCut() int {
+	if s.rows <= s.sliceHeight {
+		return s.rows
+	}
+	lowest := int(float64(s.sliceHeight) * minSliceShare)
+	best, bestDetail := s.sliceHeight, -1
+	for y := s.sliceHeight; y >= max(1, lowest); y-- {
+		detail := s.rowDetail(y)
+		if detail <= flatRowDetail*s.width {
+			return y
+		}
+		if bestDetail == -1 || detail < bestDetail {
+			best, bestDetail = y, detail
+		}
+	}
+	return best
+}
+
+// rowDetail sums the differences of row y from its left and upper
+// neighbours, it is 0 for a row of one color continuing the row above.
+func (s *Strip) rowDetail(y int) int {
+	rowSize := s.width * 4
+	row := s.pix[y*rowSize : (y+1)*rowSize]
+	above := s.pix[(y-1)*rowSize : y*rowSize]
+	detail := 0
+	for x := 0; x < rowSize; x++ {
+		if x%4 == 3 {
+			continue
+		}
+		detail += abs(int(row[x]) - int(above[x]))
+		if x >= 4 {
+			detail += abs(int(row[x]) - int(row[x-4]))
+		}
+	}
+	return detail / 3
+}
+
+// cut returns rows above y as a page and removes them from the strip.
+func (s *Strip) cut(y int) image.Image {
+	rowSize := s.width * 4
+	page := image.NewRGBA(image.Rect(0, 0, s.width, y))
+	copy(page.Pix, s.pix[:y*rowSize])
+	s.pix = append(s.pix[:0], s.pix[y*rowSize:]...)
+	s.rows -= y
+	return page
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// EncodePage applies opts other than spreads and border cropping to a
+// webtoon page and encodes it as JPEG.
+func EncodePage(img image.Image, opts Options) (Page, error) {
+	opts.AutoCrop = false
+	processed, err := EncodeJPEG(processImage(img, opts), opts.Quality)
+	if err != nil {
+		return Page{}, err
+	}
+	return Page{Data: processed, Ext: "jpg"}, nil
+}
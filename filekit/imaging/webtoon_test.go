@@ -0,0 +1,64 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	// panels of 60 noisy rows divided by white gutters of 20 rows
+	newImage := func(width, height, offset int) image.Image {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := range height {
+			for x := range width {
+				v := uint8(255)
+				if (y+offset)%80 < 60 {
+					v = uint8((x*7 + y*13) % 200)
+				}
+				img.SetGray(x, y, color.Gray{Y: v})
+			}
+		}
+		return img
+	}
+
+	strip := NewStrip(Options{WebtoonHeight: 200})
+	pages := []image.Image{}
+	offset := 0
+	for _, height := range []int{150, 330, 90, 250} {
+		pages = append(pages, strip.Add(newImage(100, height, offset))...)
+		offset += height
+	}
+	total := 0
+	for i, page := range pages {
+		total += page.Bounds().Dy()
+		if total%80 <= 60 {
+			t.Errorf("Expected page %d to end in a gutter, but it ends at row %d", i, total)
+		}
+	}
+
+	// wider images are scaled to the strip width
+	pages = append(pages, strip.Add(newImage(200, 60, 0))...)
+	offset += 30
+	pages = append(pages, strip.Flush()...)
+
+	total = 0
+	for i, page := range pages {
+		bounds := page.Bounds()
+		if bounds.Dx() != 100 || bounds.Dy() > 200 {
+			t.Errorf("Expected page %d no larger than 100x200, but got %dx%d", i, bounds.Dx(), bounds.Dy())
+		}
+		total += bounds.Dy()
+	}
+	if total != offset {
+		t.Errorf("Expected %d rows in pages, but got %d", offset, total)
+	}
+
+	flat := NewStrip(Options{Width: 100, Height: 300})
+	white := image.NewGray(image.Rect(0, 0, 100, 1000))
+	draw.Draw(white, white.Bounds(), image.NewUniform(color.Gray{Y: 255}), image.Point{}, draw.Src)
+	if pages := flat.Add(white); len(pages) != 3 || pages[0].Bounds().Dy() != 300 {
+		t.Errorf("Expected 3 pages cut near the screen height, but got %d", len(pages))
+	}
+}
@@ -0,0 +1,72 @@
+package filekit
+
+import (
+	"fmt"
+
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// KINDLE_DEVICE is the device profile of Kindle files.
+const KINDLE_DEVICE = "kindle-paperwhite"
+
+// kindleArchive is a fixed-layout EPUB with the metadata Kindle Comic
+// Creator writes. Send to Kindle converts it to KF8 keeping the fixed layout.
+type kindleArchive struct {
+	*epubArchive
+	device Device
+}
+
+func newKindleArchive(opts Options) (*kindleArchive, error) {
+	e, err := newEpubArchive(opts)
+	if err != nil {
+		return nil, err
+	}
+	device, _ := DeviceProfile(KINDLE_DEVICE)
+	return &kindleArchive{epubArchive: e, device: device}, nil
+}
+
+func (k *kindleArchive) WriteOnDiskAndClose(outputDir string, outputFileName string,
+	m metadata.Metadata, chapterRange string) error {
+	defer k.Abort()
+
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, KINDLE_EXT, k.opts.OnExists)
+	if err != nil {
+		return err
+	}
+
+	book := k.fixedLayoutBook(m, chapterRange)
+	writingMode := "horizontal-lr"
+	if book.RightToLeft {
+		writingMode = "horizontal-rl"
+	}
+	book.Meta = append(book.Meta,
+		[2]string{"original-resolution", fmt.Sprintf("%dx%d", k.device.Width, k.device.Height)},
+		[2]string{"book-type", "comic"},
+		[2]string{"primary-writing-mode", writingMode},
+		[2]string{"region-mag", "true"},
+		[2]string{"zero-gutter", "true"},
+		[2]string{"zero-margin", "true"},
+		[2]string{"orientation-lock", "none"},
+		[2]string{"ke-border-color", "#FFFFFF"},
+		[2]string{"ke-border-width", "0"},
+	)
+
+	return writeFileAtomic(outputPath, book.write)
+}
+
+// AddFile fits the page to the screen and converts it to grayscale. Pages
+// that can't be decoded are stored as is.
+func (k *kindleArchive) AddFile(fileExt string, imageBytes []byte) error {
+	img, err := imaging.Decode(imageBytes)
+	if err != nil {
+		return k.epubArchive.AddFile(fileExt, imageBytes)
+	}
+
+	page := imaging.Fit(imaging.Grayscale(img), k.device.Width, k.device.Height)
+	pageBytes, err := imaging.EncodeJPEG(page, imaging.DEFAULT_JPEG_QUALITY)
+	if err != nil {
+		return err
+	}
+	return k.epubArchive.AddFile("jpg", pageBytes)
+}
@@ -0,0 +1,72 @@
+package filekit
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+func TestKindleArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := NewContainer(KINDLE_EXT, Options{WorkDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := image.NewRGBA(image.Rect(0, 0, 2000, 3000))
+	for i := range page.Pix {
+		page.Pix[i] = 200
+	}
+	page.Set(0, 0, color.RGBA{R: 255, A: 255})
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, page); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddFile("png", buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	m := metadata.Metadata{CI: metadata.ComicInfoMetadata{Manga: metadata.MANGA_RIGHT_TO_LEFT}}
+	if err := c.WriteOnDiskAndClose(dir, "out", m, ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	r, err := zip.OpenReader(filepath.Join(dir, "out.epub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	opf := readZipEntry(t, r, "OEBPS/content.opf")
+	for _, expected := range []string{
+		`<meta name="original-resolution" content="1236x1648"/>`,
+		`<meta name="book-type" content="comic"/>`,
+		`<meta name="primary-writing-mode" content="horizontal-rl"/>`,
+		`<meta name="region-mag" content="true"/>`,
+		`page-progression-direction="rtl"`,
+	} {
+		if !strings.Contains(opf, expected) {
+			t.Errorf("Expected %s in content.opf", expected)
+		}
+	}
+
+	img, format, err := image.Decode(strings.NewReader(readZipEntry(t, r, "OEBPS/images/page-001.jpg")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" {
+		t.Errorf("Expected jpeg page, but got %s", format)
+	}
+	if _, ok := img.(*image.Gray); !ok {
+		t.Errorf("Expected grayscale page, but got %T", img)
+	}
+	if bounds := img.Bounds(); bounds.Dx() > 1236 || bounds.Dy() > 1648 {
+		t.Errorf("Expected page to fit 1236x1648, but got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
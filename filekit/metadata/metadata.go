@@ -2,7 +2,9 @@ package metadata
 
 import (
 	"encoding/xml"
-	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,21 +23,82 @@ type PlainMetadata struct {
 	Tags    string
 }
 
+// ComicInfoMetadata is ComicInfo.xml of the ComicInfo 2.1 schema
+// (https://anansi-project.github.io/docs/comicinfo/schemas/v2.1). Fields
+// follow the order of the schema sequence.
 type ComicInfoMetadata struct {
-	XMLName     xml.Name `xml:"ComicInfo"`
-	Title       string   `xml:"Title"`
-	Number      string   `xml:"Number,omitempty"`
-	Volume      string   `xml:"Volume,omitempty"`
-	Year        int      `xml:"Year"`
-	Writer      string   `xml:"Writer"`
-	Penciller   string   `xml:"Penciller"`
-	Inker       string   `xml:"Inker"`
-	Publisher   string   `xml:"Publisher"`
-	PageCount   int      `xml:"PageCount"`
-	LanguageISO string   `xml:"LanguageISO"`
-	Format      string   `xml:"Format"`
-	Manga       string   `xml:"Manga"`
-	Summary     string   `xml:"Summary"`
+	XMLName xml.Name `xml:"ComicInfo"`
+	Title   string   `xml:"Title,omitempty"`
+	Series  string   `xml:"Series,omitempty"`
+	// LocalizedSeries is the series title in the language of the translation.
+	LocalizedSeries string `xml:"LocalizedSeries,omitempty"`
+	Number          string `xml:"Number,omitempty"`
+	Count           int    `xml:"Count,omitempty"`
+	Volume          string `xml:"Volume,omitempty"`
+	AlternateSeries string `xml:"AlternateSeries,omitempty"`
+	AlternateNumber string `xml:"AlternateNumber,omitempty"`
+	AlternateCount  int    `xml:"AlternateCount,omitempty"`
+	Summary         string `xml:"Summary,omitempty"`
+	Notes           string `xml:"Notes,omitempty"`
+	Year            int    `xml:"Year,omitempty"`
+	Month           int    `xml:"Month,omitempty"`
+	Day             int    `xml:"Day,omitempty"`
+	Writer          string `xml:"Writer,omitempty"`
+	Penciller       string `xml:"Penciller,omitempty"`
+	Inker           string `xml:"Inker,omitempty"`
+	Colorist        string `xml:"Colorist,omitempty"`
+	Letterer        string `xml:"Letterer,omitempty"`
+	CoverArtist     string `xml:"CoverArtist,omitempty"`
+	Editor          string `xml:"Editor,omitempty"`
+	Translator      string `xml:"Translator,omitempty"`
+	Publisher       string `xml:"Publisher,omitempty"`
+	Imprint         string `xml:"Imprint,omitempty"`
+	Genre           string `xml:"Genre,omitempty"`
+	Tags            string `xml:"Tags,omitempty"`
+	Web             string `xml:"Web,omitempty"`
+	PageCount       int    `xml:"PageCount"`
+	LanguageISO     string `xml:"LanguageISO,omitempty"`
+	Format          string `xml:"Format,omitempty"`
+	BlackAndWhite   string `xml:"BlackAndWhite,omitempty"`
+	Manga           string `xml:"Manga,omitempty"`
+	// Characters is not set by NewMetadata, MangaDex has no characters. It is
+	// kept, so files converted or merged by mdx keep characters of other
+	// taggers.
+	Characters          string          `xml:"Characters,omitempty"`
+	Teams               string          `xml:"Teams,omitempty"`
+	Locations           string          `xml:"Locations,omitempty"`
+	ScanInformation     string          `xml:"ScanInformation,omitempty"`
+	StoryArc            string          `xml:"StoryArc,omitempty"`
+	StoryArcNumber      string          `xml:"StoryArcNumber,omitempty"`
+	SeriesGroup         string          `xml:"SeriesGroup,omitempty"`
+	AgeRating           string          `xml:"AgeRating,omitempty"`
+	Pages               []ComicPageInfo `xml:"Pages>Page,omitempty"`
+	CommunityRating     string          `xml:"CommunityRating,omitempty"`
+	MainCharacterOrTeam string          `xml:"MainCharacterOrTeam,omitempty"`
+	Review              string          `xml:"Review,omitempty"`
+	GTIN                string          `xml:"GTIN,omitempty"`
+}
+
+// ComicPageInfo describes a page of the archive. Image is the zero-based
+// index of the page in the archive.
+type ComicPageInfo struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	DoublePage  bool   `xml:"DoublePage,attr,omitempty"`
+	ImageSize   int64  `xml:"ImageSize,attr,omitempty"`
+	Key         string `xml:"Key,attr,omitempty"`
+	Bookmark    string `xml:"Bookmark,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+}
+
+// MarshalComicInfo returns ComicInfo.xml content with the XML header.
+func (ci ComicInfoMetadata) MarshalComicInfo() ([]byte, error) {
+	content, err := xml.MarshalIndent(ci, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
 }
 
 type ComicBookMetadata struct {
@@ -60,16 +123,49 @@ type Credit struct {
 	Role   string `json:"role"`
 }
 
+const (
+	MANGA_NO  = "No"
+	MANGA_YES = "Yes"
+	// MANGA_RIGHT_TO_LEFT marks manga read from right to left.
+	MANGA_RIGHT_TO_LEFT = "YesAndRightToLeft"
+
+	AGE_RATING_EVERYONE    = "Everyone"
+	AGE_RATING_TEEN        = "Teen"
+	AGE_RATING_MATURE      = "Mature 17+"
+	AGE_RATING_ADULTS_ONLY = "Adults Only 18+"
+
+	PAGE_FRONT_COVER = "FrontCover"
+	PAGE_STORY       = "Story"
+)
+
+// rightToLeftLanguages are original languages of manga read from right to left.
+var rightToLeftLanguages = []string{"ja", "ko", "zh", "zh-hk"}
+
+// ageRatings maps MangaDex content ratings to ComicInfo age ratings.
+var ageRatings = map[string]string{
+	"safe":         AGE_RATING_EVERYONE,
+	"suggestive":   AGE_RATING_TEEN,
+	"erotica":      AGE_RATING_MATURE,
+	"pornographic": AGE_RATING_ADULTS_ONLY,
+}
+
 type MangaProvider interface {
 	Title(language string) string
+	LocalizedTitle(language string) string
 	Description(language string) string
 	Publisher() string
 	Year() int
+	Status() string
+	OriginalLanguage() string
+	ContentRating() string
+	LastChapter() string
+	Link() string
 	AuthorsArr() []string
 	Authors() string
 	ArtistsArr() []string
 	Artists() string
 	TagsArr() []string
+	TagsGroupArr(groups ...string) []string
 	Tags() string
 	LinksArr() []string
 	Links() string
@@ -80,6 +176,7 @@ type ChapterProvider interface {
 	Number() string
 	Volume() string
 	Language() string
+	Translator() string
 	PagesCount() int
 }
 
@@ -101,20 +198,34 @@ func NewMetadata(appId string, m MangaProvider, c ChapterProvider) Metadata {
 		credits = append(credits, credit)
 	}
 
-	mangaTitle := fmt.Sprintf("%s | %s vol%s ch%s",
-		c.Language(), m.Title("en"), c.Volume(), c.Number())
+	series := m.Title("en")
+	localizedSeries := m.LocalizedTitle(c.Language())
+	if localizedSeries == series {
+		localizedSeries = ""
+	}
 
 	mangaDescription := m.Description("en") + "<br>Read or Buy here:<br>"
 	for _, l := range m.LinksArr() {
 		mangaDescription += l + "<br>"
 	}
 
+	web := []string{}
+	if link := m.Link(); link != "" {
+		web = append(web, link)
+	}
+	web = append(web, m.LinksArr()...)
+
+	manga := MANGA_NO
+	if IsRightToLeftLanguage(m.OriginalLanguage()) {
+		manga = MANGA_RIGHT_TO_LEFT
+	}
+
 	metadata := Metadata{
 		CBI: ComicBookMetadata{
 			AppID:        appId,
 			LastModified: time.Now().UTC().String(),
 			ComicBookInfoData: ComicBookInfo{
-				Series:    mangaTitle,
+				Series:    series,
 				Title:     c.Title(),
 				Publisher: m.Publisher(),
 				Issue:     c.Number(),
@@ -125,20 +236,29 @@ func NewMetadata(appId string, m MangaProvider, c ChapterProvider) Metadata {
 			},
 		},
 		CI: ComicInfoMetadata{
-			XMLName:     xml.Name{Local: "ComicInfo"},
-			Title:       mangaTitle,
-			Number:      c.Number(),
-			Volume:      c.Volume(),
-			Year:        m.Year(),
-			Writer:      m.Authors(),
-			Penciller:   m.Artists(),
-			Inker:       m.Artists(),
-			Publisher:   m.Publisher(),
-			PageCount:   c.PagesCount(),
-			LanguageISO: c.Language(),
-			Format:      "Comic Book",
-			Manga:       "No",
-			Summary:     mangaDescription,
+			XMLName:         xml.Name{Local: "ComicInfo"},
+			Title:           c.Title(),
+			Series:          series,
+			LocalizedSeries: localizedSeries,
+			Number:          c.Number(),
+			Count:           seriesCount(m),
+			Volume:          integerOrEmpty(c.Volume()),
+			Summary:         mangaDescription,
+			Year:            m.Year(),
+			Writer:          m.Authors(),
+			Penciller:       m.Artists(),
+			Inker:           m.Artists(),
+			Translator:      c.Translator(),
+			Publisher:       m.Publisher(),
+			Genre:           strings.Join(m.TagsGroupArr("genre"), ", "),
+			Tags:            strings.Join(m.TagsGroupArr("theme", "format", "content"), ", "),
+			Web:             strings.Join(web, " "),
+			PageCount:       c.PagesCount(),
+			LanguageISO:     c.Language(),
+			Format:          "Comic Book",
+			Manga:           manga,
+			ScanInformation: c.Translator(),
+			AgeRating:       ageRatings[m.ContentRating()],
 		},
 		P: PlainMetadata{
 			Authors: m.Authors(),
@@ -149,3 +269,115 @@ func NewMetadata(appId string, m MangaProvider, c ChapterProvider) Metadata {
 
 	return metadata
 }
+
+// SetPages replaces the page list and the page count with pages actually
+// stored in the file.
+func (m *Metadata) SetPages(pages []ComicPageInfo) {
+	m.CI.Pages = pages
+	m.CI.PageCount = len(pages)
+}
+
+// URN prefixes of MangaDex IDs recorded in files.
+const (
+	MANGA_URN_PREFIX   = "urn:mangadex:manga:"
+	CHAPTER_URN_PREFIX = "urn:mangadex:chapter:"
+)
+
+// SOURCE_NOTES_PREFIX starts the line of the ComicInfo notes with the IDs.
+const SOURCE_NOTES_PREFIX = "Downloaded from MangaDex:"
+
+// SourceIDs are MangaDex IDs of the manga and the chapters a file was
+// downloaded from, they let the metadata be fetched again.
+type SourceIDs struct {
+	Manga    string
+	Chapters []string
+}
+
+// URNs returns the IDs as URNs, e.g. urn:mangadex:manga:<uuid>.
+func (ids SourceIDs) URNs() []string {
+	if ids.Manga == "" {
+		return nil
+	}
+	urns := []string{MANGA_URN_PREFIX + ids.Manga}
+	for _, id := range ids.Chapters {
+		urns = append(urns, CHAPTER_URN_PREFIX+id)
+	}
+	return urns
+}
+
+// ParseSourceIDs finds URNs of MangaDex IDs in text.
+func ParseSourceIDs(text string) SourceIDs {
+	ids := SourceIDs{}
+	for _, field := range strings.Fields(text) {
+		if id, ok := strings.CutPrefix(field, MANGA_URN_PREFIX); ok && ids.Manga == "" {
+			ids.Manga = id
+		} else if id, ok := strings.CutPrefix(field, CHAPTER_URN_PREFIX); ok {
+			ids.Chapters = append(ids.Chapters, id)
+		}
+	}
+	return ids
+}
+
+// SetSourceIDs records ids in the ComicInfo notes. Only the line of
+// recorded IDs is replaced, other notes are kept.
+func (m *Metadata) SetSourceIDs(ids SourceIDs) {
+	line := ""
+	if urns := ids.URNs(); len(urns) > 0 {
+		line = SOURCE_NOTES_PREFIX + " " + strings.Join(urns, " ")
+	}
+
+	lines := []string{}
+	if m.CI.Notes != "" {
+		lines = strings.Split(m.CI.Notes, "\n")
+	}
+	i := slices.IndexFunc(lines, func(l string) bool {
+		return strings.HasPrefix(l, SOURCE_NOTES_PREFIX)
+	})
+	switch {
+	case i >= 0 && line == "":
+		lines = slices.Delete(lines, i, i+1)
+	case i >= 0:
+		lines[i] = line
+	case line != "":
+		lines = append(lines, line)
+	}
+	m.CI.Notes = strings.Join(lines, "\n")
+}
+
+// SourceIDs returns IDs recorded with SetSourceIDs.
+func (m Metadata) SourceIDs() SourceIDs {
+	return ParseSourceIDs(m.CI.Notes)
+}
+
+// IsRightToLeftLanguage reports whether manga in the original language are
+// read from right to left.
+func IsRightToLeftLanguage(language string) bool {
+	return slices.Contains(rightToLeftLanguages, language)
+}
+
+// IsRightToLeft reports whether pages are read from right to left.
+func (m Metadata) IsRightToLeft() bool {
+	return m.CI.Manga == MANGA_RIGHT_TO_LEFT
+}
+
+// seriesCount returns the number of chapters of a completed series, or 0
+// while it is unknown.
+func seriesCount(m MangaProvider) int {
+	if m.Status() != "completed" {
+		return 0
+	}
+	count, err := strconv.Atoi(m.LastChapter())
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// integerOrEmpty returns value if it is an integer, the schema doesn't allow
+// volumes like "1.5".
+func integerOrEmpty(value string) string {
+	if _, err := strconv.Atoi(value); err != nil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,312 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type xsdSchema struct {
+	ComplexTypes []struct {
+		Name     string `xml:"name,attr"`
+		Elements []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"sequence>element"`
+		Attributes []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"attribute"`
+	} `xml:"complexType"`
+	SimpleTypes []struct {
+		Name  string `xml:"name,attr"`
+		Enums []struct {
+			Value string `xml:"value,attr"`
+		} `xml:"restriction>enumeration"`
+		ListEnums []struct {
+			Value string `xml:"value,attr"`
+		} `xml:"list>simpleType>restriction>enumeration"`
+	} `xml:"simpleType"`
+}
+
+type comicInfoSchema struct {
+	// elements of ComicInfo in the sequence order and their types
+	elements     []string
+	elementTypes map[string]string
+	// pageAttributes are ComicPageInfo attributes and their types
+	pageAttributes map[string]string
+	enums          map[string][]string
+}
+
+func loadComicInfoSchema(t *testing.T) comicInfoSchema {
+	content, err := os.ReadFile("testdata/ComicInfo.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := xsdSchema{}
+	if err := xml.Unmarshal(content, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	s := comicInfoSchema{
+		elementTypes:   map[string]string{},
+		pageAttributes: map[string]string{},
+		enums:          map[string][]string{},
+	}
+	for _, ct := range schema.ComplexTypes {
+		switch ct.Name {
+		case "ComicInfo":
+			for _, el := range ct.Elements {
+				s.elements = append(s.elements, el.Name)
+				s.elementTypes[el.Name] = el.Type
+			}
+		case "ComicPageInfo":
+			for _, attr := range ct.Attributes {
+				s.pageAttributes[attr.Name] = attr.Type
+			}
+		}
+	}
+	for _, st := range schema.SimpleTypes {
+		for _, enum := range append(st.Enums, st.ListEnums...) {
+			s.enums[st.Name] = append(s.enums[st.Name], enum.Value)
+		}
+	}
+	return s
+}
+
+// checkValue checks value against a schema type.
+func (s comicInfoSchema) checkValue(t *testing.T, name, typeName, value string) {
+	switch typeName {
+	case "xs:int", "xs:long":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			t.Errorf("Test Case: %s. Expected integer, but got %q", name, value)
+		}
+	case "xs:boolean":
+		if value != "true" && value != "false" {
+			t.Errorf("Test Case: %s. Expected boolean, but got %q", name, value)
+		}
+	default:
+		enum, ok := s.enums[typeName]
+		if !ok {
+			return
+		}
+		for _, v := range strings.Fields(value) {
+			if typeName != "ComicPageType" {
+				v = value
+			}
+			if !slices.Contains(enum, v) {
+				t.Errorf("Test Case: %s. Expected one of %v, but got %q", name, enum, v)
+			}
+		}
+	}
+}
+
+type testManga struct{}
+
+func (testManga) Title(language string) string { return "Series" }
+func (testManga) LocalizedTitle(language string) string {
+	return map[string]string{"en": "Series", "uk": "Серія"}[language]
+}
+func (testManga) Description(language string) string { return "Description" }
+func (testManga) Publisher() string                  { return "shounen" }
+func (testManga) Year() int                          { return 2020 }
+func (testManga) Status() string                     { return "completed" }
+func (testManga) OriginalLanguage() string           { return "ja" }
+func (testManga) ContentRating() string              { return "suggestive" }
+func (testManga) LastChapter() string                { return "42" }
+func (testManga) Link() string                       { return "https://mangadex.org/title/id" }
+func (testManga) AuthorsArr() []string               { return []string{"Author"} }
+func (testManga) Authors() string                    { return "Author" }
+func (testManga) ArtistsArr() []string               { return []string{"Artist"} }
+func (testManga) Artists() string                    { return "Artist" }
+func (testManga) TagsArr() []string                  { return []string{"Action", "Monsters"} }
+func (testManga) Tags() string                       { return "Action, Monsters" }
+func (testManga) LinksArr() []string                 { return []string{"https://anilist.co/manga/1"} }
+func (testManga) Links() string                      { return "https://anilist.co/manga/1" }
+func (testManga) TagsGroupArr(groups ...string) []string {
+	if slices.Contains(groups, "genre") {
+		return []string{"Action"}
+	}
+	return []string{"Monsters"}
+}
+
+type testChapter struct {
+	language string
+	volume   string
+}
+
+func (c testChapter) Title() string      { return "Chapter Title" }
+func (c testChapter) Number() string     { return "5" }
+func (c testChapter) Volume() string     { return c.volume }
+func (c testChapter) Language() string   { return c.language }
+func (c testChapter) Translator() string { return "Group" }
+func (c testChapter) PagesCount() int    { return 3 }
+
+func TestComicInfoSchema(t *testing.T) {
+	schema := loadComicInfoSchema(t)
+
+	m := NewMetadata("mdx", testManga{}, testChapter{language: "uk", volume: "1.5"})
+	m.SetPages([]ComicPageInfo{
+		{Image: 0, Type: PAGE_FRONT_COVER, ImageSize: 10, ImageWidth: 4, ImageHeight: 6},
+		{Image: 1, DoublePage: true, ImageSize: 10, ImageWidth: 8, ImageHeight: 6},
+	})
+	content, err := m.CI.MarshalComicInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	depth := 0
+	lastIndex := -1
+	elementName := ""
+	seen := map[string]bool{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			name := token.Name.Local
+			switch depth {
+			case 1:
+				if name != "ComicInfo" {
+					t.Errorf("Test Case: root. Expected ComicInfo, but got %s", name)
+				}
+			case 2:
+				elementName = name
+				seen[name] = true
+				index := slices.Index(schema.elements, name)
+				if index == -1 {
+					t.Errorf("Test Case: %s. Expected element from the schema, but got unknown element", name)
+					continue
+				}
+				if index <= lastIndex {
+					t.Errorf("Test Case: %s. Expected element after %s, but got it earlier",
+						name, schema.elements[lastIndex])
+				}
+				lastIndex = index
+			case 3:
+				if elementName != "Pages" || name != "Page" {
+					t.Errorf("Test Case: %s. Expected Page inside Pages, but got %s", elementName, name)
+					continue
+				}
+				for _, attr := range token.Attr {
+					attrType, ok := schema.pageAttributes[attr.Name.Local]
+					if !ok {
+						t.Errorf("Test Case: Page. Expected attribute from the schema, but got %s",
+							attr.Name.Local)
+						continue
+					}
+					schema.checkValue(t, "Page@"+attr.Name.Local, attrType, attr.Value)
+				}
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth != 2 || elementName == "Pages" {
+				continue
+			}
+			schema.checkValue(t, elementName, schema.elementTypes[elementName], string(token))
+		}
+	}
+
+	expected := map[string]string{
+		"Title":           "Chapter Title",
+		"Series":          "Series",
+		"LocalizedSeries": "Серія",
+		"Manga":           MANGA_RIGHT_TO_LEFT,
+		"AgeRating":       AGE_RATING_TEEN,
+		"Genre":           "Action",
+		"Tags":            "Monsters",
+		"Translator":      "Group",
+		"ScanInformation": "Group",
+		"Web":             "https://mangadex.org/title/id https://anilist.co/manga/1",
+		"Count":           "42",
+		"PageCount":       "2",
+	}
+	parsed := struct {
+		Elements []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	}{}
+	if err := xml.Unmarshal(content, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, el := range parsed.Elements {
+		values[el.XMLName.Local] = el.Value
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", name, value, values[name])
+		}
+	}
+	if seen["Volume"] {
+		t.Errorf("Test Case: Volume. Expected no volume for \"1.5\", but got %q", values["Volume"])
+	}
+}
+
+func TestSourceIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		notes     string
+		ids       SourceIDs
+		wantNotes string
+	}{
+		{
+			name:      "Merged chapters",
+			ids:       SourceIDs{Manga: "m1", Chapters: []string{"c1", "c2"}},
+			wantNotes: "Downloaded from MangaDex: urn:mangadex:manga:m1 urn:mangadex:chapter:c1 urn:mangadex:chapter:c2",
+		},
+		{
+			name:      "No manga",
+			ids:       SourceIDs{Chapters: []string{"c1"}},
+			wantNotes: "",
+		},
+		{
+			name:      "Other notes",
+			notes:     "Scanned by me",
+			ids:       SourceIDs{Manga: "m1", Chapters: []string{"c1"}},
+			wantNotes: "Scanned by me\nDownloaded from MangaDex: urn:mangadex:manga:m1 urn:mangadex:chapter:c1",
+		},
+		{
+			name:      "Replaced IDs",
+			notes:     "Downloaded from MangaDex: urn:mangadex:manga:m0\nScanned by me",
+			ids:       SourceIDs{Manga: "m1", Chapters: []string{"c1"}},
+			wantNotes: "Downloaded from MangaDex: urn:mangadex:manga:m1 urn:mangadex:chapter:c1\nScanned by me",
+		},
+		{
+			name:      "Removed IDs",
+			notes:     "Scanned by me\nDownloaded from MangaDex: urn:mangadex:manga:m0",
+			wantNotes: "Scanned by me",
+		},
+	}
+
+	for _, tc := range tests {
+		m := Metadata{}
+		m.CI.Notes = tc.notes
+		m.SetSourceIDs(tc.ids)
+		if m.CI.Notes != tc.wantNotes {
+			t.Errorf("Test Case: %s. Expected notes %q, but got %q", tc.name, tc.wantNotes, m.CI.Notes)
+		}
+		if tc.ids.Manga == "" {
+			continue
+		}
+		got := m.SourceIDs()
+		if got.Manga != tc.ids.Manga || !slices.Equal(got.Chapters, tc.ids.Chapters) {
+			t.Errorf("Test Case: %s. Expected IDs %v, but got %v", tc.name, tc.ids, got)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package filekit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ON_EXISTS_RENAME adds a " (n)" suffix to the output name.
+	ON_EXISTS_RENAME = "rename"
+	// ON_EXISTS_OVERWRITE replaces the existing output.
+	ON_EXISTS_OVERWRITE = "overwrite"
+	// ON_EXISTS_SKIP keeps the existing output and does not write a new one.
+	ON_EXISTS_SKIP = "skip"
+	// ON_EXISTS_FAIL returns ErrOutputExists.
+	ON_EXISTS_FAIL = "fail"
+
+	// tempPattern names in-progress files and directories created next to
+	// the final output.
+	tempPattern = ".mdx-*.tmp"
+)
+
+var (
+	// ErrOutputExists is returned when the output path is taken and the
+	// policy is ON_EXISTS_FAIL.
+	ErrOutputExists = errors.New("output already exists")
+	// ErrOutputSkipped is returned when the output path is taken and the
+	// policy is ON_EXISTS_SKIP. Nothing is written in this case.
+	ErrOutputSkipped = errors.New("output already exists, skipped")
+)
+
+// Options configures containers created by NewContainer.
+type Options struct {
+	// OnExists is one of the ON_EXISTS_* policies. Empty means ON_EXISTS_RENAME.
+	OnExists string
+	// WorkDir keeps in-progress files. It should be on the same filesystem
+	// as the output, then finished files are moved into place by a rename.
+	// Empty means the system temporary directory.
+	WorkDir string
+	// EpubLayout is one of the EPUB_LAYOUT_* values. Empty means
+	// EPUB_LAYOUT_FIXED.
+	EpubLayout string
+	// PdfPageSize is one of the PDF_PAGE_* sizes or a device profile name.
+	// Empty means PDF_PAGE_NATIVE.
+	PdfPageSize string
+	// PdfScale is one of the PDF_SCALE_* values. Empty means PDF_SCALE_FIT.
+	PdfScale string
+	// PdfMargin is the page margin in points.
+	PdfMargin float64
+	// PdfCompression is one of the PDF_COMPRESSION_* values. Empty means
+	// PDF_COMPRESSION_DEFAULT.
+	PdfCompression string
+	// NoMedia adds a .nomedia file to zip archives, so Android galleries
+	// don't show the pages.
+	NoMedia bool
+	// HtmlAssets puts pages of HTML files into a sibling folder instead of
+	// embedding them as data URIs.
+	HtmlAssets bool
+}
+
+// IsNotSupportedPolicy reports whether policy is not one of the ON_EXISTS_*
+// values.
+func IsNotSupportedPolicy(policy string) bool {
+	return policy != ON_EXISTS_RENAME &&
+		policy != ON_EXISTS_OVERWRITE &&
+		policy != ON_EXISTS_SKIP &&
+		policy != ON_EXISTS_FAIL
+}
+
+// OutputExists reports whether the output for outputFileName with extension
+// is already present in outputDir.
+func OutputExists(outputDir, outputFileName, extension string) bool {
+	_, err := os.Stat(OutputPath(outputDir, outputFileName, extension))
+	return err == nil
+}
+
+// OutputPath returns the path of the output file before the on-exists
+// policy is applied.
+func OutputPath(outputDir, outputFileName, extension string) string {
+	outputFileName = safeOutputName(outputFileName)
+	extension = FileExtension(extension)
+	if extension == DIR_EXT {
+		return filepath.Join(outputDir, outputFileName)
+	}
+	return filepath.Join(outputDir, outputFileName+"."+extension)
+}
+
+// resolveOutputPath applies policy to the output path and creates outputDir.
+func resolveOutputPath(outputDir, outputFileName, extension, policy string) (string, error) {
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	outputPath := OutputPath(outputDir, outputFileName, extension)
+	_, err := os.Stat(outputPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return outputPath, nil
+	}
+
+	switch policy {
+	case ON_EXISTS_OVERWRITE:
+		return outputPath, nil
+	case ON_EXISTS_SKIP:
+		return "", fmt.Errorf("%w: %s", ErrOutputSkipped, outputPath)
+	case ON_EXISTS_FAIL:
+		return "", fmt.Errorf("%w: %s", ErrOutputExists, outputPath)
+	}
+
+	if extension == DIR_EXT {
+		return safeOutputDirPath(outputDir, outputFileName), nil
+	}
+	return safeOutputPath(outputDir, outputFileName, FileExtension(extension)), nil
+}
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory. The temporary file is synced and renamed into outputPath, so
+// readers never see a partial file.
+func writeFileAtomic(outputPath string, write func(w io.Writer) error) error {
+	return writeTempFileAtomic(outputPath, func(f *os.File) error {
+		return write(f)
+	})
+}
+
+// writeTempFileAtomic is writeFileAtomic for writers that read back what
+// they have written.
+func writeTempFileAtomic(outputPath string, write func(f *os.File) error) error {
+	dir := filepath.Dir(outputPath)
+	tmp, err := os.CreateTemp(dir, tempPattern)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return commitTempFile(tmp, outputPath)
+}
+
+// WriteFileAtomic writes a file outside of containers, e.g. an index or a
+// settings file, like writeFileAtomic. Its directory is created.
+func WriteFileAtomic(path string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, write)
+}
+
+// WriteOutputFile writes a file next to container outputs, e.g. a
+// shortcut, with the on-exists policy and like writeFileAtomic. It returns
+// the path of the written file.
+func WriteOutputFile(outputDir, outputFileName, extension, policy string,
+	write func(w io.Writer) error) (string, error) {
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, extension, policy)
+	if err != nil {
+		return "", err
+	}
+	return outputPath, writeFileAtomic(outputPath, write)
+}
+
+// createWorkFile creates a temporary file in the work directory.
+func createWorkFile(opts Options) (*os.File, error) {
+	if opts.WorkDir == "" {
+		return os.CreateTemp("", tempPattern)
+	}
+	if err := os.MkdirAll(opts.WorkDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(opts.WorkDir, tempPattern)
+}
+
+// commitTempFile syncs, closes and renames tmp into outputPath. If tmp is on
+// another filesystem, it is copied next to outputPath first. tmp is removed
+// on failure.
+func commitTempFile(tmp *os.File, outputPath string) error {
+	tmpPath := tmp.Name()
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		defer os.Remove(tmpPath)
+		if filepath.Dir(tmpPath) == filepath.Dir(outputPath) {
+			return err
+		}
+		return copyFileAtomic(tmpPath, outputPath)
+	}
+
+	syncDir(filepath.Dir(outputPath))
+	return nil
+}
+
+func copyFileAtomic(srcPath, outputPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return writeFileAtomic(outputPath, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+// commitTempDir renames the fully written tmpDir into outputPath. An existing
+// outputPath is moved aside first and removed after the rename succeeded.
+func commitTempDir(tmpDir, outputPath string) error {
+	oldPath := ""
+	if _, err := os.Stat(outputPath); err == nil {
+		oldPath = strings.TrimSuffix(tmpDir, ".tmp") + ".old.tmp"
+		if err := os.Rename(outputPath, oldPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpDir, outputPath); err != nil {
+		if oldPath != "" {
+			_ = os.Rename(oldPath, outputPath)
+		}
+		return err
+	}
+
+	syncDir(filepath.Dir(outputPath))
+
+	if oldPath != "" {
+		return os.RemoveAll(oldPath)
+	}
+	return nil
+}
+
+// syncDir flushes directory entries, so a rename survives a crash. Errors are
+// ignored because some platforms can't sync directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,82 @@
+package filekit
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+func testPage(t *testing.T) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeTestContainer(t *testing.T, ext, dir string, opts Options) error {
+	t.Helper()
+	c, err := NewContainer(ext, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddFile("png", testPage(t)); err != nil {
+		t.Fatal(err)
+	}
+	return c.WriteOnDiskAndClose(dir, "out", metadata.Metadata{}, "")
+}
+
+func TestOnExistsPolicy(t *testing.T) {
+	for _, ext := range []string{CBZ_EXT, PDF_EXT, EPUB_EXT, DIR_EXT, CBT_EXT, ZIP_EXT, KINDLE_EXT, HTML_EXT} {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if err := writeTestContainer(t, ext, dir, Options{}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := writeTestContainer(t, ext, dir, Options{OnExists: ON_EXISTS_RENAME}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := writeTestContainer(t, ext, dir, Options{OnExists: ON_EXISTS_OVERWRITE}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			err := writeTestContainer(t, ext, dir, Options{OnExists: ON_EXISTS_SKIP})
+			if !errors.Is(err, ErrOutputSkipped) {
+				t.Errorf("Expected %v, but got %v", ErrOutputSkipped, err)
+			}
+			err = writeTestContainer(t, ext, dir, Options{OnExists: ON_EXISTS_FAIL})
+			if !errors.Is(err, ErrOutputExists) {
+				t.Errorf("Expected %v, but got %v", ErrOutputExists, err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			want := []string{"out", "out (1)"}
+			if ext != DIR_EXT {
+				want = []string{"out (1)." + FileExtension(ext), "out." + FileExtension(ext)}
+			}
+			if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] {
+				t.Errorf("Expected %v in output directory, but got %v", want, names)
+			}
+
+			if ext == DIR_EXT {
+				if _, err := os.Stat(filepath.Join(dir, "out", "01.png")); err != nil {
+					t.Errorf("Expected page in output directory: %v", err)
+				}
+			}
+		})
+	}
+}
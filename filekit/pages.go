@@ -0,0 +1,80 @@
+package filekit
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// pageList collects ComicInfo page entries of stored pages.
+type pageList struct {
+	pages []metadata.ComicPageInfo
+	// bookmark is set on the next page, it marks the first page of a chapter
+	bookmark string
+}
+
+// add describes the next page. Spreads are marked as double pages.
+// Dimensions are left empty if the image format is unknown.
+func (l *pageList) add(src []byte) {
+	page := metadata.ComicPageInfo{
+		Image:     len(l.pages),
+		ImageSize: int64(len(src)),
+	}
+	if page.Image == 0 {
+		page.Type = metadata.PAGE_FRONT_COVER
+	}
+	page.Bookmark, l.bookmark = l.bookmark, ""
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err == nil {
+		page.ImageWidth = config.Width
+		page.ImageHeight = config.Height
+		page.DoublePage = imaging.IsSpread(config.Width, config.Height)
+	}
+
+	l.pages = append(l.pages, page)
+}
+
+// archivePages names pages of archive containers. Pages of merged archives
+// are put into a folder per chapter.
+type archivePages struct {
+	pageCounter int
+	pages       pageList
+	// chapterDir is a folder for pages of the current chapter in merged
+	// archives
+	chapterDir   string
+	chapterCount int
+}
+
+func newArchivePages() archivePages {
+	return archivePages{pageCounter: 1}
+}
+
+func (a *archivePages) beginChapter(chapter Chapter) {
+	a.chapterCount++
+	a.chapterDir = chapterDirName(a.chapterCount, chapter)
+	a.pageCounter = 1
+	a.pages.bookmark = chapter.Label()
+}
+
+// nextPage records the page and returns its path inside the archive.
+func (a *archivePages) nextPage(fileExt string, src []byte) string {
+	fileName := fmt.Sprintf("%02d.%s", a.pageCounter, fileExt)
+	if a.chapterDir != "" {
+		fileName = a.chapterDir + "/" + fileName
+	}
+	a.pages.add(src)
+	a.pageCounter++
+	return fileName
+}
+
+// pageInfo returns ComicInfo entries of recorded pages.
+func (a *archivePages) pageInfo() []metadata.ComicPageInfo {
+	return a.pages.pages
+}
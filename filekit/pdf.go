@@ -2,94 +2,313 @@ package filekit
 
 import (
 	"bytes"
+	"compress/zlib"
+	"fmt"
 	"image"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/arimatakao/mdx/filekit/metadata"
 	"github.com/signintech/gopdf"
 )
 
+const (
+	// PDF_PAGE_NATIVE sizes every page to its image, one pixel is one point.
+	PDF_PAGE_NATIVE = "native"
+	PDF_PAGE_A4     = "a4"
+	PDF_PAGE_A5     = "a5"
+	PDF_PAGE_LETTER = "letter"
+
+	// PDF_SCALE_FIT shows the whole image inside the margins.
+	PDF_SCALE_FIT = "fit"
+	// PDF_SCALE_FILL covers the area inside the margins and crops the image.
+	PDF_SCALE_FILL = "fill"
+
+	// PDF_COMPRESSION_DEFAULT compresses page content streams with the
+	// default zlib level. Image data is not recompressed.
+	PDF_COMPRESSION_DEFAULT = "default"
+	PDF_COMPRESSION_NONE    = "none"
+	PDF_COMPRESSION_FAST    = "fast"
+	PDF_COMPRESSION_BEST    = "best"
+)
+
+var pdfCompressionLevels = map[string]int{
+	PDF_COMPRESSION_DEFAULT: zlib.DefaultCompression,
+	PDF_COMPRESSION_NONE:    zlib.NoCompression,
+	PDF_COMPRESSION_FAST:    zlib.BestSpeed,
+	PDF_COMPRESSION_BEST:    zlib.BestCompression,
+}
+
+var pdfPageSizes = map[string]*gopdf.Rect{
+	PDF_PAGE_A4:     gopdf.PageSizeA4,
+	PDF_PAGE_A5:     gopdf.PageSizeA5,
+	PDF_PAGE_LETTER: gopdf.PageSizeLetter,
+}
+
+// IsNotSupportedPdfPageSize reports whether size is neither one of the
+// PDF_PAGE_* sizes nor a device profile.
+func IsNotSupportedPdfPageSize(size string) bool {
+	size = strings.ToLower(size)
+	if _, ok := pdfPageSizes[size]; ok || size == PDF_PAGE_NATIVE {
+		return false
+	}
+	_, ok := DeviceProfile(size)
+	return !ok
+}
+
+// IsNotSupportedPdfScale reports whether scale is not one of the PDF_SCALE_*
+// values.
+func IsNotSupportedPdfScale(scale string) bool {
+	scale = strings.ToLower(scale)
+	return scale != PDF_SCALE_FIT && scale != PDF_SCALE_FILL
+}
+
+// IsNotSupportedPdfCompression reports whether compression is not one of
+// the PDF_COMPRESSION_* values.
+func IsNotSupportedPdfCompression(compression string) bool {
+	_, ok := pdfCompressionLevels[strings.ToLower(compression)]
+	return !ok
+}
+
 type pdfFile struct {
-	pdf *gopdf.GoPdf
+	opts Options
+	pdf  *gopdf.GoPdf
+	// pageSize is empty for native page sizes
+	pageSize  *gopdf.Rect
+	pageCount int
+	// chapterStarts maps a page index to the chapter starting there
+	chapterStarts map[int]Chapter
+	// outline has top-level entries, volume is the one of the last entry
+	outline []*pdfOutlineEntry
+	volume  string
 }
 
-func newPdfFile() (pdfFile, error) {
+// pdfOutlineEntry is an outline entry pointing at the top of a page,
+// entries of chapters are nested under the entry of their volume.
+type pdfOutlineEntry struct {
+	title string
+	// page is the index of the page, height is its height
+	page     int
+	height   float64
+	children []*pdfOutlineEntry
+}
+
+func newPdfFile(opts Options) (*pdfFile, error) {
+	opts.PdfScale = strings.ToLower(opts.PdfScale)
 
 	pdf := new(gopdf.GoPdf)
 	pdf.Start(gopdf.Config{
 		PageSize: *gopdf.PageSizeA4,
 	})
+	if level, ok := pdfCompressionLevels[strings.ToLower(opts.PdfCompression)]; ok {
+		pdf.SetCompressLevel(level)
+	}
+
+	p := &pdfFile{
+		opts:          opts,
+		pdf:           pdf,
+		chapterStarts: map[int]Chapter{},
+	}
 
-	pdf.SetNoCompression()
+	size := strings.ToLower(opts.PdfPageSize)
+	if pageSize, ok := pdfPageSizes[size]; ok {
+		p.pageSize = &gopdf.Rect{W: pageSize.W, H: pageSize.H}
+	} else if device, ok := DeviceProfile(size); ok {
+		w, h := device.pageSizePt()
+		p.pageSize = &gopdf.Rect{W: w, H: h}
+	}
 
-	return pdfFile{
-		pdf: pdf,
-	}, nil
+	return p, nil
 }
 
-func (p pdfFile) WriteOnDiskAndClose(outputDir, outputFileName string,
+func (p *pdfFile) WriteOnDiskAndClose(outputDir, outputFileName string,
 	m metadata.Metadata, chapterRange string) error {
-	author := m.P.Authors + " | " + m.P.Artists
-
-	p.pdf.SetInfo(gopdf.PdfInfo{
-		Title:        m.CI.Title,
-		Author:       author,
-		Subject:      m.CBI.ComicBookInfoData.Title,
-		Creator:      m.CBI.AppID,
-		Producer:     m.CBI.AppID,
-		CreationDate: time.Now(),
-	})
+	defer p.pdf.Close()
 
-	err := os.MkdirAll(outputDir, os.ModePerm)
+	outputPath, err := resolveOutputPath(outputDir, outputFileName, PDF_EXT, p.opts.OnExists)
 	if err != nil {
 		return err
 	}
 
-	outputPath := safeOutputPath(outputDir, outputFileName, PDF_EXT)
+	// gopdf can't write custom document information, an outline with
+	// nested entries and viewer preferences, they are appended as an
+	// incremental update
+	return writeTempFileAtomic(outputPath, func(f *os.File) error {
+		content := &pdfTailWriter{w: f}
+		if _, err := p.pdf.WriteTo(content); err != nil {
+			return err
+		}
+		if content.err != nil {
+			return content.err
+		}
+		trailer, err := readPdfTrailer(content.tail)
+		if err != nil {
+			return err
+		}
+		catalog, err := pdfObjectAt(f, content.n, trailer.startxref, trailer.root)
+		if err != nil {
+			return err
+		}
 
-	err = p.pdf.WritePdf(outputPath)
-	if err != nil {
-		return err
-	}
+		update := newPdfUpdate(trailer)
+		info := update.add(pdfInfo(m, chapterRange))
+		entries := ""
+		if len(p.outline) > 0 {
+			pages, err := readPdfPages(f, content.n, trailer, catalog)
+			if err != nil {
+				return err
+			}
+			outlines, err := addPdfOutline(update, p.outline, pages)
+			if err != nil {
+				return err
+			}
+			entries += fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", outlines)
+		}
+		if m.IsRightToLeft() {
+			entries += " /ViewerPreferences << /Direction /R2L >>"
+		}
+		catalogDict, err := extendPdfDict(catalog, entries)
+		if err != nil {
+			return err
+		}
+		update.set(trailer.root, catalogDict)
 
-	err = p.pdf.Close()
-	if err != nil {
-		return err
-	}
+		return update.write(f, content.n, info)
+	})
+}
 
+func (p *pdfFile) Abort() error {
+	return p.pdf.Close()
+}
+
+func (p *pdfFile) BeginChapter(chapter Chapter) error {
+	p.chapterStarts[p.pageCount] = chapter
 	return nil
 }
 
-func (p pdfFile) AddFile(fileName string, imageBytes []byte) error {
+func (p *pdfFile) AddFile(fileName string, imageBytes []byte) error {
 	imgWidth, imgHeight, err := getImageDimensions(imageBytes)
 	if err != nil {
 		return err
 	}
 
-	imageReader := bytes.NewBuffer(imageBytes)
+	// the image is parsed before the page is added, so a page that fails
+	// doesn't leave a blank page behind
+	imgObj := new(gopdf.ImageObj)
+	if err := imgObj.SetImage(bytes.NewReader(imageBytes)); err != nil {
+		return err
+	}
+	if err := imgObj.Parse(); err != nil {
+		return err
+	}
+	imgH1, err := gopdf.ImageHolderByReader(bytes.NewReader(imageBytes))
+	if err != nil {
+		return err
+	}
 
+	pageSize := p.pageRect(imgWidth, imgHeight)
 	p.pdf.AddPageWithOption(gopdf.PageOption{
-		PageSize: &gopdf.Rect{
-			W: imgWidth,
-			H: imgHeight,
-		},
+		PageSize: pageSize,
 	})
 
-	imgH1, err := gopdf.ImageHolderByReader(imageReader)
-	if err != nil {
-		return err
+	if chapter, ok := p.chapterStarts[p.pageCount]; ok {
+		p.addOutline(chapter, pageSize.H)
 	}
-	if err := p.pdf.ImageByHolder(imgH1, 0, 0, &gopdf.Rect{
-		W: float64(imgWidth),
-		H: float64(imgHeight),
+
+	margin := p.opts.PdfMargin
+	boxW, boxH := pageSize.W-2*margin, pageSize.H-2*margin
+	scale := min(boxW/imgWidth, boxH/imgHeight)
+	if p.opts.PdfScale == PDF_SCALE_FILL {
+		scale = max(boxW/imgWidth, boxH/imgHeight)
+		p.pdf.SaveGraphicsState()
+		p.pdf.ClipPolygon([]gopdf.Point{
+			{X: margin, Y: margin},
+			{X: margin + boxW, Y: margin},
+			{X: margin + boxW, Y: margin + boxH},
+			{X: margin, Y: margin + boxH},
+		})
+		defer p.pdf.RestoreGraphicsState()
+	}
+	w, h := imgWidth*scale, imgHeight*scale
+
+	if err := p.pdf.ImageByHolder(imgH1, (pageSize.W-w)/2, (pageSize.H-h)/2, &gopdf.Rect{
+		W: w,
+		H: h,
 	}); err != nil {
 		return err
 	}
 
+	p.pageCount++
 	return nil
 }
 
+// pageRect returns the page size for an image. Fixed page sizes are turned
+// to landscape for landscape images, e.g. double pages.
+func (p *pdfFile) pageRect(imgWidth, imgHeight float64) *gopdf.Rect {
+	margin := p.opts.PdfMargin
+	if p.pageSize == nil {
+		return &gopdf.Rect{W: imgWidth + 2*margin, H: imgHeight + 2*margin}
+	}
+	if imgWidth > imgHeight {
+		return &gopdf.Rect{W: p.pageSize.H, H: p.pageSize.W}
+	}
+	return &gopdf.Rect{W: p.pageSize.W, H: p.pageSize.H}
+}
+
+// pdfInfo returns the document information dictionary. ComicInfo fields
+// are added as custom entries, so files can be indexed like archives.
+func pdfInfo(m metadata.Metadata, chapterRange string) string {
+	now := pdfDate(time.Now())
+	info := []string{"/CreationDate " + now, "/ModDate " + now}
+	number := m.CI.Number
+	if chapterRange != "" {
+		number = chapterRange
+	}
+	fields := [][2]string{
+		{"Title", bookTitle(m, chapterRange)},
+		{"Author", strings.Trim(m.P.Authors+" | "+m.P.Artists, " |")},
+		{"Subject", m.CBI.ComicBookInfoData.Title},
+		{"Creator", m.CBI.AppID},
+		{"Producer", m.CBI.AppID},
+		{"Keywords", strings.Join(m.SourceIDs().URNs(), " ")},
+		{"Series", m.CI.Series},
+		{"Volume", m.CI.Volume},
+		{"Number", number},
+		{"LanguageISO", m.CI.LanguageISO},
+		{"Translator", m.CI.Translator},
+	}
+	for _, field := range fields {
+		if field[1] != "" {
+			info = append(info, "/"+field[0]+" "+pdfString(field[1]))
+		}
+	}
+	return "<< " + strings.Join(info, " ") + " >>"
+}
+
+// addOutline adds outline entries of a chapter starting on the current
+// page of pageHeight. Chapters of a volume are nested under an entry of the
+// volume.
+func (p *pdfFile) addOutline(chapter Chapter, pageHeight float64) {
+	entry := func(title string) *pdfOutlineEntry {
+		return &pdfOutlineEntry{title: title, page: p.pageCount, height: pageHeight}
+	}
+
+	if chapter.Volume == "" {
+		p.volume = ""
+		p.outline = append(p.outline, entry(chapter.Label()))
+		return
+	}
+
+	if chapter.Volume != p.volume {
+		p.volume = chapter.Volume
+		p.outline = append(p.outline, entry("Volume "+chapter.Volume))
+	}
+	volume := p.outline[len(p.outline)-1]
+	chapter.Volume = ""
+	volume.children = append(volume.children, entry(chapter.Label()))
+}
+
 func getImageDimensions(img []byte) (float64, float64, error) {
 	buf := bytes.NewBuffer(img)
 	config, _, err := image.DecodeConfig(buf)
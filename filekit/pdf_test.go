@@ -0,0 +1,263 @@
+package filekit
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/signintech/gopdf"
+)
+
+func TestPdfUpdate(t *testing.T) {
+	dir := t.TempDir()
+	m := metadata.Metadata{CI: metadata.ComicInfoMetadata{
+		Series: "Series",
+		Manga:  metadata.MANGA_RIGHT_TO_LEFT,
+	}}
+	writeMergedTestContainer(t, PDF_EXT, dir, Options{PdfPageSize: PDF_PAGE_A5, PdfMargin: 10}, m)
+
+	content, err := os.ReadFile(filepath.Join(dir, "out.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(content)
+	size := int64(len(content))
+
+	tail, err := readPdfTail(r, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailer, err := readPdfTrailer(tail)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	object := func(num int) string {
+		body, err := pdfObjectAt(r, size, trailer.startxref, num)
+		if err != nil {
+			t.Fatalf("Unexpected error reading object %d: %v", num, err)
+		}
+		return string(body)
+	}
+
+	catalog := object(trailer.root)
+	for _, expected := range []string{"/ViewerPreferences << /Direction /R2L >>", "/PageMode /UseOutlines"} {
+		if !strings.Contains(catalog, expected) {
+			t.Errorf("Expected %s in catalog, but got %s", expected, catalog)
+		}
+	}
+	if pages, err := readPdfPages(r, size, trailer, []byte(catalog)); err != nil || len(pages) != 4 {
+		t.Errorf("Expected 4 pages, but got %v (%v)", pages, err)
+	}
+
+	// the outline has a single volume entry with two chapters
+	outlinesMatch := regexp.MustCompile(`/Outlines (\d+) 0 R`).FindStringSubmatch(catalog)
+	if outlinesMatch == nil {
+		t.Fatalf("Expected an outline in catalog, but got %s", catalog)
+	}
+	outlinesNum, _ := strconv.Atoi(outlinesMatch[1])
+	outlines := object(outlinesNum)
+	match := regexp.MustCompile(`/First (\d+) 0 R /Last (\d+) 0 R /Count 1`).FindStringSubmatch(outlines)
+	if match == nil || match[1] != match[2] {
+		t.Fatalf("Expected one top-level outline entry, but got %s", outlines)
+	}
+	volumeNum, _ := strconv.Atoi(match[1])
+	volume := object(volumeNum)
+	for _, expected := range []string{"/Parent " + outlinesMatch[1] + " 0 R", "/Title " + pdfString("Volume 1"),
+		"/First", "/Last", "/Count -2"} {
+		if !strings.Contains(volume, expected) {
+			t.Errorf("Expected %s in volume entry, but got %s", expected, volume)
+		}
+	}
+	for _, title := range []string{"Ch. 1", "Ch. 2: End"} {
+		if !bytes.Contains(content, []byte(pdfString(title))) {
+			t.Errorf("Expected outline entry %q", title)
+		}
+	}
+
+	info, err := readPdfInfo(r, size, trailer)
+	if err != nil || !strings.Contains(info, "/Series "+pdfString("Series")) {
+		t.Errorf("Expected series in document information, but got %s (%v)", info, err)
+	}
+
+	// every entry of the appended cross-reference section points to its object
+	xref := content[trailer.startxref:]
+	entryRe := regexp.MustCompile(`(\d+) 1\n(\d{10}) 00000 n \n`)
+	entries := entryRe.FindAllSubmatch(xref, -1)
+	if len(entries) == 0 {
+		t.Fatal("Expected cross-reference entries of the update")
+	}
+	for _, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[2]))
+		expected := string(entry[1]) + " 0 obj"
+		if !bytes.HasPrefix(content[offset:], []byte(expected)) {
+			t.Errorf("Expected %q at offset %d", expected, offset)
+		}
+	}
+}
+
+func TestPdfOutlineDestination(t *testing.T) {
+	page := func(width, height int) []byte {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	testCases := []struct {
+		name       string
+		pageSize   string
+		page       []byte
+		wantHeight float64
+	}{
+		{"A5", PDF_PAGE_A5, page(4, 6), gopdf.PageSizeA5.H},
+		{"Letter", PDF_PAGE_LETTER, page(4, 6), gopdf.PageSizeLetter.H},
+		{"Native page taller than A4", PDF_PAGE_NATIVE, page(100, 3000), 3000},
+	}
+
+	destRe := regexp.MustCompile(`/Dest \[(\d+) 0 R /XYZ 0 ([\d.]+) null\]`)
+	mediaBoxRe := regexp.MustCompile(`/MediaBox \[\s*0 0 [\d.]+ ([\d.]+)\s*\]`)
+	for _, tc := range testCases {
+		dir := t.TempDir()
+		c, err := NewContainer(PDF_EXT, Options{PdfPageSize: tc.pageSize, WorkDir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, chapter := range []Chapter{{Number: "1"}, {Number: "2"}} {
+			if err := c.BeginChapter(chapter); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.AddFile("png", tc.page); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.WriteOnDiskAndClose(dir, "out", metadata.Metadata{}, ""); err != nil {
+			t.Fatalf("Test Case: %s. Unexpected error: %v", tc.name, err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, "out.pdf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bytes.NewReader(content)
+		size := int64(len(content))
+		trailer, err := readPdfTrailer(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		catalog, err := pdfObjectAt(r, size, trailer.startxref, trailer.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages, err := readPdfPages(r, size, trailer, catalog)
+		if err != nil {
+			t.Fatalf("Test Case: %s. Unexpected error: %v", tc.name, err)
+		}
+
+		// chapter 2 starts on the second page
+		match := destRe.FindSubmatch(content[bytes.Index(content, []byte(pdfString("Ch. 2"))):])
+		if match == nil {
+			t.Fatalf("Test Case: %s. Expected a destination of chapter 2", tc.name)
+		}
+		destPage, _ := strconv.Atoi(string(match[1]))
+		top, _ := strconv.ParseFloat(string(match[2]), 64)
+		if destPage != pages[1] || math.Abs(top-tc.wantHeight) > 0.01 {
+			t.Errorf("Test Case: %s. Expected top %.2f of page object %d, but got %.2f of %d",
+				tc.name, tc.wantHeight, pages[1], top, destPage)
+		}
+
+		pageObject, err := pdfObjectAt(r, size, trailer.startxref, pages[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		mediaBox := mediaBoxRe.FindSubmatch(pageObject)
+		if mediaBox == nil || string(mediaBox[1]) != string(match[2]) {
+			t.Errorf("Test Case: %s. Expected the destination at the top of %s", tc.name, pageObject)
+		}
+	}
+}
+
+func TestPdfFailedPage(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewContainer(PDF_EXT, Options{WorkDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header of a truncated page is readable, its image data is not
+	truncated := testPage(t)[:40]
+	for i, page := range [][]byte{testPage(t), truncated, testPage(t)} {
+		if err := c.AddFile("png", page); (err != nil) != (i == 1) {
+			t.Fatalf("Expected an error only for the truncated page, but got %v for page %d", err, i+1)
+		}
+	}
+	if err := c.WriteOnDiskAndClose(dir, "out", metadata.Metadata{}, ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "out.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(content)
+	trailer, err := readPdfTrailer(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := pdfObjectAt(r, int64(len(content)), trailer.startxref, trailer.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages, err := readPdfPages(r, int64(len(content)), trailer, catalog); err != nil || len(pages) != 2 {
+		t.Errorf("Expected 2 pages without a blank one, but got %v (%v)", pages, err)
+	}
+}
+
+func TestPdfTailWriter(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), pdfTailSize)
+	testCases := []struct {
+		name      string
+		chunkSize int
+	}{
+		{"small writes", 7},
+		{"writes larger than the tail", 3 * pdfTailSize},
+	}
+
+	for _, tc := range testCases {
+		out := new(bytes.Buffer)
+		w := &pdfTailWriter{w: out}
+		for chunk := range slices.Chunk(content, tc.chunkSize) {
+			if _, err := w.Write(chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if w.n != int64(len(content)) || !bytes.Equal(out.Bytes(), content) {
+			t.Errorf("Test Case: %s. Expected content passed through, but got %d bytes", tc.name, w.n)
+		}
+		if !bytes.Equal(w.tail, content[len(content)-pdfTailSize:]) {
+			t.Errorf("Test Case: %s. Expected the last %d bytes in tail", tc.name, pdfTailSize)
+		}
+	}
+}
+
+func TestPdfOptions(t *testing.T) {
+	if IsNotSupportedPdfScale("FILL") || !IsNotSupportedPdfScale("stretch") {
+		t.Error("Expected pdf scales checked without case")
+	}
+	if IsNotSupportedPdfCompression("Best") || !IsNotSupportedPdfCompression("lzw") {
+		t.Error("Expected pdf compression checked without case")
+	}
+
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("UTC+3", 3*60*60))
+	if got := pdfDate(date); got != "(D:20240501090000Z)" {
+		t.Errorf("Expected date in UTC, but got %s", got)
+	}
+}
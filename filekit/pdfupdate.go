@@ -0,0 +1,417 @@
+package filekit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrMalformedPdf is returned when a PDF can't be updated because its
+// structure is not understood.
+var ErrMalformedPdf = errors.New("malformed pdf")
+
+// pdfTailSize is the number of bytes at the end of a PDF read to find the
+// last trailer.
+const pdfTailSize = 1024
+
+var (
+	pdfStartxrefRe = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	pdfSizeRe      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfRootRe      = regexp.MustCompile(`/Root\s+(\d+)\s+0\s+R`)
+	pdfInfoRe      = regexp.MustCompile(`/Info\s+(\d+)\s+0\s+R`)
+	pdfPrevRe      = regexp.MustCompile(`/Prev\s+(\d+)`)
+	pdfPagesRe     = regexp.MustCompile(`/Pages\s+(\d+)\s+0\s+R`)
+	pdfKidsRe      = regexp.MustCompile(`/Kids\s*\[([^\]]*)\]`)
+	pdfCountRe     = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfRefRe       = regexp.MustCompile(`(\d+)\s+0\s+R`)
+)
+
+// pdfTrailer is the part of a finished PDF needed to append an
+// incremental update to it.
+type pdfTrailer struct {
+	size int
+	root int
+	// info is 0 when the document information is not an indirect object
+	info      int
+	startxref int64
+}
+
+// readPdfTrailer reads the last trailer from the end of a PDF.
+func readPdfTrailer(tail []byte) (pdfTrailer, error) {
+	trailer := pdfTrailer{}
+
+	match := pdfStartxrefRe.FindSubmatch(tail)
+	if match == nil {
+		return trailer, fmt.Errorf("%w: no startxref", ErrMalformedPdf)
+	}
+	trailer.startxref, _ = strconv.ParseInt(string(match[1]), 10, 64)
+
+	trailerStart := bytes.LastIndex(tail, []byte("trailer"))
+	if trailerStart == -1 {
+		return trailer, fmt.Errorf("%w: no trailer", ErrMalformedPdf)
+	}
+	dict := tail[trailerStart:]
+	if match := pdfSizeRe.FindSubmatch(dict); match != nil {
+		trailer.size, _ = strconv.Atoi(string(match[1]))
+	}
+	if match := pdfRootRe.FindSubmatch(dict); match != nil {
+		trailer.root, _ = strconv.Atoi(string(match[1]))
+	}
+	if match := pdfInfoRe.FindSubmatch(dict); match != nil {
+		trailer.info, _ = strconv.Atoi(string(match[1]))
+	}
+	if trailer.size == 0 || trailer.root == 0 {
+		return trailer, fmt.Errorf("%w: no /Size or /Root in trailer", ErrMalformedPdf)
+	}
+	return trailer, nil
+}
+
+// readPdfTail returns the last bytes of a PDF of size bytes.
+func readPdfTail(r io.ReaderAt, size int64) ([]byte, error) {
+	offset := max(size-pdfTailSize, 0)
+	tail := make([]byte, size-offset)
+	if _, err := r.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return tail, nil
+}
+
+// pdfObjectAt returns the body of object num. It is looked up in the
+// cross-reference section at xref and in the previous ones.
+func pdfObjectAt(r io.ReaderAt, size, xref int64, num int) ([]byte, error) {
+	for xref > 0 && xref < size {
+		offset, prev, err := pdfXrefOffset(io.NewSectionReader(r, xref, size-xref), num)
+		if err != nil {
+			return nil, err
+		}
+		if offset > 0 && offset < size {
+			return pdfObjectBody(io.NewSectionReader(r, offset, size-offset), num)
+		}
+		if prev >= xref {
+			break
+		}
+		xref = prev
+	}
+	return nil, fmt.Errorf("%w: no object %d", ErrMalformedPdf, num)
+}
+
+// pdfXrefOffset reads a cross-reference section and returns the offset of
+// object num, 0 if the section has no entry for it, and /Prev of the
+// trailer following the section.
+func pdfXrefOffset(r io.Reader, num int) (int64, int64, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "xref" {
+		return 0, 0, fmt.Errorf("%w: no cross-reference section", ErrMalformedPdf)
+	}
+
+	offset := int64(0)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "trailer") {
+			break
+		}
+		var first, count int
+		if _, err := fmt.Sscanf(line, "%d %d", &first, &count); err != nil {
+			return 0, 0, fmt.Errorf("%w: cross-reference subsection %q", ErrMalformedPdf, line)
+		}
+		for i := range count {
+			if !scanner.Scan() {
+				return 0, 0, fmt.Errorf("%w: truncated cross-reference section", ErrMalformedPdf)
+			}
+			if first+i == num && strings.HasSuffix(strings.TrimSpace(scanner.Text()), "n") {
+				offset, _ = strconv.ParseInt(strings.Fields(scanner.Text())[0], 10, 64)
+			}
+		}
+	}
+
+	prev := int64(0)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := pdfPrevRe.FindStringSubmatch(line); match != nil {
+			prev, _ = strconv.ParseInt(match[1], 10, 64)
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "startxref") {
+			break
+		}
+	}
+	return offset, prev, scanner.Err()
+}
+
+// pdfObjectBody reads object num starting at r up to endobj.
+func pdfObjectBody(r io.Reader, num int) ([]byte, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != fmt.Sprintf("%d 0 obj", num) {
+		return nil, fmt.Errorf("%w: no object %d at its offset", ErrMalformedPdf, num)
+	}
+	body := []byte{}
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "endobj" {
+			return body, nil
+		}
+		body = append(append(body, scanner.Bytes()...), '\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: object %d has no endobj", ErrMalformedPdf, num)
+}
+
+// readPdfPages returns numbers of page objects of a PDF of size bytes in
+// the order of pages. The page tree is found through catalog, only a flat
+// tree is read, as gopdf writes it.
+func readPdfPages(r io.ReaderAt, size int64, trailer pdfTrailer, catalog []byte) ([]int, error) {
+	match := pdfPagesRe.FindSubmatch(catalog)
+	if match == nil {
+		return nil, fmt.Errorf("%w: no /Pages in catalog", ErrMalformedPdf)
+	}
+	pagesNum, _ := strconv.Atoi(string(match[1]))
+	tree, err := pdfObjectAt(r, size, trailer.startxref, pagesNum)
+	if err != nil {
+		return nil, err
+	}
+
+	kids := pdfKidsRe.FindSubmatch(tree)
+	count := pdfCountRe.FindSubmatch(tree)
+	if kids == nil || count == nil {
+		return nil, fmt.Errorf("%w: no /Kids or /Count in page tree", ErrMalformedPdf)
+	}
+	pages := []int{}
+	for _, ref := range pdfRefRe.FindAllSubmatch(kids[1], -1) {
+		num, _ := strconv.Atoi(string(ref[1]))
+		pages = append(pages, num)
+	}
+	if strconv.Itoa(len(pages)) != string(count[1]) {
+		return nil, fmt.Errorf("%w: nested page tree", ErrMalformedPdf)
+	}
+	return pages, nil
+}
+
+// extendPdfDict appends entries to the end of dict.
+func extendPdfDict(dict []byte, entries string) (string, error) {
+	trimmed := strings.TrimSpace(string(dict))
+	if !strings.HasPrefix(trimmed, "<<") || !strings.HasSuffix(trimmed, ">>") {
+		return "", fmt.Errorf("%w: object is not a dictionary", ErrMalformedPdf)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(trimmed, ">>")) + entries + " >>", nil
+}
+
+// addPdfOutline adds the outline root with entries to update and returns
+// its number. pages are numbers of page objects.
+func addPdfOutline(update *pdfUpdate, entries []*pdfOutlineEntry, pages []int) (int, error) {
+	root := update.reserve()
+	first, last, err := addPdfOutlineEntries(update, entries, root, pages)
+	if err != nil {
+		return 0, err
+	}
+	update.set(root, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>",
+		first, last, len(entries)))
+	return root, nil
+}
+
+// addPdfOutlineEntries adds sibling entries under parent and returns the
+// numbers of the first and the last one. Every entry shows the top of its
+// page, nested entries are closed.
+func addPdfOutlineEntries(update *pdfUpdate, entries []*pdfOutlineEntry, parent int,
+	pages []int) (int, int, error) {
+	nums := make([]int, len(entries))
+	for i := range entries {
+		nums[i] = update.reserve()
+	}
+
+	for i, entry := range entries {
+		if entry.page >= len(pages) {
+			return 0, 0, fmt.Errorf("%w: no page %d of outline entry %q", ErrMalformedPdf, entry.page+1, entry.title)
+		}
+		dict := fmt.Sprintf("<< /Title %s /Parent %d 0 R /Dest [%d 0 R /XYZ 0 %.2f null]",
+			pdfString(entry.title), parent, pages[entry.page], entry.height)
+		if i > 0 {
+			dict += fmt.Sprintf(" /Prev %d 0 R", nums[i-1])
+		}
+		if i < len(entries)-1 {
+			dict += fmt.Sprintf(" /Next %d 0 R", nums[i+1])
+		}
+		if len(entry.children) > 0 {
+			first, last, err := addPdfOutlineEntries(update, entry.children, nums[i], pages)
+			if err != nil {
+				return 0, 0, err
+			}
+			dict += fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count -%d", first, last, len(entry.children))
+		}
+		update.set(nums[i], dict+" >>")
+	}
+	return nums[0], nums[len(nums)-1], nil
+}
+
+// pdfTailWriter passes a PDF through and keeps its last bytes. gopdf
+// doesn't return write errors, the first one is kept in err.
+type pdfTailWriter struct {
+	w    io.Writer
+	n    int64
+	tail []byte
+	err  error
+}
+
+func (t *pdfTailWriter) Write(p []byte) (int, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+	n, err := t.w.Write(p)
+	t.n += int64(n)
+	t.err = err
+
+	written := p[:n]
+	if len(written) >= pdfTailSize {
+		t.tail = append(t.tail[:0], written[len(written)-pdfTailSize:]...)
+	} else {
+		t.tail = append(t.tail, written...)
+		if over := len(t.tail) - pdfTailSize; over > 0 {
+			t.tail = append(t.tail[:0], t.tail[over:]...)
+		}
+	}
+	return n, err
+}
+
+// pdfUpdate collects objects of an incremental update.
+type pdfUpdate struct {
+	trailer pdfTrailer
+	next    int
+	objects map[int]string
+}
+
+func newPdfUpdate(trailer pdfTrailer) *pdfUpdate {
+	return &pdfUpdate{
+		trailer: trailer,
+		next:    trailer.size,
+		objects: map[int]string{},
+	}
+}
+
+// reserve returns a number for a new object, its body is set later.
+func (u *pdfUpdate) reserve() int {
+	num := u.next
+	u.next++
+	return num
+}
+
+// set defines or redefines object num.
+func (u *pdfUpdate) set(num int, body string) {
+	u.objects[num] = body
+}
+
+// add defines a new object and returns its number.
+func (u *pdfUpdate) add(body string) int {
+	num := u.reserve()
+	u.set(num, body)
+	return num
+}
+
+// write appends the update to a PDF of offset bytes. info is the number of
+// the document information object, 0 means none.
+func (u *pdfUpdate) write(w io.Writer, offset int64, info int) error {
+	nums := make([]int, 0, len(u.objects))
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	slices.Sort(nums)
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("\n")
+	offsets := map[int]int64{}
+	for _, num := range nums {
+		offsets[num] = offset + int64(buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", num, u.objects[num])
+	}
+
+	xref := offset + int64(buf.Len())
+	buf.WriteString("xref\n")
+	for _, num := range nums {
+		fmt.Fprintf(buf, "%d 1\n%010d 00000 n \n", num, offsets[num])
+	}
+
+	buf.WriteString("trailer\n<<\n")
+	fmt.Fprintf(buf, "/Size %d\n/Root %d 0 R\n", u.next, u.trailer.root)
+	if info != 0 {
+		fmt.Fprintf(buf, "/Info %d 0 R\n", info)
+	}
+	fmt.Fprintf(buf, "/Prev %d\n>>\nstartxref\n%d\n%%%%EOF\n", u.trailer.startxref, xref)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// pdfString encodes a text string as UTF-16BE, so any language is shown.
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, r := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// pdfText decodes a literal or a hex string, hex strings starting with the
+// FEFF mark are UTF-16BE.
+func pdfText(s string) string {
+	if strings.HasPrefix(s, "(") {
+		return pdfLiteralReplacer.Replace(strings.TrimSuffix(strings.TrimPrefix(s, "("), ")"))
+	}
+	data, err := hex.DecodeString(strings.Trim(s, "<>"))
+	if err != nil {
+		return s
+	}
+	if len(data) < 2 || data[0] != 0xFE || data[1] != 0xFF {
+		return string(data)
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+var pdfLiteralReplacer = strings.NewReplacer(`\(`, "(", `\)`, ")", `\\`, `\`)
+
+var pdfInfoEntryRe = regexp.MustCompile(`/(\w+)\s*(<[0-9A-Fa-f]*>|\((?:\\.|[^\\)])*\))`)
+
+// readPdfInfo returns the latest document information dictionary of a PDF
+// of size bytes, it is empty when the trailer has none.
+func readPdfInfo(r io.ReaderAt, size int64, trailer pdfTrailer) (string, error) {
+	if trailer.info == 0 {
+		return "", nil
+	}
+	body, err := pdfObjectAt(r, size, trailer.startxref, trailer.info)
+	return strings.TrimSpace(string(body)), err
+}
+
+// pdfInfoEntries returns decoded text entries of an information dictionary.
+func pdfInfoEntries(dict string) [][2]string {
+	entries := [][2]string{}
+	for _, match := range pdfInfoEntryRe.FindAllStringSubmatch(dict, -1) {
+		entries = append(entries, [2]string{match[1], pdfText(match[2])})
+	}
+	return entries
+}
+
+// pdfInfoText returns entries of an information dictionary as lines.
+func pdfInfoText(dict string) string {
+	var b strings.Builder
+	for _, entry := range pdfInfoEntries(dict) {
+		fmt.Fprintf(&b, "%s: %s\n", entry[0], entry[1])
+	}
+	return b.String()
+}
+
+// pdfDate formats t in UTC, as the Z suffix says.
+func pdfDate(t time.Time) string {
+	return "(D:" + t.UTC().Format("20060102150405") + "Z)"
+}
@@ -0,0 +1,479 @@
+package filekit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// ErrMalformedEpub is returned when an epub can't be retagged because its
+// package document is not understood.
+var ErrMalformedEpub = errors.New("malformed epub")
+
+// TaggedFile is a finished file opened to rewrite its metadata, pages are
+// copied as is. cbz, cbt, fixed-layout epub and pdf files can be retagged,
+// reflowable epub files record no MangaDex IDs.
+type TaggedFile struct {
+	Path string
+	// Format is the container extension of the file.
+	Format string
+	// Metadata read from the file, pdf files keep only a part of it.
+	Metadata metadata.Metadata
+	// opf is the package document of epub files at opfPath
+	opf     []byte
+	opfPath string
+	pkg     epubPackage
+	// pdfTrailer is the last trailer of pdf files and pdfInfo their
+	// document information dictionary
+	pdfTrailer pdfTrailer
+	pdfInfo    string
+}
+
+// OpenTaggedFile reads metadata of the file at filePath. Files without
+// metadata, e.g. plain zip archives, return ErrBookNotSupported.
+func OpenTaggedFile(filePath string) (*TaggedFile, error) {
+	name := filepath.Base(filePath)
+	f := &TaggedFile{Path: filePath}
+
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), ".")) {
+	case CBZ_EXT, CBT_EXT:
+		book, err := OpenBook(filePath)
+		if err != nil {
+			return nil, err
+		}
+		book.Close()
+		if book.Format == ZIP_EXT {
+			return nil, fmt.Errorf("%w: %s has no ComicInfo.xml", ErrBookNotSupported, name)
+		}
+		f.Format = book.Format
+		f.Metadata = book.Metadata
+	case EPUB_EXT:
+		if err := f.readEpub(); err != nil {
+			return nil, err
+		}
+	case PDF_EXT:
+		if err := f.readPdf(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrBookNotSupported, name)
+	}
+	return f, nil
+}
+
+// readPdf reads the trailer and the document information of a pdf file,
+// pages are not read.
+func (f *TaggedFile) readPdf() error {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	tail, err := readPdfTail(file, stat.Size())
+	if err != nil {
+		return err
+	}
+	if f.pdfTrailer, err = readPdfTrailer(tail); err != nil {
+		return err
+	}
+	if f.pdfInfo, err = readPdfInfo(file, stat.Size(), f.pdfTrailer); err != nil {
+		return err
+	}
+	f.Format = PDF_EXT
+	f.Metadata = metadataFromPdfInfo(f.pdfInfo)
+	return nil
+}
+
+// metadataFromPdfInfo reads entries of pdfInfo back.
+func metadataFromPdfInfo(dict string) metadata.Metadata {
+	ci := metadata.ComicInfoMetadata{XMLName: xml.Name{Local: "ComicInfo"}}
+	keywords := ""
+	for _, entry := range pdfInfoEntries(dict) {
+		switch entry[0] {
+		case "Subject":
+			ci.Title = entry[1]
+		case "Series":
+			ci.Series = entry[1]
+		case "Volume":
+			ci.Volume = entry[1]
+		case "Number":
+			ci.Number = entry[1]
+		case "LanguageISO":
+			ci.LanguageISO = entry[1]
+		case "Translator":
+			ci.Translator = entry[1]
+		case "Keywords":
+			keywords = entry[1]
+		}
+	}
+	m := metadataFromComicInfo(ci)
+	m.SetSourceIDs(metadata.ParseSourceIDs(keywords))
+	return m
+}
+
+func (f *TaggedFile) readEpub() error {
+	reader, err := zip.OpenReader(f.Path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	pkg, pkgPath, err := readEpubPackage(files)
+	if err != nil {
+		return err
+	}
+	if !isFixedLayout(pkg) {
+		return fmt.Errorf("%w: only fixed-layout epub files can be retagged, "+
+			"reflowable ones record no MangaDex IDs", ErrBookNotSupported)
+	}
+	opf, err := readZipFile(files[pkgPath])
+	if err != nil {
+		return err
+	}
+	if _, _, ok := opfMetadataBounds(opf); !ok {
+		return fmt.Errorf("%w: no metadata in %s", ErrMalformedEpub, pkgPath)
+	}
+
+	f.Format = EPUB_EXT
+	f.Metadata = metadataFromEpub(pkg)
+	f.opf, f.opfPath, f.pkg = opf, pkgPath, pkg
+	return nil
+}
+
+func isFixedLayout(pkg epubPackage) bool {
+	for _, meta := range pkg.Metadata.Meta {
+		if meta.Property == "rendition:layout" && strings.TrimSpace(meta.Value) == "pre-paginated" {
+			return true
+		}
+	}
+	return false
+}
+
+// opfMetadataBounds returns the start and the end of the metadata element of
+// a package document.
+func opfMetadataBounds(opf []byte) (int, int, bool) {
+	start := bytes.Index(opf, []byte("<metadata"))
+	end := bytes.Index(opf, []byte("</metadata>"))
+	if start == -1 || end < start {
+		return 0, 0, false
+	}
+	return start, end + len("</metadata>"), true
+}
+
+// Tags returns metadata stored in the file as text, to compare it with
+// NewTags before retagging.
+func (f *TaggedFile) Tags() (string, error) {
+	switch f.Format {
+	case EPUB_EXT:
+		start, end, _ := opfMetadataBounds(f.opf)
+		return string(f.opf[start:end]), nil
+	case PDF_EXT:
+		return pdfInfoText(f.pdfInfo), nil
+	}
+	return comicInfoTags(f.Metadata, f.Format == CBZ_EXT)
+}
+
+// NewTags returns metadata Retag writes as text and reports whether it
+// differs from Tags. Dates of the file are kept if nothing else changes.
+// chapterRange is used in titles of merged epub and pdf files.
+func (f *TaggedFile) NewTags(m metadata.Metadata, chapterRange string) (string, bool, error) {
+	current, err := f.Tags()
+	if err != nil {
+		return "", false, err
+	}
+	kept, err := f.newTags(m, chapterRange, true)
+	if err != nil || kept == current {
+		return kept, false, err
+	}
+	tags, err := f.newTags(m, chapterRange, false)
+	return tags, true, err
+}
+
+func (f *TaggedFile) newTags(m metadata.Metadata, chapterRange string, keepDates bool) (string, error) {
+	switch f.Format {
+	case EPUB_EXT:
+		return f.opfMetadata(m, chapterRange, keepDates)
+	case PDF_EXT:
+		return pdfInfoText(f.newPdfInfo(m, chapterRange, keepDates)), nil
+	}
+	if keepDates {
+		m.CBI.AppID = f.Metadata.CBI.AppID
+		m.CBI.LastModified = f.Metadata.CBI.LastModified
+	}
+	return comicInfoTags(f.withPages(m), f.Format == CBZ_EXT)
+}
+
+// Retag replaces ComicInfo.xml and the ComicBookInfo comment of archives,
+// the package metadata of epub files or the document information of pdf
+// files with m.
+func (f *TaggedFile) Retag(m metadata.Metadata, chapterRange string) error {
+	switch f.Format {
+	case CBZ_EXT:
+		return f.retagZip(f.withPages(m))
+	case CBT_EXT:
+		return f.retagTar(f.withPages(m))
+	case EPUB_EXT:
+		return f.retagEpub(m, chapterRange)
+	case PDF_EXT:
+		return f.retagPdf(m, chapterRange)
+	}
+	return fmt.Errorf("%w: %s", ErrBookNotSupported, filepath.Base(f.Path))
+}
+
+// withPages returns m with the page list of the file, pages are not changed
+// by retagging.
+func (f *TaggedFile) withPages(m metadata.Metadata) metadata.Metadata {
+	m.CI.Pages = f.Metadata.CI.Pages
+	m.CI.PageCount = f.Metadata.CI.PageCount
+	return m
+}
+
+func comicInfoTags(m metadata.Metadata, withComment bool) (string, error) {
+	content, err := m.CI.MarshalComicInfo()
+	if err != nil {
+		return "", err
+	}
+	if !withComment {
+		return string(content), nil
+	}
+	comment, err := json.MarshalIndent(m.CBI, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n" + string(comment), nil
+}
+
+func (f *TaggedFile) retagZip(m metadata.Metadata) error {
+	reader, err := zip.OpenReader(f.Path)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(f.Path, reader, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, file := range reader.File {
+			if file.Name == comicInfoFileName {
+				continue
+			}
+			if err := zw.Copy(file); err != nil {
+				return err
+			}
+		}
+
+		comicInfo, err := m.CI.MarshalComicInfo()
+		if err != nil {
+			return err
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     comicInfoFileName,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(comicInfo); err != nil {
+			return err
+		}
+
+		comment, err := json.Marshal(m.CBI)
+		if err != nil {
+			return err
+		}
+		if err := zw.SetComment(string(comment)); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+func (f *TaggedFile) retagTar(m metadata.Metadata) error {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(f.Path, file, func(w io.Writer) error {
+		tr := tar.NewReader(file)
+		tw := tar.NewWriter(w)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if header.Name == comicInfoFileName {
+				continue
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+
+		comicInfo, err := m.CI.MarshalComicInfo()
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     comicInfoFileName,
+			Mode:     0644,
+			Size:     int64(len(comicInfo)),
+			ModTime:  time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(comicInfo); err != nil {
+			return err
+		}
+		return tw.Close()
+	})
+}
+
+// opfMetadata renders the metadata element of the package document. The
+// book identifier, the cover and additional Kindle entries are kept.
+func (f *TaggedFile) opfMetadata(m metadata.Metadata, chapterRange string, keepDates bool) (string, error) {
+	book := fixedLayoutBook{
+		Identifier: newUUID(),
+		Modified:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	book.setMetadata(m, chapterRange)
+	if ids := f.pkg.Metadata.Identifiers; len(ids) > 0 {
+		book.Identifier = strings.TrimPrefix(ids[0], "urn:uuid:")
+	}
+	for _, meta := range f.pkg.Metadata.Meta {
+		if meta.Property == "dcterms:modified" && keepDates {
+			book.Modified = strings.TrimSpace(meta.Value)
+		}
+		if meta.Name != "" && meta.Name != "fixed-layout" {
+			book.Meta = append(book.Meta, [2]string{meta.Name, meta.Content})
+		}
+	}
+
+	var b strings.Builder
+	if err := epubMetadataTemplate.Execute(&b, book); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (f *TaggedFile) retagEpub(m metadata.Metadata, chapterRange string) error {
+	block, err := f.opfMetadata(m, chapterRange, false)
+	if err != nil {
+		return err
+	}
+	start, end, _ := opfMetadataBounds(f.opf)
+	opf := slices.Concat(f.opf[:start], []byte(block), f.opf[end:])
+
+	reader, err := zip.OpenReader(f.Path)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(f.Path, reader, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, file := range reader.File {
+			if file.Name != f.opfPath {
+				if err := zw.Copy(file); err != nil {
+					return err
+				}
+				continue
+			}
+			fw, err := zw.Create(file.Name)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(opf); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	})
+}
+
+var (
+	pdfCreationDateRe = regexp.MustCompile(`/CreationDate\s*\([^)]*\)`)
+	pdfModDateRe      = regexp.MustCompile(`/ModDate\s*\([^)]*\)`)
+)
+
+// newPdfInfo returns the document information dictionary for m, the
+// creation date of the file is kept.
+func (f *TaggedFile) newPdfInfo(m metadata.Metadata, chapterRange string, keepDates bool) string {
+	info := pdfInfo(m, chapterRange)
+	dates := []*regexp.Regexp{pdfCreationDateRe}
+	if keepDates {
+		dates = append(dates, pdfModDateRe)
+	}
+	for _, re := range dates {
+		if date := re.FindString(f.pdfInfo); date != "" {
+			info = re.ReplaceAllLiteralString(info, date)
+		}
+	}
+	return info
+}
+
+// retagPdf appends an incremental update with new document information,
+// the pages are not rewritten.
+func (f *TaggedFile) retagPdf(m metadata.Metadata, chapterRange string) error {
+	src, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	update := newPdfUpdate(f.pdfTrailer)
+	info := update.add(f.newPdfInfo(m, chapterRange, false))
+
+	return replaceFile(f.Path, src, func(w io.Writer) error {
+		size, err := io.Copy(w, src)
+		if err != nil {
+			return err
+		}
+		return update.write(w, size, info)
+	})
+}
+
+// replaceFile writes a new version of filePath and renames it into place.
+// src reading the old version is closed before the rename.
+func replaceFile(filePath string, src io.Closer, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), tempPattern)
+	if err != nil {
+		src.Close()
+		return err
+	}
+
+	err = write(tmp)
+	src.Close()
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return commitTempFile(tmp, filePath)
+}
@@ -0,0 +1,113 @@
+package filekit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+func TestRetag(t *testing.T) {
+	ids := metadata.SourceIDs{Manga: "m1", Chapters: []string{"c1", "c2"}}
+	m := metadata.Metadata{
+		CI: metadata.ComicInfoMetadata{Series: "Old Series", Number: "1", Volume: "1", LanguageISO: "en"},
+	}
+	m.SetSourceIDs(ids)
+
+	testCases := []struct {
+		ext  string
+		name string
+	}{
+		{CBZ_EXT, "out.cbz"},
+		{CBT_EXT, "out.cbt"},
+		{EPUB_EXT, "out.epub"},
+		{KINDLE_EXT, "out.epub"},
+		{PDF_EXT, "out.pdf"},
+	}
+
+	for _, tc := range testCases {
+		dir := t.TempDir()
+		writeMergedTestContainer(t, tc.ext, dir, Options{}, m)
+		filePath := filepath.Join(dir, tc.name)
+
+		f, err := OpenTaggedFile(filePath)
+		if err != nil {
+			t.Fatalf("Test Case: %s. Unexpected error: %v", tc.ext, err)
+		}
+		got := f.Metadata.SourceIDs()
+		if got.Manga != ids.Manga || !slices.Equal(got.Chapters, ids.Chapters) {
+			t.Errorf("Test Case: %s. Expected IDs %v, but got %v", tc.ext, ids, got)
+		}
+
+		retagged := m
+		retagged.CI.Series = "New Series"
+		if err := f.Retag(retagged, "1-2"); err != nil {
+			t.Fatalf("Test Case: %s. Unexpected error: %v", tc.ext, err)
+		}
+
+		f, err = OpenTaggedFile(filePath)
+		if err != nil {
+			t.Fatalf("Test Case: %s. Unexpected error after retag: %v", tc.ext, err)
+		}
+		tags, err := f.Tags()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(tags, "New Series") || strings.Contains(tags, "Old Series") {
+			t.Errorf("Test Case: %s. Expected new series in metadata, but got:\n%s", tc.ext, tags)
+		}
+		if got := f.Metadata.SourceIDs(); got.Manga != ids.Manga {
+			t.Errorf("Test Case: %s. Expected IDs kept, but got %v", tc.ext, got)
+		}
+		if _, isChanged, err := f.NewTags(retagged, "1-2"); err != nil || isChanged {
+			t.Errorf("Test Case: %s. Expected no changes after retag, but got changes (%v)", tc.ext, err)
+		}
+		if cover := `<meta name="cover" content="image-001"/>`; f.opf != nil && strings.Count(string(f.opf), cover) != 1 {
+			t.Errorf("Test Case: %s. Expected the cover kept once, but got:\n%s", tc.ext, f.opf)
+		}
+
+		if tc.ext == PDF_EXT {
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trailer, err := readPdfTrailer(content)
+			if err != nil {
+				t.Fatalf("Test Case: %s. Unexpected error: %v", tc.ext, err)
+			}
+			r := bytes.NewReader(content)
+			catalog, err := pdfObjectAt(r, int64(len(content)), trailer.startxref, trailer.root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages, err := readPdfPages(r, int64(len(content)), trailer, catalog)
+			if err != nil || len(pages) != 4 {
+				t.Errorf("Test Case: %s. Expected 4 pages, but got %v (%v)", tc.ext, pages, err)
+			}
+			continue
+		}
+		book, err := OpenBook(filePath)
+		if err != nil {
+			t.Fatalf("Test Case: %s. Unexpected error: %v", tc.ext, err)
+		}
+		if book.PageCount() != 4 || len(book.Chapters) != 2 {
+			t.Errorf("Test Case: %s. Expected 4 pages in 2 chapters, but got %d pages in %d chapters",
+				tc.ext, book.PageCount(), len(book.Chapters))
+		}
+		book.Close()
+	}
+}
+
+func TestRetagReflowableEpub(t *testing.T) {
+	dir := t.TempDir()
+	writeMergedTestContainer(t, EPUB_EXT, dir, Options{EpubLayout: EPUB_LAYOUT_REFLOW}, metadata.Metadata{})
+
+	if _, err := OpenTaggedFile(filepath.Join(dir, "out.epub")); !errors.Is(err, ErrBookNotSupported) {
+		t.Errorf("Expected %v for a reflowable epub, but got %v", ErrBookNotSupported, err)
+	}
+}
@@ -0,0 +1,319 @@
+// Package config reads and writes the mdx config file, a TOML file with
+// default values of command flags.
+//
+// Keys are flag names. Keys before any table apply to every command with the
+// flag, keys of a table named after a command apply to that command only, e.g.
+// [download] or [library.missing], and keys of [profiles.<name>] tables apply
+// to every command when the profile is selected:
+//
+//	language = "en"
+//	profile = "kobo"
+//
+//	[download]
+//	ext = "cbz"
+//	output = "~/Manga"
+//
+//	[profiles.kobo]
+//	ext = "epub"
+//	device = "kobo-libra"
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/spf13/pflag"
+)
+
+const (
+	fileName = "config.toml"
+	// PROFILE_TABLE is the prefix of profile tables.
+	PROFILE_TABLE = "profiles"
+	// PROFILE_FLAG selects the profile table.
+	PROFILE_FLAG = "profile"
+	// ENV_PREFIX starts names of environment variables with flag values.
+	ENV_PREFIX = "MDX_"
+)
+
+// Path returns the path of the config file in the user config directory,
+// $XDG_CONFIG_HOME/mdx/config.toml on Linux.
+func Path() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "mdx", fileName)
+}
+
+// Config is the config file.
+type Config struct {
+	data map[string]any
+	// keys are dotted keys of values in the order of the file, keys added
+	// with Set are appended
+	keys []string
+}
+
+// Entry is a key of the config file with its values. Values have one
+// element unless the key is an array.
+type Entry struct {
+	Key    string
+	Values []string
+}
+
+// Load reads the config file at path, a missing file is an empty config.
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Read(strings.NewReader(""))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+func Read(r io.Reader) (*Config, error) {
+	c := &Config{data: map[string]any{}}
+	md, err := toml.NewDecoder(r).Decode(&c.data)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range md.Keys() {
+		if _, ok := c.Get(key.String()); ok {
+			c.keys = append(c.keys, key.String())
+		}
+	}
+	return c, nil
+}
+
+// Get returns values of key, e.g. "language", "download.ext" or
+// "profiles.kobo.ext". Tables have no values.
+func (c *Config) Get(key string) ([]string, bool) {
+	value, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	if array, isArray := value.([]any); isArray {
+		values := []string{}
+		for _, element := range array {
+			v, ok := formatValue(element)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, v)
+		}
+		return values, true
+	}
+	v, ok := formatValue(value)
+	if !ok {
+		return nil, false
+	}
+	return []string{v}, true
+}
+
+// Set replaces values of key, tables of the key are added if they are
+// missing.
+func (c *Config) Set(key string, values []string) {
+	parts := strings.Split(key, ".")
+	table := c.data
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := table[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			table[part] = next
+		}
+		table = next
+	}
+
+	if len(values) == 1 {
+		table[parts[len(parts)-1]] = parseValue(values[0])
+	} else {
+		array := []any{}
+		for _, value := range values {
+			array = append(array, parseValue(value))
+		}
+		table[parts[len(parts)-1]] = array
+	}
+
+	for _, k := range c.keys {
+		if k == key {
+			return
+		}
+	}
+	c.keys = append(c.keys, key)
+}
+
+// Entries returns keys of the config file in the order of the file.
+func (c *Config) Entries() []Entry {
+	entries := []Entry{}
+	for _, key := range c.keys {
+		if values, ok := c.Get(key); ok {
+			entries = append(entries, Entry{Key: key, Values: values})
+		}
+	}
+	return entries
+}
+
+// HasTable reports whether the table is in the config file.
+func (c *Config) HasTable(table string) bool {
+	value, ok := c.lookup(table)
+	if !ok {
+		return false
+	}
+	_, isTable := value.(map[string]any)
+	return isTable
+}
+
+// Save replaces the config file at path, an interrupted save keeps the old
+// file. Comments of the file are not kept.
+func (c *Config) Save(path string) error {
+	return filekit.WriteFileAtomic(path, func(w io.Writer) error {
+		encoder := toml.NewEncoder(w)
+		encoder.Indent = ""
+		return encoder.Encode(c.data)
+	})
+}
+
+// EnvName returns the environment variable of a flag, e.g. MDX_FILE_NAME
+// for file-name.
+func EnvName(flag string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// Apply sets flags which are not set on the command line. Values are taken
+// from MDX_* environment variables, the table of the selected profile, the
+// command table, e.g. "library.missing", and keys of all commands, in this
+// order. The profile flag itself is taken from the environment and keys of
+// all commands only.
+func (c *Config) Apply(flags *pflag.FlagSet, command string) error {
+	tables := []string{command, ""}
+	if f := flags.Lookup(PROFILE_FLAG); f != nil {
+		if err := c.applyFlag(flags, f, ""); err != nil {
+			return err
+		}
+		if profile := f.Value.String(); profile != "" {
+			table := PROFILE_TABLE + "." + profile
+			if !c.HasTable(table) {
+				return fmt.Errorf("profile %s is not in the config file", profile)
+			}
+			tables = append([]string{table}, tables...)
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err == nil && f.Name != "help" && f.Name != PROFILE_FLAG {
+			err = c.applyFlag(flags, f, tables...)
+		}
+	})
+	return err
+}
+
+// applyFlag sets f from its environment variable or the first of tables
+// with the key, unless f is set on the command line.
+func (c *Config) applyFlag(flags *pflag.FlagSet, f *pflag.Flag, tables ...string) error {
+	if f.Changed {
+		return nil
+	}
+	envName := EnvName(f.Name)
+	if value, ok := os.LookupEnv(envName); ok {
+		if err := flags.Set(f.Name, value); err != nil {
+			return fmt.Errorf("setting --%s from %s: %w", f.Name, envName, err)
+		}
+		return nil
+	}
+	for _, table := range tables {
+		key := f.Name
+		if table != "" {
+			key = table + "." + f.Name
+		}
+		values, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		for _, value := range values {
+			if err := flags.Set(f.Name, expandHome(value)); err != nil {
+				return fmt.Errorf("setting --%s from %s: %w", f.Name, key, err)
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// expandHome replaces ~ at the start of a path from the config file with the
+// home directory, shells do it for paths on the command line.
+func expandHome(value string) string {
+	if value != "~" && !strings.HasPrefix(value, "~/") {
+		return value
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return value
+	}
+	return filepath.Join(home, value[1:])
+}
+
+// SplitKey splits a dotted key into the table and the key name, e.g.
+// "profiles.kobo.ext" into "profiles.kobo" and "ext".
+func SplitKey(key string) (table, name string) {
+	i := strings.LastIndex(key, ".")
+	if i == -1 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+// lookup returns the value or the table of a dotted key.
+func (c *Config) lookup(key string) (any, bool) {
+	var value any = c.data
+	for _, part := range strings.Split(key, ".") {
+		table, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = table[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// formatValue returns a value of the file as text, tables and arrays are
+// not values of flags.
+func formatValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case map[string]any, []any, []map[string]any:
+		return "", false
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// parseValue returns value as a boolean or a number if it is written like
+// one, so values are saved without quotes. Values read back as other text,
+// e.g. "007", stay strings.
+func parseValue(value string) any {
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(n, 10) == value {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == value {
+		return f
+	}
+	return value
+}
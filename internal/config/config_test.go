@@ -0,0 +1,261 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const testConfig = `# defaults of all commands
+language = "en" # english
+profile = 'kobo'
+download.merge = true
+
+[download]
+ext = "cbz"
+file-name = "%3 ch%5 #1"
+translated-by = [
+  "Group A", # the first one
+  "Group B",
+]
+
+["library".missing]
+language = "uk"
+
+[profiles.kobo]
+ext = "epub"
+gamma = 1.2
+`
+
+func TestRead(t *testing.T) {
+	c, err := Read(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		values []string
+	}{
+		{"language", []string{"en"}},
+		{"profile", []string{"kobo"}},
+		{"download.ext", []string{"cbz"}},
+		{"download.file-name", []string{"%3 ch%5 #1"}},
+		{"download.merge", []string{"true"}},
+		{"download.translated-by", []string{"Group A", "Group B"}},
+		{"library.missing.language", []string{"uk"}},
+		{"profiles.kobo.ext", []string{"epub"}},
+		{"profiles.kobo.gamma", []string{"1.2"}},
+		{"ext", nil},
+		{"download", nil},
+	}
+
+	for _, tt := range tests {
+		values, _ := c.Get(tt.key)
+		if !slices.Equal(values, tt.values) {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", tt.key, tt.values, values)
+		}
+	}
+
+	if !c.HasTable("profiles.kobo") || c.HasTable("profiles.phone") || c.HasTable("language") {
+		t.Errorf("Expected only profiles.kobo of profiles to be a table")
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Unquoted string", "ext = cbz"},
+		{"Missing value", "ext ="},
+		{"Unterminated string", `ext = "cbz`},
+		{"Unterminated array", `translated-by = ["a", "b"`},
+		{"Duplicate key", "ext = \"cbz\"\next = \"pdf\""},
+	}
+
+	for _, tt := range tests {
+		if _, err := Read(strings.NewReader(tt.content)); err == nil {
+			t.Errorf("Test Case: %s. Expected error, but got nil", tt.name)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mdx", fileName)
+	c, err := Load(path)
+	if err != nil || len(c.Entries()) != 0 {
+		t.Fatalf("Expected an empty config without a file, but got %v (%v)", c.Entries(), err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	c.Set("language", []string{"uk"})
+	c.Set("output-format", []string{"plain"})
+	c.Set("download.output", []string{"~/Manga"})
+	c.Set("download.file-name", []string{"007"})
+	c.Set("profiles.phone.ext", []string{"pdf"})
+	c.Set("profiles.phone.width", []string{"1080"})
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	saved, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	want := map[string][]string{
+		"language":                 {"uk"},
+		"profile":                  {"kobo"},
+		"output-format":            {"plain"},
+		"download.ext":             {"cbz"},
+		"download.merge":           {"true"},
+		"download.file-name":       {"007"},
+		"download.output":          {"~/Manga"},
+		"download.translated-by":   {"Group A", "Group B"},
+		"library.missing.language": {"uk"},
+		"profiles.kobo.ext":        {"epub"},
+		"profiles.kobo.gamma":      {"1.2"},
+		"profiles.phone.ext":       {"pdf"},
+		"profiles.phone.width":     {"1080"},
+	}
+	entries := saved.Entries()
+	if len(entries) != len(want) {
+		t.Errorf("Expected %d keys, but got %v", len(want), entries)
+	}
+	for _, entry := range entries {
+		if !slices.Equal(entry.Values, want[entry.Key]) {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", entry.Key, want[entry.Key], entry.Values)
+		}
+	}
+
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(files) != 1 {
+		t.Errorf("Expected only %s in the config directory, but got %v (%v)", fileName, files, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	const content = `language = "en"
+ext = "cbz"
+output = "~/Manga"
+translated-by = ["Group A", "Group B"]
+
+[download]
+ext = "pdf"
+language = "de"
+
+[profiles.kobo]
+ext = "epub"
+
+[profiles.phone]
+language = "uk"
+`
+	c, err := Read(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		env     map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "Keys of all commands",
+			command: "info",
+			want: map[string]string{"language": "en", "ext": "cbz", "output": filepath.Join(home, "Manga"),
+				"translated-by": "[Group A,Group B]"},
+		},
+		{
+			name:    "Command table",
+			command: "download",
+			want:    map[string]string{"language": "de", "ext": "pdf"},
+		},
+		{
+			name:    "Profile",
+			command: "download",
+			args:    []string{"--profile", "kobo"},
+			want:    map[string]string{"language": "de", "ext": "epub"},
+		},
+		{
+			name:    "Profile from the environment",
+			command: "download",
+			env:     map[string]string{"MDX_PROFILE": "phone"},
+			want:    map[string]string{"language": "uk", "ext": "pdf"},
+		},
+		{
+			name:    "Environment",
+			command: "download",
+			args:    []string{"--profile", "kobo"},
+			env:     map[string]string{"MDX_EXT": "cbt", "MDX_TRANSLATED_BY": "Group C"},
+			want:    map[string]string{"ext": "cbt", "translated-by": "[Group C]"},
+		},
+		{
+			name:    "Command line",
+			command: "download",
+			args:    []string{"--profile", "kobo", "--ext", "zip", "--translated-by", "Group D"},
+			env:     map[string]string{"MDX_EXT": "cbt"},
+			want:    map[string]string{"ext": "zip", "language": "de", "translated-by": "[Group D]"},
+		},
+		{
+			name:    "Missing profile",
+			command: "download",
+			args:    []string{"--profile", "tablet"},
+			wantErr: true,
+		},
+		{
+			name:    "Malformed value",
+			command: "download",
+			env:     map[string]string{"MDX_WIDTH": "wide"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			flags := pflag.NewFlagSet(tt.command, pflag.ContinueOnError)
+			flags.String(PROFILE_FLAG, "", "")
+			flags.String("language", "", "")
+			flags.String("ext", "", "")
+			flags.String("output", "", "")
+			flags.StringSlice("translated-by", nil, "")
+			flags.Int("width", 0, "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := c.Apply(flags, tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Test Case: %s. Expected error %v, but got %v", tt.name, tt.wantErr, err)
+			}
+			for name, want := range tt.want {
+				if got := flags.Lookup(name).Value.String(); got != want {
+					t.Errorf("Test Case: %s. Expected --%s %q, but got %q", tt.name, name, want, got)
+				}
+			}
+		})
+	}
+}
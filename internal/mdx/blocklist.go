@@ -0,0 +1,92 @@
+package mdx
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/pterm/pterm"
+)
+
+const blocklistFileName = "blocklist.txt"
+
+// BlocklistPath returns the path of the blocklist of pages dropped with
+// --skip-credits, it is kept in the user config directory.
+func BlocklistPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "mdx", blocklistFileName)
+}
+
+// LoadBlocklist reads the blocklist or exits.
+func LoadBlocklist() *imaging.Blocklist {
+	blocklist, err := imaging.LoadBlocklist(BlocklistPath())
+	if err != nil {
+		e.Printfln("While reading blocklist %s: %v", BlocklistPath(), err)
+		os.Exit(1)
+	}
+	return blocklist
+}
+
+// AddToBlocklist adds hashes of page images, e.g. credit or recruitment
+// pages, to the blocklist.
+func AddToBlocklist(files []string, note string) {
+	blocklist := LoadBlocklist()
+
+	added := 0
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			e.Printfln("While reading %s: %v", file, err)
+			os.Exit(1)
+		}
+		img, err := imaging.Decode(content)
+		if err != nil {
+			e.Printfln("%s is not a jpg, png or gif image: %v", file, err)
+			os.Exit(1)
+		}
+
+		entryNote := note
+		if entryNote == "" {
+			entryNote = filepath.Base(file)
+		}
+		if !blocklist.Add(imaging.Hash(img), entryNote) {
+			pterm.Warning.Printfln("%s is already in the blocklist", file)
+			continue
+		}
+		added++
+	}
+
+	if err := filekit.WriteFileAtomic(BlocklistPath(), blocklist.Write); err != nil {
+		e.Printfln("While saving blocklist %s: %v", BlocklistPath(), err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Added %d pages to %s", added, BlocklistPath())
+}
+
+// ListBlocklist prints hashes of blocked pages.
+func ListBlocklist() {
+	blocklist := LoadBlocklist()
+	if isMachineOutput() {
+		type entryResult struct {
+			Hash string `json:"hash"`
+			Note string `json:"note,omitempty"`
+		}
+		results := []entryResult{}
+		for _, entry := range blocklist.Entries {
+			results = append(results, entryResult{Hash: entry.Hash.String(), Note: entry.Note})
+		}
+		printResults(results)
+		return
+	}
+	if len(blocklist.Entries) == 0 {
+		dp.Println("Blocklist " + BlocklistPath() + " is empty")
+		return
+	}
+	for _, entry := range blocklist.Entries {
+		dp.Println(entry.Hash.String() + " " + entry.Note)
+	}
+}
@@ -0,0 +1,316 @@
+package mdx
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arimatakao/mdx/mangadexapi"
+	"github.com/pterm/pterm"
+)
+
+const (
+	chapterStatusExternal  = "external"
+	chapterStatusScheduled = "scheduled"
+)
+
+// chapterReport is the chapter and volume tree of a manga in one language
+// with problems found in it.
+type chapterReport struct {
+	MangaID  string          `json:"mangaId"`
+	Title    string          `json:"title"`
+	Language string          `json:"language"`
+	Volumes  []reportVolume  `json:"volumes"`
+	Gaps     []chapterGap    `json:"gaps"`
+	Other    []otherLanguage `json:"otherLanguagesOnly"`
+	// External are chapters hosted on other sites, e.g. MangaPlus, they
+	// have no pages on MangaDex.
+	External  []reportChapter `json:"external"`
+	Scheduled []reportChapter `json:"scheduled"`
+}
+
+type reportVolume struct {
+	// Volume is empty for chapters without a volume.
+	Volume   string          `json:"volume"`
+	Chapters []reportChapter `json:"chapters"`
+}
+
+type reportChapter struct {
+	ID          string `json:"id"`
+	Volume      string `json:"volume,omitempty"`
+	Number      string `json:"number,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Pages       int    `json:"pages"`
+	Status      string `json:"status,omitempty"`
+	ExternalUrl string `json:"externalUrl,omitempty"`
+	// ReadableAt is when a scheduled chapter can be read, the later of its
+	// publish and readable dates. It is nil for other chapters.
+	ReadableAt *time.Time `json:"readableAt,omitempty"`
+}
+
+// chapterGap is a range of whole chapter numbers missing in the language.
+type chapterGap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// otherLanguage is a chapter number translated only to other languages.
+type otherLanguage struct {
+	Number    string   `json:"number"`
+	Languages []string `json:"languages"`
+}
+
+type checkParam struct {
+	mangaId  string
+	language string
+}
+
+func NewCheckParam(mangaId, language string) checkParam {
+	return checkParam{
+		mangaId:  mangaId,
+		language: language,
+	}
+}
+
+// RunCheck prints the chapter report of the manga.
+func (p checkParam) RunCheck() {
+	spinner, _ := pterm.DefaultSpinner.Start("Fetching chapters...")
+	resp, err := client.GetMangaInfo(p.mangaId)
+	if err != nil {
+		spinner.Fail("Failed to fetch manga info")
+		e.Printfln("While getting manga information: %v", err)
+		exit(1)
+	}
+	report := p.fetchReport(resp.MangaInfo())
+	spinner.Success("Fetched chapters")
+
+	if isMachineOutput() {
+		printResult(report)
+		return
+	}
+	printChapterReport(report)
+}
+
+// fetchReport fetches chapters in the language and, for numbers missing in
+// it, chapters in other languages found in the chapter aggregate.
+func (p checkParam) fetchReport(manga mangadexapi.MangaInfo) chapterReport {
+	chapters, err := client.GetChapterFeed(manga.ID, p.language)
+	if err != nil {
+		e.Printfln("While getting chapters: %v", err)
+		exit(1)
+	}
+	numbers, err := client.GetChapterNumbers(manga.ID)
+	if err != nil {
+		e.Printfln("While getting chapter numbers: %v", err)
+		exit(1)
+	}
+
+	otherIds := otherLanguageIds(chapters, numbers)
+	if len(otherIds) == 0 {
+		return newChapterReport(manga, chapters, p.language, time.Now())
+	}
+	others, err := client.GetChaptersByIds(otherIds)
+	if err != nil {
+		e.Printfln("While getting chapters in other languages: %v", err)
+		exit(1)
+	}
+	return newChapterReport(manga, append(chapters, others...), p.language, time.Now())
+}
+
+// otherLanguageIds returns IDs of aggregate chapters with numbers that none
+// of chapters has.
+func otherLanguageIds(chapters []mangadexapi.Chapter, numbers []mangadexapi.AggregateChapter) []string {
+	translated := map[string]bool{}
+	for _, chapter := range chapters {
+		translated[chapter.Number()] = true
+	}
+
+	ids := []string{}
+	for _, number := range numbers {
+		// chapters without a number are aggregated as "none"
+		if number.Chapter == "none" || translated[number.Chapter] {
+			continue
+		}
+		ids = append(ids, number.IDs()...)
+	}
+	return ids
+}
+
+func newChapterReport(manga mangadexapi.MangaInfo, chapters []mangadexapi.Chapter,
+	language string, now time.Time) chapterReport {
+	report := chapterReport{
+		MangaID:   manga.ID,
+		Title:     manga.Title("en"),
+		Language:  language,
+		Volumes:   []reportVolume{},
+		Gaps:      []chapterGap{},
+		Other:     []otherLanguage{},
+		External:  []reportChapter{},
+		Scheduled: []reportChapter{},
+	}
+
+	numbers := []string{}
+	otherLanguages := map[string][]string{}
+	for _, chapter := range chapters {
+		if chapter.Language() != language {
+			if chapter.Number() != "" {
+				otherLanguages[chapter.Number()] = appendUnique(otherLanguages[chapter.Number()],
+					chapter.Language())
+			}
+			continue
+		}
+
+		c := newReportChapter(chapter, now)
+		switch c.Status {
+		case chapterStatusExternal:
+			report.External = append(report.External, c)
+		case chapterStatusScheduled:
+			report.Scheduled = append(report.Scheduled, c)
+		}
+		numbers = appendUnique(numbers, c.Number)
+
+		i := slices.IndexFunc(report.Volumes, func(v reportVolume) bool {
+			return v.Volume == c.Volume
+		})
+		if i == -1 {
+			report.Volumes = append(report.Volumes, reportVolume{Volume: c.Volume})
+			i = len(report.Volumes) - 1
+		}
+		report.Volumes[i].Chapters = append(report.Volumes[i].Chapters, c)
+	}
+
+	// chapters without a volume go last
+	slices.SortStableFunc(report.Volumes, func(a, b reportVolume) int {
+		if a.Volume == "" || b.Volume == "" {
+			return strings.Compare(b.Volume, a.Volume)
+		}
+		return compareNumbers(a.Volume, b.Volume)
+	})
+
+	for number, languages := range otherLanguages {
+		if !slices.Contains(numbers, number) {
+			slices.Sort(languages)
+			report.Other = append(report.Other, otherLanguage{Number: number, Languages: languages})
+		}
+	}
+	slices.SortFunc(report.Other, func(a, b otherLanguage) int {
+		return compareNumbers(a.Number, b.Number)
+	})
+
+	report.Gaps = chapterGaps(numbers)
+	return report
+}
+
+func newReportChapter(chapter mangadexapi.Chapter, now time.Time) reportChapter {
+	c := reportChapter{
+		ID:     chapter.ID,
+		Volume: chapter.Volume(),
+		Number: chapter.Number(),
+		Title:  chapter.Title(),
+		Group:  chapter.Translator(),
+		Pages:  chapter.PagesCount(),
+	}
+	switch {
+	case chapter.IsExternal():
+		c.Status = chapterStatusExternal
+		c.ExternalUrl = chapter.ExternalUrl()
+	case !chapter.IsReadable(now):
+		c.Status = chapterStatusScheduled
+		readableAt := chapter.Attributes.PublishAt
+		if chapter.Attributes.ReadableAt.After(readableAt) {
+			readableAt = chapter.Attributes.ReadableAt
+		}
+		c.ReadableAt = &readableAt
+	}
+	return c
+}
+
+// chapterGaps returns ranges of whole chapter numbers missing between the
+// first chapter and the last one, numbering is expected to start with 1 or 0.
+// Numbers like "10.5" fill no gap.
+func chapterGaps(numbers []string) []chapterGap {
+	wholes := []int{}
+	for _, number := range numbers {
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil || value < 0 {
+			continue
+		}
+		whole := int(math.Floor(value))
+		if !slices.Contains(wholes, whole) {
+			wholes = append(wholes, whole)
+		}
+	}
+	slices.Sort(wholes)
+
+	gaps := []chapterGap{}
+	expected := 1
+	for _, whole := range wholes {
+		if whole > expected {
+			gaps = append(gaps, chapterGap{From: strconv.Itoa(expected), To: strconv.Itoa(whole - 1)})
+		}
+		expected = max(expected, whole+1)
+	}
+	return gaps
+}
+
+func printChapterReport(r chapterReport) {
+	dp.Println(field.Sprint("Title: "), r.Title)
+	dp.Println(field.Sprint("Language: "), r.Language)
+
+	tableData := pterm.TableData{{"Volume", "Chapter", "Title", "Group", "Pages", "Status"}}
+	for _, volume := range r.Volumes {
+		for _, c := range volume.Chapters {
+			tableData = append(tableData, []string{
+				volume.Volume, c.Number, c.Title, c.Group, strconv.Itoa(c.Pages), c.statusText(),
+			})
+		}
+	}
+	if len(tableData) == 1 {
+		pterm.Warning.Printfln("No chapters in language %s", r.Language)
+	} else {
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	}
+
+	if len(r.Gaps) != 0 {
+		gaps := []string{}
+		for _, gap := range r.Gaps {
+			if gap.From == gap.To {
+				gaps = append(gaps, gap.From)
+			} else {
+				gaps = append(gaps, gap.From+"-"+gap.To)
+			}
+		}
+		pterm.Warning.Printfln("Gaps in numbering: %s", strings.Join(gaps, ", "))
+	}
+	if len(r.Other) != 0 {
+		pterm.Warning.Printfln("%d chapters exist only in other languages:", len(r.Other))
+		for _, other := range r.Other {
+			dp.Printfln("  ch. %s: %s", other.Number, strings.Join(other.Languages, ", "))
+		}
+	}
+	if len(r.External) != 0 {
+		pterm.Warning.Printfln("%d chapters are hosted on external sites and have no pages", len(r.External))
+	}
+	if len(r.Scheduled) != 0 {
+		pterm.Warning.Printfln("%d chapters are not published yet", len(r.Scheduled))
+	}
+	if len(r.Gaps)+len(r.Other)+len(r.External)+len(r.Scheduled) == 0 && len(tableData) > 1 {
+		pterm.Success.Println("No gaps, external or scheduled chapters")
+	}
+}
+
+func (c reportChapter) statusText() string {
+	switch c.Status {
+	case chapterStatusExternal:
+		return "external: " + c.ExternalUrl
+	case chapterStatusScheduled:
+		if c.ReadableAt == nil {
+			return "scheduled"
+		}
+		return "scheduled: readable on " + c.ReadableAt.Local().Format("2006-01-02 15:04")
+	}
+	return ""
+}
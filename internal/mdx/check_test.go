@@ -0,0 +1,110 @@
+package mdx
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+func TestChapterGaps(t *testing.T) {
+	tests := []struct {
+		numbers []string
+		want    []chapterGap
+	}{
+		{[]string{}, []chapterGap{}},
+		{[]string{"0", "1", "2", "3"}, []chapterGap{}},
+		{[]string{"1", "2", "2.5", "5", "Extra"}, []chapterGap{{"3", "4"}}},
+		{[]string{"3", "4", "6"}, []chapterGap{{"1", "2"}, {"5", "5"}}},
+	}
+
+	for _, tc := range tests {
+		if got := chapterGaps(tc.numbers); !slices.Equal(got, tc.want) {
+			t.Errorf("Test Case: %v. Expected %v, but got %v", tc.numbers, tc.want, got)
+		}
+	}
+}
+
+func TestNewChapterReport(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	chapter := func(volume, number, language, externalUrl string, publishAt time.Time) mangadexapi.Chapter {
+		return mangadexapi.Chapter{Attributes: mangadexapi.ChapterAttr{
+			Volume:             volume,
+			Chapter:            number,
+			TranslatedLanguage: language,
+			ExternalUrl:        externalUrl,
+			PublishAt:          publishAt,
+		}}
+	}
+	chapters := []mangadexapi.Chapter{
+		chapter("1", "1", "en", "", now.AddDate(0, -1, 0)),
+		chapter("1", "2", "es", "", now.AddDate(0, -1, 0)),
+		chapter("1", "3", "en", "https://mangaplus.shueisha.co.jp", now.AddDate(0, -1, 0)),
+		chapter("", "4", "en", "", now.AddDate(0, 0, 7)),
+	}
+
+	report := newChapterReport(mangadexapi.MangaInfo{}, chapters, "en", now)
+	if len(report.Volumes) != 2 || report.Volumes[0].Volume != "1" || len(report.Volumes[0].Chapters) != 2 {
+		t.Errorf("Test Case: volumes. Expected volume 1 with 2 chapters and no volume, but got %v", report.Volumes)
+	}
+	if !slices.Equal(report.Gaps, []chapterGap{{"2", "2"}}) {
+		t.Errorf("Test Case: gaps. Expected gap 2, but got %v", report.Gaps)
+	}
+	if len(report.Other) != 1 || report.Other[0].Number != "2" || report.Other[0].Languages[0] != "es" {
+		t.Errorf("Test Case: other languages. Expected chapter 2 in es, but got %v", report.Other)
+	}
+	if len(report.External) != 1 || report.External[0].Number != "3" {
+		t.Errorf("Test Case: external. Expected chapter 3, but got %v", report.External)
+	}
+	if len(report.Scheduled) != 1 || report.Scheduled[0].Number != "4" {
+		t.Errorf("Test Case: scheduled. Expected chapter 4, but got %v", report.Scheduled)
+	}
+}
+
+func TestOtherLanguageIds(t *testing.T) {
+	chapters := []mangadexapi.Chapter{
+		{ID: "1en", Attributes: mangadexapi.ChapterAttr{Chapter: "1"}},
+		{ID: "3en", Attributes: mangadexapi.ChapterAttr{Chapter: "3"}},
+	}
+	numbers := []mangadexapi.AggregateChapter{
+		{Chapter: "1", ID: "1en", Others: []string{"1es"}},
+		{Chapter: "2", ID: "2es", Others: []string{"2fr"}},
+		{Chapter: "3", ID: "3en"},
+		{Chapter: "none", ID: "oneshot"},
+	}
+
+	got := otherLanguageIds(chapters, numbers)
+	if want := []string{"2es", "2fr"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+}
+
+func TestScheduledChapter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		attr mangadexapi.ChapterAttr
+		want time.Time
+	}{
+		{"Publish date", mangadexapi.ChapterAttr{PublishAt: now.AddDate(0, 0, 7)}, now.AddDate(0, 0, 7)},
+		{"Readable date without publish date", mangadexapi.ChapterAttr{ReadableAt: now.AddDate(0, 0, 3)},
+			now.AddDate(0, 0, 3)},
+		{"Readable date after publish date", mangadexapi.ChapterAttr{PublishAt: now.AddDate(0, 0, -1),
+			ReadableAt: now.AddDate(0, 0, 3)}, now.AddDate(0, 0, 3)},
+	}
+
+	for _, tt := range tests {
+		c := newReportChapter(mangadexapi.Chapter{Attributes: tt.attr}, now)
+		if c.Status != chapterStatusScheduled || c.ReadableAt == nil || !c.ReadableAt.Equal(tt.want) {
+			t.Errorf("Test Case: %s. Expected scheduled until %v, but got %s %v", tt.name, tt.want, c.Status, c.ReadableAt)
+		}
+		if want := "scheduled: readable on " + tt.want.Local().Format("2006-01-02 15:04"); c.statusText() != want {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", tt.name, want, c.statusText())
+		}
+	}
+
+	if got := (reportChapter{Status: chapterStatusScheduled}).statusText(); got != "scheduled" {
+		t.Errorf("Test Case: no date. Expected %q, but got %q", "scheduled", got)
+	}
+}
@@ -0,0 +1,152 @@
+package mdx
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/pterm/pterm"
+)
+
+var (
+	openContainersMu sync.Mutex
+	// openContainers are aborted before the program exits, so their
+	// temporary files never outlive the process.
+	openContainers = make(map[*trackedContainer]struct{})
+	watchOnce      sync.Once
+)
+
+// trackedContainer unregisters itself once it is written or aborted. Calls
+// are serialized, so an interrupt never aborts a container in the middle of
+// adding a page.
+type trackedContainer struct {
+	filekit.Container
+	mu sync.Mutex
+}
+
+func newContainer(extension string, opts filekit.Options) (*trackedContainer, error) {
+	c, err := filekit.NewContainer(extension, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := &trackedContainer{Container: c}
+	openContainersMu.Lock()
+	openContainers[tc] = struct{}{}
+	openContainersMu.Unlock()
+	return tc, nil
+}
+
+func (c *trackedContainer) release() {
+	openContainersMu.Lock()
+	delete(openContainers, c)
+	openContainersMu.Unlock()
+}
+
+func (c *trackedContainer) BeginChapter(chapter filekit.Chapter) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Container.BeginChapter(chapter)
+}
+
+func (c *trackedContainer) AddFile(fileExt string, imageBytes []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Container.AddFile(fileExt, imageBytes)
+}
+
+func (c *trackedContainer) WriteOnDiskAndClose(outputDir, outputFileName string,
+	m metadata.Metadata, chapterRange string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.release()
+	return c.Container.WriteOnDiskAndClose(outputDir, outputFileName, m, chapterRange)
+}
+
+func (c *trackedContainer) Abort() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.release()
+	return c.Container.Abort()
+}
+
+func abortOpenContainers() {
+	openContainersMu.Lock()
+	containers := make([]*trackedContainer, 0, len(openContainers))
+	for c := range openContainers {
+		containers = append(containers, c)
+	}
+	openContainersMu.Unlock()
+
+	for _, c := range containers {
+		if err := c.Abort(); err != nil {
+			e.Printfln("While removing temporary files: %v", err)
+		}
+	}
+}
+
+// exit aborts unfinished containers, prints collected file results and
+// terminates the program.
+func exit(code int) {
+	abortOpenContainers()
+	flushFileResults()
+	os.Exit(code)
+}
+
+// watchInterrupt aborts unfinished containers when the program is
+// interrupted by a signal.
+func watchInterrupt() {
+	watchOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			dp.Println("")
+			pterm.Warning.Println("Interrupted, removing unfinished files...")
+			exit(130)
+		}()
+	})
+}
+
+// interruptContext returns a context canceled when the program is
+// interrupted by a signal, a second signal terminates the program.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// CleanTemp removes temporary files left by interrupted runs of mdx,
+// including old versions that didn't clean up after themselves.
+func CleanTemp(outputDirs []string, olderThan time.Duration) {
+	spinner, _ := pterm.DefaultSpinner.Start("Removing temporary files...")
+	removed, err := filekit.CleanStale(outputDirs, olderThan)
+	if err != nil {
+		spinner.Fail("Failed to remove temporary files")
+		e.Printfln("While removing temporary files: %v", err)
+		exit(1)
+	}
+
+	if isMachineOutput() {
+		spinner.Success()
+		printResults(append([]string{}, removed...))
+		return
+	}
+	if len(removed) == 0 {
+		spinner.Success("Nothing to remove")
+		return
+	}
+
+	spinner.Success(pterm.Sprintf("Removed %d temporary files and directories", len(removed)))
+	for _, path := range removed {
+		dp.Println(path)
+	}
+}
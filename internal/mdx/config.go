@@ -0,0 +1,89 @@
+package mdx
+
+import (
+	"os"
+	"strings"
+
+	"github.com/arimatakao/mdx/internal/config"
+	"github.com/pterm/pterm"
+)
+
+// configResult is a key of the config file in json and ndjson formats.
+type configResult struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// ConfigPath returns the path of the config file with default flag values.
+func ConfigPath() string {
+	return config.Path()
+}
+
+// LoadConfig reads the config file or exits.
+func LoadConfig() *config.Config {
+	c, err := config.Load(ConfigPath())
+	if err != nil {
+		e.Printfln("While reading config file %s: %v", ConfigPath(), err)
+		os.Exit(1)
+	}
+	return c
+}
+
+// PrintConfigPath prints the path of the config file.
+func PrintConfigPath() {
+	if isMachineOutput() {
+		printResult(struct {
+			Path string `json:"path"`
+		}{ConfigPath()})
+		return
+	}
+	dp.Println(ConfigPath())
+}
+
+// GetConfig prints values of key, a value per line.
+func GetConfig(key string) {
+	values, ok := LoadConfig().Get(key)
+	if !ok {
+		e.Printfln("%s is not set in %s", key, ConfigPath())
+		os.Exit(1)
+	}
+	if isMachineOutput() {
+		printResult(configResult{Key: key, Values: values})
+		return
+	}
+	for _, value := range values {
+		dp.Println(value)
+	}
+}
+
+// SetConfig sets values of key and saves the config file, several values
+// are saved as an array.
+func SetConfig(key string, values []string) {
+	c := LoadConfig()
+	c.Set(key, values)
+	if err := c.Save(ConfigPath()); err != nil {
+		e.Printfln("While saving config file %s: %v", ConfigPath(), err)
+		os.Exit(1)
+	}
+	pterm.Success.Printfln("Set %s to %s", key, strings.Join(values, ", "))
+}
+
+// ListConfig prints keys of the config file with their values.
+func ListConfig() {
+	entries := LoadConfig().Entries()
+	if isMachineOutput() {
+		results := []configResult{}
+		for _, entry := range entries {
+			results = append(results, configResult{Key: entry.Key, Values: entry.Values})
+		}
+		printResults(results)
+		return
+	}
+	if len(entries) == 0 {
+		dp.Println("Config file " + ConfigPath() + " is empty")
+		return
+	}
+	for _, entry := range entries {
+		dp.Println(field.Sprint(entry.Key+" = "), strings.Join(entry.Values, ", "))
+	}
+}
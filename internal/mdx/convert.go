@@ -0,0 +1,173 @@
+package mdx
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arimatakao/mdx/app"
+	"github.com/arimatakao/mdx/downloader"
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/pterm/pterm"
+)
+
+// offlineParam rebuilds downloaded files without the network.
+type offlineParam struct {
+	outputExt     string
+	outputDir     string
+	containerOpts filekit.Options
+	imageOpts     imaging.Options
+	webtoonMode   string
+	// naming names files of split chapters and merged volumes
+	naming downloader.Naming
+}
+
+func NewOfflineParam(outputExt, outputDir string, containerOpts filekit.Options,
+	imageOpts imaging.Options, webtoonMode string, naming downloader.Naming) offlineParam {
+	return offlineParam{
+		outputExt:     outputExt,
+		outputDir:     outputDir,
+		containerOpts: containerOpts,
+		imageOpts:     imageOpts,
+		webtoonMode:   webtoonMode,
+		naming:        naming,
+	}
+}
+
+// RunConvert rebuilds downloaded files in another format. Pages and
+// metadata are read from the files, the network is not used.
+func (p offlineParam) RunConvert(inputPaths []string) {
+	watchInterrupt()
+	beginFileResults()
+	defer flushFileResults()
+
+	for _, inputPath := range inputPaths {
+		book, err := filekit.OpenBook(inputPath)
+		if err != nil {
+			e.Printfln("While reading %s: %v", inputPath, err)
+			exit(1)
+		}
+
+		err = p.convertBook(book, inputPath)
+		book.Close()
+		if errors.Is(err, filekit.ErrOutputSkipped) {
+			pterm.Warning.Printfln("Skipped %s, output already exists", inputPath)
+			continue
+		}
+		if err != nil {
+			e.Printfln("While converting %s: %v", inputPath, err)
+			exit(1)
+		}
+	}
+}
+
+func (p offlineParam) convertBook(book *filekit.Book, inputPath string) error {
+	outputDir := p.outputDir
+	if outputDir == "" {
+		outputDir = filepath.Dir(inputPath)
+	}
+	name := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	if book.Format == filekit.DIR_EXT {
+		name = filepath.Base(inputPath)
+	}
+	if isSamePath(inputPath, filekit.OutputPath(outputDir, name, p.outputExt)) {
+		return errors.New("output is the input file, choose another output directory or format")
+	}
+
+	title := "Converting " + filepath.Base(inputPath)
+	err := p.writeBook(outputDir, name, book.Chapters, book.Metadata, book.ChapterRange(), title)
+	if err != nil {
+		return err
+	}
+	pterm.Success.Printfln("Converted %s", filepath.Base(inputPath))
+	return nil
+}
+
+// writeBook writes pages of chapters into a new file and reports the result.
+// Chapters with a boundary are marked in the file.
+func (p offlineParam) writeBook(outputDir, name string, chapters []filekit.BookChapter,
+	m metadata.Metadata, chapterRange, title string) error {
+	err := p.writeBookPages(outputDir, name, chapters, m, chapterRange, title)
+
+	result := fileResult{
+		Path:       filekit.OutputPath(outputDir, name, p.outputExt),
+		Status:     FILE_STATUS_SAVED,
+		ChapterIDs: m.SourceIDs().Chapters,
+	}
+	if errors.Is(err, filekit.ErrOutputSkipped) {
+		result.Status = FILE_STATUS_SKIPPED
+		result.Reason = "it already exists"
+	} else if err != nil {
+		result.Status = FILE_STATUS_FAILED
+		result.Reason = err.Error()
+	}
+	reportFile(result)
+	return err
+}
+
+func (p offlineParam) writeBookPages(outputDir, name string, chapters []filekit.BookChapter,
+	m metadata.Metadata, chapterRange, title string) error {
+	opts := p.containerOpts
+	opts.WorkDir = outputDir
+	containerFile, err := newContainer(p.outputExt, opts)
+	if err != nil {
+		return err
+	}
+
+	imageOpts := p.imageOpts
+	imageOpts.RightToLeft = m.IsRightToLeft()
+	imageOpts.Webtoon = imaging.IsWebtoon(p.webtoonMode,
+		slices.Contains(m.CBI.ComicBookInfoData.Tags, "Long Strip"))
+
+	pageCount := 0
+	for _, chapter := range chapters {
+		pageCount += len(chapter.Pages)
+	}
+	bar := startProgressbar(pageCount, title)
+	if bar != nil {
+		defer bar.Stop()
+	}
+
+	for _, chapter := range chapters {
+		if chapter.HasBoundary {
+			if err := containerFile.BeginChapter(chapter.Chapter); err != nil {
+				containerFile.Abort()
+				return err
+			}
+		}
+
+		pipeline := downloader.NewPagePipeline(containerFile, imageOpts)
+		for _, page := range chapter.Pages {
+			data, err := page.Read()
+			if err == nil {
+				err = pipeline.Add(page.Ext, data)
+			}
+			if err != nil {
+				pipeline.Wait()
+				containerFile.Abort()
+				return err
+			}
+			if bar != nil {
+				bar.Increment()
+			}
+		}
+		if err := pipeline.Wait(); err != nil {
+			containerFile.Abort()
+			return err
+		}
+	}
+
+	m.CBI.AppID = app.USER_AGENT
+	m.CBI.LastModified = time.Now().UTC().String()
+	return containerFile.WriteOnDiskAndClose(outputDir, name, m, chapterRange)
+}
+
+func isSamePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
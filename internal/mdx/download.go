@@ -4,413 +4,235 @@ import (
 	"errors"
 	"maps"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/arimatakao/mdx/app"
+	"github.com/arimatakao/mdx/downloader"
 	"github.com/arimatakao/mdx/filekit"
-	"github.com/arimatakao/mdx/filekit/metadata"
 	"github.com/arimatakao/mdx/mangadexapi"
 	"github.com/pterm/pterm"
 )
 
-var (
-	ErrEmptyChapters         = errors.New("empty chapters")
-	selectedVolumeChapterMap = make(map[string][]mangadexapi.Chapter)
-)
-
 type dlParam struct {
-	mangaInfo        mangadexapi.MangaInfo
-	chapters         []mangadexapi.ChapterFullInfo
-	lowestChapter    int
-	highestChapter   int
-	lowestVolume     int
-	highestVolume    int
-	chaptersRange    string
-	volumesRange     string
-	language         string
-	translateGroup   string
-	outputDir        string
-	outputExt        string
-	fileNameTemplate string
-	isJpg            bool
-	isMerge          bool
-	isVolume         bool
-	isAll            bool
-	isLast           bool
+	req        downloader.Request
+	isDryRun   bool
+	isPlanJson bool
 }
 
-func NewDownloadParam(chaptersRange, volumesRange string, lowestChapter, highestChapter, lowestVolume, highestVolume int,
-	language, translateGroup, outputDir, outputExt, fileNameTemplate string, isJpg, isMerge, isVolume, isAll, isLast bool) dlParam {
-
+func NewDownloadParam(req downloader.Request, isDryRun, isPlanJson bool) dlParam {
 	return dlParam{
-		mangaInfo:        mangadexapi.MangaInfo{},
-		chapters:         []mangadexapi.ChapterFullInfo{},
-		lowestChapter:    lowestChapter,
-		highestChapter:   highestChapter,
-		lowestVolume:     lowestVolume,
-		highestVolume:    highestVolume,
-		chaptersRange:    chaptersRange,
-		volumesRange:     volumesRange,
-		language:         language,
-		translateGroup:   translateGroup,
-		outputDir:        outputDir,
-		outputExt:        outputExt,
-		fileNameTemplate: fileNameTemplate,
-		isJpg:            isJpg,
-		isMerge:          isMerge,
-		isVolume:         isVolume,
-		isAll:            isAll,
-		isLast:           isLast,
+		req:        req,
+		isDryRun:   isDryRun || isPlanJson,
+		isPlanJson: isPlanJson,
 	}
 }
 
-func (p dlParam) printDlInteractiveParams() {
-	printMangaInfo(p.mangaInfo)
+func (p dlParam) printDlInteractiveParams(manga mangadexapi.MangaInfo, plan downloader.Plan) {
+	printMangaInfo(manga)
 	field.Println("---")
 	dlChapterList, dlVolumeList := "", ""
-	for _, c := range p.chapters {
-		dlChapterList += " " + c.Number()
-		if !strings.Contains(dlVolumeList, c.Volume()) {
-			dlVolumeList += " " + c.Volume()
+	for _, file := range plan.Files {
+		for _, c := range file.Chapters {
+			dlChapterList += " " + c.Number
+			if !strings.Contains(dlVolumeList, c.Volume) {
+				dlVolumeList += " " + c.Volume
+			}
 		}
 	}
 	dp.Println(field.Sprint("Chapters:"), dlChapterList)
 	dp.Println(field.Sprint("Volumes:"), dlVolumeList)
-	dp.Println(field.Sprint("Output directory: "), p.outputDir)
-	dp.Println(field.Sprint("Fileformat: "), p.outputExt)
+	for _, skipped := range plan.Skipped {
+		dp.Println(field.Sprint("Skipped chapter "+skipped.Number+":"), skipped.Reason)
+	}
+	dp.Println(field.Sprint("Output directory: "), p.req.OutputDir)
+	dp.Println(field.Sprint("Fileformat: "), p.req.OutputExt)
 	isMerging := "no"
-	if p.isMerge {
+	if p.req.IsMerge {
 		isMerging = "yes"
 	}
-	if p.isVolume {
+	if p.req.IsVolume {
 		dp.Println(field.Sprint("Merging volumes: "), isMerging)
 	} else {
 		dp.Println(field.Sprint("Merging chapters: "), isMerging)
 	}
 }
 
-func (p dlParam) getMangaInfo(mangaId string) (mangadexapi.MangaInfo, error) {
-	resp, err := client.GetMangaInfo(mangaId)
-	if err != nil {
-		return mangadexapi.MangaInfo{}, err
-	}
-	return resp.MangaInfo(), nil
-}
-
-func (p *dlParam) filterChapters(chapters []mangadexapi.Chapter) []mangadexapi.Chapter {
-	if p.isAll {
-		return chapters
-	}
-
-	if p.isLast {
-		return []mangadexapi.Chapter{chapters[len(chapters)-1]}
+func (p dlParam) RunDownload() {
+	if p.isPlanJson {
+		// keep stdout for the plan
+		pterm.SetDefaultOutput(os.Stderr)
 	}
-
-	var selectedChapters []mangadexapi.Chapter
-
-	if p.isVolume {
-		spinnerVolInfo, _ := pterm.DefaultSpinner.Start("Creating volume and chapter map...")
-		for _, c := range chapters {
-			volumeInt, err := strconv.Atoi(c.Volume())
-			if err != nil {
-				continue
-			}
-			if p.lowestVolume <= volumeInt && volumeInt <= p.highestVolume {
-				selectedChapters = append(selectedChapters, c)
-				selectedVolumeChapterMap[c.Volume()] = append(selectedVolumeChapterMap[c.Volume()], c)
-			}
-		}
-		spinnerVolInfo.Success("Created volume and chapter map")
-		return selectedChapters
+	if !p.isDryRun {
+		beginFileResults()
+		defer flushFileResults()
 	}
+	d := downloader.New(client, nil)
 
-	if p.highestChapter == p.lowestChapter {
-		for _, c := range chapters {
-			chapterNum, err := strconv.Atoi(c.Number())
-			if err != nil {
-				continue
-			}
-
-			if p.highestChapter == chapterNum {
-				selectedChapters = append(selectedChapters, c)
-				break
-			}
-
-		}
-	} else {
-		for _, c := range chapters {
-			chapterNum, err := strconv.Atoi(c.Number())
-			if err != nil {
-				continue
-			}
-
-			if p.lowestChapter <= chapterNum && chapterNum <= p.highestChapter {
-				selectedChapters = append(selectedChapters, c)
-			}
-
-		}
-
-	}
-
-	return selectedChapters
-}
-
-func (p dlParam) RunDownload(mangaId, chapterId string) {
-	// Step 0: If a specific chapter is provided, download it
-	if chapterId != "" {
+	var (
+		manga    mangadexapi.MangaInfo
+		chapters []mangadexapi.Chapter
+	)
+	if p.req.ChapterID != "" {
 		spinnerChapInfo, _ := pterm.DefaultSpinner.Start("Fetching chapter info...")
-		resp, err := client.GetChapterInfo(chapterId)
-		if err != nil {
-			spinnerChapInfo.Fail("Failed to get chapter info")
-			os.Exit(1)
-		}
-		chapterInfo := resp.GetChapterInfo()
-
-		// Populate manga info for filename and metadata when downloading by chapter URL.
-		mangaId := chapterInfo.GetMangaId()
-		if mangaId != "" {
-			mangaResp, err := client.GetMangaInfo(mangaId)
-			if err != nil {
-				spinnerChapInfo.Fail("Failed to get chapter info")
-				e.Printf("While getting manga info for chapter: %v\n", err)
-				os.Exit(1)
-			}
-			p.mangaInfo = mangaResp.MangaInfo()
-		}
-
-		chapterFullInfo, err := client.GetChapterImagesInFullInfo(chapterInfo)
+		mangaInfo, chapter, err := d.FetchChapter(p.req.ChapterID)
 		if err != nil {
 			spinnerChapInfo.Fail("Failed to get chapter info")
-			os.Exit(1)
+			e.Printfln("While getting chapter info: %v", err)
+			exit(1)
 		}
 		spinnerChapInfo.Success("Fetched chapter info")
-		p.chapters = []mangadexapi.ChapterFullInfo{chapterFullInfo}
-		p.flexDownloadChapters()
-		return
-	}
-
-	// Step 1: Fetch manga information
-	spinnerMangaInfo, _ := pterm.DefaultSpinner.Start("Fetching manga info...")
-	mangaInfo, err := p.getMangaInfo(mangaId)
-	if err != nil {
-		spinnerMangaInfo.Fail("Failed to get manga info")
-		os.Exit(1)
-	}
-	p.mangaInfo = mangaInfo
-	spinnerMangaInfo.Success("Fetched manga info")
-
-	// Step 2: Print the fetched manga information
-	printMangaInfo(mangaInfo)
-
-	// Step 3: Fetch all chapters information without images
-	spinnerChapInfo, _ := pterm.DefaultSpinner.Start("Fetching chapters info...")
-	chapters, err := client.GetAllChaptersInfo(mangaId, p.language, p.translateGroup)
-	if err != nil {
-		spinnerChapInfo.Fail("Failed to get chapters info")
-		e.Printf("While getting manga chapters: %v\n", err)
-		os.Exit(1)
-	}
-	spinnerChapInfo.Success("Fetched chapters info")
-
-	// Step 4: Filter the fetched chapters
-	filteredChapters := p.filterChapters(chapters)
-	if len(filteredChapters) == 0 {
-		e.Println("No chapters found after filtering, try another range, language, or translation group.")
-		os.Exit(0)
-	}
-
-	// Step 5: Load image links on pages for each filtered chapter
-	for _, c := range filteredChapters {
-		fullInfo, err := client.GetChapterImagesInFullInfo(c)
-		if err != nil {
-			e.Println("Error while getting images download list")
-			os.Exit(1)
-		}
-		p.chapters = append(p.chapters, fullInfo)
-	}
-
-	// Step 6: Download the chapters
-	p.flexDownloadChapters()
-}
-
-func (p dlParam) flexDownloadChapters() {
-	if p.isVolume && p.isMerge {
-		// Download chapters merged by volumes
-		p.downloadMergeVolumes()
-	} else if p.isMerge {
-		// Merge all chapters into one file
-		p.downloadMergeChapters()
+		manga, chapters = mangaInfo, []mangadexapi.Chapter{chapter}
 	} else {
-		// Download each chapter as a separate file
-		p.downloadChapters()
-	}
-}
-
-func (p dlParam) downloadMergeVolumes() {
-	for volumeId, volume := range selectedVolumeChapterMap {
-		containerFile, err := filekit.NewContainer(p.outputExt)
+		spinnerMangaInfo, _ := pterm.DefaultSpinner.Start("Fetching manga info...")
+		mangaInfo, err := d.FetchManga(p.req.MangaID)
 		if err != nil {
-			e.Printf("While creating output file: %v\n", err)
-			os.Exit(1)
+			spinnerMangaInfo.Fail("Failed to get manga info")
+			exit(1)
 		}
+		spinnerMangaInfo.Success("Fetched manga info")
+		manga = mangaInfo
+		printMangaInfo(manga)
 
-		volumeChaptersRange := []string{}
-		for _, chapter := range volume {
-			for _, chapterFullInfo := range p.chapters {
-				if chapterFullInfo.Info.ID == chapter.ID &&
-					!contains(volumeChaptersRange, chapterFullInfo.Info.Number()) {
-
-					volumeChaptersRange = append(volumeChaptersRange, chapterFullInfo.Info.Number())
-					volumeId = chapterFullInfo.Volume()
-
-					printChapterInfo(chapterFullInfo)
-
-					err = p.downloadProcess(containerFile, chapterFullInfo)
-					if err != nil {
-						e.Printf("While downloading chapter: %v\n", err)
-						os.Exit(1)
-					}
-					break
-				}
-			}
-		}
-		startChapter := minChapter(volumeChaptersRange)
-		endChapter := maxChapter(volumeChaptersRange)
-		chaptersRange := startChapter + "-" + endChapter
-		filename := p.volumeFileName(volumeId, chaptersRange)
-
-		spinnerSave, _ := pterm.DefaultSpinner.Start("Saving file " + filename)
-
-		metaInfo := metadata.NewMetadata(app.USER_AGENT, p.mangaInfo, selectedVolumeChapterMap[volumeId][0])
-		err = containerFile.WriteOnDiskAndClose(p.outputDir, filename, metaInfo, "")
+		spinnerChapInfo, _ := pterm.DefaultSpinner.Start("Fetching chapters info...")
+		chapters, err = d.FetchChapters(p.req)
 		if err != nil {
-
-			spinnerSave.Fail("File not saved")
-			e.Printf("While saving %s on disk: %v\n", filename, err)
-			os.Exit(1)
+			spinnerChapInfo.Fail("Failed to get chapters info")
+			e.Printf("While getting manga chapters: %v\n", err)
+			exit(1)
 		}
-		spinnerSave.Success("Saved " + filename)
-	}
-}
-
-func (p dlParam) downloadMergeChapters() {
-	containerFile, err := filekit.NewContainer(p.outputExt)
-	if err != nil {
-		e.Printf("While creating output file: %v\n", err)
-		os.Exit(1)
-	}
-
-	for _, chapter := range p.chapters {
-		printChapterInfo(chapter)
-
-		err = p.downloadProcess(containerFile, chapter)
-		if err != nil {
-			e.Printf("While downloading chapter: %v\n", err)
-			os.Exit(1)
+		spinnerChapInfo.Success("Fetched chapters info")
+		if len(chapters) == 0 {
+			e.Println("No chapters found after filtering, try another range, language, or translation group.")
+			exit(0)
 		}
 	}
 
-	chaptersRange := p.chapters[0].Info.Number()
-	if len(p.chapters) > 1 {
-		chaptersRange += "-" + p.chapters[len(p.chapters)-1].Number()
-	}
-
-	filename := p.mergeChaptersFileName(chaptersRange)
-
-	spinnerSave, _ := pterm.DefaultSpinner.Start("Saving file " + filename)
-
-	metaInfo := metadata.NewMetadata(app.USER_AGENT, p.mangaInfo, p.chapters[0])
-	err = containerFile.WriteOnDiskAndClose(p.outputDir, filename, metaInfo, p.chaptersRange)
-	if err != nil {
-		spinnerSave.Fail("File not saved")
-		e.Printf("While saving %s on disk: %v\n", filename, err)
-		os.Exit(1)
+	if p.isDryRun {
+		p.printPlan(d.Plan(p.req, manga, chapters))
+		return
 	}
-
-	spinnerSave.Success("Saved " + filename)
+	p.download(manga, chapters)
 }
 
-func (p dlParam) downloadChapters() {
-	for _, chapter := range p.chapters {
-		printChapterInfo(chapter)
-
-		containerFile, err := filekit.NewContainer(p.outputExt)
-		if err != nil {
-			e.Printf("While creating output file: %v\n", err)
-			os.Exit(1)
-		}
-
-		err = p.downloadProcess(containerFile, chapter)
-		if err != nil {
-			e.Printf("While downloading chapter: %v\n", err)
-			os.Exit(1)
-		}
-
-		filename := p.chapterFileName(chapter)
-
-		spinnerSave, _ := pterm.DefaultSpinner.Start("Saving file " + filename)
-
-		metaInfo := metadata.NewMetadata(app.USER_AGENT, p.mangaInfo, chapter)
-		err = containerFile.WriteOnDiskAndClose(p.outputDir, filename, metaInfo, "")
-		if err != nil {
-			spinnerSave.Fail("File not saved")
-			e.Printf("While saving %s on disk: %v\n", filename, err)
-			os.Exit(1)
-		}
-
-		spinnerSave.Success("Saved " + filename)
+// printPlan prints files the download would write instead of downloading
+// them.
+func (p dlParam) printPlan(plan downloader.Plan) {
+	if p.isPlanJson || isMachineOutput() {
+		printResult(plan)
+		return
 	}
+	printDownloadPlan(plan)
 }
 
-func (p dlParam) downloadProcess(outputFile filekit.Container,
-	chapter mangadexapi.ChapterFullInfo) error {
-	if len(p.chapters) == 0 {
-		return ErrEmptyChapters
+// download downloads chapters of the manga and prints the progress. An
+// interrupted download removes its unfinished files before the exit.
+func (p dlParam) download(manga mangadexapi.MangaInfo, chapters []mangadexapi.Chapter) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	printer := &downloadPrinter{language: p.req.Language}
+	d := downloader.New(client, printer.handle)
+	_, err := d.DownloadChapters(ctx, p.req, manga, chapters)
+	switch {
+	case err == nil:
+	case errors.Is(err, downloader.ErrNoReadableChapters):
+		e.Println("None of the chapters can be downloaded from MangaDex.")
+		exit(0)
+	case ctx.Err() != nil:
+		dp.Println("")
+		pterm.Warning.Println("Interrupted, removing unfinished files...")
+		exit(130)
+	default:
+		e.Printfln("While downloading: %v", err)
+		exit(1)
 	}
+}
 
-	if p.language == "ru" {
-		printUaNotification()
-	}
+// downloadPrinter prints events of a download.
+type downloadPrinter struct {
+	language string
+	bar      *pterm.ProgressbarPrinter
+	spinner  *pterm.SpinnerPrinter
+}
 
-	files := chapter.PngFiles
-	imgExt := "png"
-	if p.isJpg {
-		files = chapter.JpgFiles
-		imgExt = "jpg"
+func (pr *downloadPrinter) handle(event downloader.Event) {
+	switch ev := event.(type) {
+	case downloader.ChapterSkipped:
+		pterm.Warning.Printfln("Skipped chapter %s: %s", ev.Chapter.Number(), ev.Reason)
+		if ev.Shortcut != "" {
+			pterm.Info.Printfln("Saved shortcut %s", ev.Shortcut)
+		}
+	case downloader.VolumeSkipped:
+		pterm.Warning.Printfln("Skipped volume %s, none of its chapters can be downloaded", ev.Volume)
+	case downloader.ChapterStarted:
+		printChapterInfo(ev.Chapter)
+		if pr.language == "ru" {
+			printUaNotification()
+		}
+		pr.bar = startProgressbar(ev.Pages, "Downloading pages...")
+	case downloader.PageDownloaded:
+		if pr.bar != nil {
+			pr.bar.Increment()
+		}
+	case downloader.PageUnsupported:
+		dp.Println(ev.File + " media file in chapter is not supported")
+	case downloader.ChapterDownloaded:
+		if pr.bar != nil {
+			pr.bar.Stop()
+		}
+		pr.bar = nil
+		dp.Println("")
+		if ev.DroppedPages > 0 {
+			pterm.Info.Printfln("Dropped %d blocklisted pages", ev.DroppedPages)
+		}
+	case downloader.FileSaving:
+		pr.spinner, _ = pterm.DefaultSpinner.Start("Saving file " + filepath.Base(ev.Path))
+	case downloader.FileDone:
+		pr.printFile(ev.File)
+	case downloader.SeriesFolderReused:
+		dp.Printfln("Series folder %q is used for %q", ev.Folder, ev.Series)
+	case downloader.Warning:
+		pterm.Warning.Println(ev.Err)
 	}
+}
 
-	dlbar, _ := pterm.DefaultProgressbar.WithTotal(len(files)).
-		WithTitle("Downloading pages...").
-		WithBarStyle(pterm.NewStyle(pterm.FgGreen)).Start()
-	defer dlbar.Stop()
-
-	for _, imageFile := range files {
-		outputImage, isRealJpg, err := client.DownloadImage(chapter.DownloadBaseURL,
-			chapter.HashId, imageFile, p.isJpg)
-		if errors.Is(err, mangadexapi.ErrNotImageMedia) {
-			dp.Println(imageFile + " media file in chapter is not supported")
-			continue
-		} else if err != nil {
-			dlbar.WithBarStyle(pterm.NewStyle(pterm.FgRed)).
-				UpdateTitle("Failed downloading").Stop()
-			return err
+func (pr *downloadPrinter) printFile(file downloader.FileResult) {
+	name := filepath.Base(file.Path)
+	switch file.Status {
+	case downloader.FILE_SAVED:
+		pr.spinner.Success("Saved " + name)
+		if len(file.Missing) != 0 {
+			numbers := []string{}
+			for _, missing := range file.Missing {
+				numbers = append(numbers, missing.Chapter.Number())
+			}
+			pterm.Warning.Printfln("%s is saved without chapters %s", name, strings.Join(numbers, ", "))
 		}
-
-		if isRealJpg {
-			imgExt = "jpg"
+	case downloader.FILE_SKIPPED:
+		if pr.spinner != nil {
+			pr.spinner.Warning("Skipped " + name + ", it already exists")
+		} else {
+			pterm.Warning.Printfln("Skipped %s, it already exists", name)
 		}
-
-		if err := outputFile.AddFile(imgExt, outputImage); err != nil {
-			dlbar.WithBarStyle(pterm.NewStyle(pterm.FgRed)).
+	case downloader.FILE_FAILED:
+		if pr.bar != nil {
+			pr.bar.WithBarStyle(pterm.NewStyle(pterm.FgRed)).
 				UpdateTitle("Failed downloading").Stop()
-			return err
 		}
-		dlbar.Increment()
+		if pr.spinner != nil {
+			pr.spinner.Fail("File not saved")
+		}
 	}
-	dp.Println("")
-	return nil
+	pr.bar, pr.spinner = nil, nil
+
+	reportFile(fileResult{
+		Path:       file.Path,
+		Status:     file.Status,
+		ChapterIDs: file.ChapterIDs,
+		Reason:     file.Reason,
+	})
 }
 
 const OPTION_MANGA_TEMPLATE = "%d | %s | %s"                            // number | authors | title
@@ -458,21 +280,30 @@ func getChapterNumsFromOptions(options []string) []string {
 	return i
 }
 
+// savingExtensions are output formats in the interactive saving options.
+var savingExtensions = []string{
+	filekit.CBZ_EXT,
+	filekit.PDF_EXT,
+	filekit.EPUB_EXT,
+	filekit.DIR_EXT,
+	filekit.CBT_EXT,
+	filekit.ZIP_EXT,
+	filekit.KINDLE_EXT,
+	filekit.HTML_EXT,
+}
+
+// toSavingOptions lists every format, then every format with merged
+// chapters. Volumes are always merged, so the second half is skipped.
 func toSavingOptions(isVolume bool) []string {
 	options := []string{}
-	options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 1, filekit.CBZ_EXT))
-	options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 2, filekit.PDF_EXT))
-	options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 3, filekit.EPUB_EXT))
-	options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 4, filekit.DIR_EXT))
+	for i, ext := range savingExtensions {
+		options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, i+1, ext))
+	}
 	if !isVolume {
-		options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 5,
-			filekit.CBZ_EXT+" + merge chapters in one file"))
-		options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 6,
-			filekit.PDF_EXT+" + merge chapters in one file"))
-		options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 7,
-			filekit.EPUB_EXT+" + merge chapters in one file"))
-		options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE, 8,
-			filekit.DIR_EXT+" + merge chapters in one file"))
+		for i, ext := range savingExtensions {
+			options = append(options, pterm.Sprintf(OPTION_SAVING_TEMPLATE,
+				len(savingExtensions)+i+1, ext+" + merge chapters in one file"))
+		}
 	}
 	return options
 }
@@ -488,31 +319,16 @@ func getSavingOption(option string) (string, bool) {
 		return "", false
 	}
 	dp.Println(num)
-	switch num {
-	case 1:
-		return filekit.CBZ_EXT, false
-	case 2:
-		return filekit.PDF_EXT, false
-	case 3:
-		return filekit.EPUB_EXT, false
-	case 4:
-		return filekit.DIR_EXT, false
-	case 5:
-		return filekit.CBZ_EXT, true
-	case 6:
-		return filekit.PDF_EXT, true
-	case 7:
-		return filekit.EPUB_EXT, true
-	case 8:
-		return filekit.DIR_EXT, true
-	default:
+	if num < 1 || num > 2*len(savingExtensions) {
 		return filekit.CBZ_EXT, false
 	}
+	index := (num - 1) % len(savingExtensions)
+	return savingExtensions[index], num > len(savingExtensions)
 }
 
 func (p dlParam) RunInteractiveDownload() {
 	cols, rows := getTerminalSize()
-	p.isVolume = false
+	p.req.IsVolume = false
 
 	foundManga := []string{}
 	associationMangaIdNums := make(map[string]string)
@@ -527,7 +343,7 @@ func (p dlParam) RunInteractiveDownload() {
 			mangaList, err := client.Find(searchTitle, 50, offset, true)
 			if err != nil {
 				e.Printfln("%v", err)
-				os.Exit(1)
+				exit(1)
 			}
 
 			if len(mangaList.Data) == 0 {
@@ -560,7 +376,7 @@ func (p dlParam) RunInteractiveDownload() {
 		respMangaInfo, err := client.GetMangaInfo(mangaId)
 		if err != nil {
 			e.Printfln("%v", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		printMangaInfo(respMangaInfo.Data)
@@ -570,21 +386,20 @@ func (p dlParam) RunInteractiveDownload() {
 			mangaInfo = respMangaInfo.Data
 		}
 	}
-	p.mangaInfo = mangaInfo
 
 	clearOutput()
 	translatedLanguage, _ := pterm.DefaultInteractiveSelect.
 		WithOptions(mangaInfo.TranslatedLanguages()).WithFilter(false).
 		WithMaxHeight(rows - 2).Show("Select language")
-	p.language = translatedLanguage
+	p.req.Language = translatedLanguage
 
 	foundChapters := []mangadexapi.Chapter{}
 	for offset := 0; ; offset += 50 {
 		clearOutput()
-		chapterlist, err := client.GetChaptersList(96, offset, mangaInfo.ID, p.language)
+		chapterlist, err := client.GetChaptersList(96, offset, mangaInfo.ID, p.req.Language)
 		if err != nil {
 			e.Printfln("%v", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		if len(chapterlist.Data) == 0 {
@@ -603,11 +418,10 @@ func (p dlParam) RunInteractiveDownload() {
 		WithOptions([]string{"Download by Volume", "Download by Chapter"}).
 		WithMaxHeight(rows - 2).Show("Select download option")
 
-	selectedChapterNums := []string{}
-	associationChapterIdNums := make(map[string]string)
+	selectedChapters := []mangadexapi.Chapter{}
 
 	if downloadOption == "Download by Volume" {
-		p.isVolume = true
+		p.req.IsVolume = true
 		volumeChapterMap := make(map[string][]mangadexapi.Chapter)
 		for _, chapter := range foundChapters {
 			volume := chapter.Volume()
@@ -635,7 +449,7 @@ func (p dlParam) RunInteractiveDownload() {
 					endChapter := chapters[len(chapters)-1].Number()
 					option := pterm.Sprintf(
 						"%s | Volume %s | Chapters %s-%s",
-						p.mangaInfo.Title("en"), volume, startChapter, endChapter,
+						mangaInfo.Title("en"), volume, startChapter, endChapter,
 					)
 					printVolumeOptions = append(printVolumeOptions, option)
 				}
@@ -655,41 +469,22 @@ func (p dlParam) RunInteractiveDownload() {
 
 			isSelected, _ = pterm.DefaultInteractiveConfirm.Show("Is correct volumes?")
 			if isSelected {
-				selectedVolumeNumbers := []int{}
-				// Build the actual "UI index -> chapter ID" map (just like we do for chapters)
-				// We'll keep track of a "virtual" selection index (i) for each chapter found inside each volume.
-				i := 1
-				selectedVolumeChapterMap = make(map[string][]mangadexapi.Chapter)
 				for _, selectedVolume := range selectedVolumes {
 					// Extract volume number from "xxx | Volume NN |..."
 					volumeStr := strings.TrimSpace(strings.Split(selectedVolume, "|")[1][7:])
-					volumeNum, _ := strconv.Atoi(volumeStr)
-					selectedVolumeNumbers = append(selectedVolumeNumbers, volumeNum)
-					selectedVolumeChapterMap[volumeStr] = volumeChapterMap[volumeStr]
-					// For each chapter in that volume
-					for _, ch := range volumeChapterMap[volumeStr] {
-						// We store "i -> chapter.ID" into associationChapterIdNums
-						idx := strconv.Itoa(i)
-						associationChapterIdNums[idx] = ch.ID
-						selectedChapterNums = append(selectedChapterNums, idx)
-						i++
-					}
+					selectedChapters = append(selectedChapters, volumeChapterMap[volumeStr]...)
 				}
-				sort.Ints(selectedVolumeNumbers)
-				p.lowestVolume = selectedVolumeNumbers[0]
-				p.highestVolume = selectedVolumeNumbers[len(selectedVolumeNumbers)-1]
-				p.volumesRange = strconv.Itoa(p.lowestVolume) + "-" + strconv.Itoa(p.highestVolume)
 			}
 		}
 	} else {
 		for isSelected := false; !isSelected; {
 			clearOutput()
 			printChapterOptions, associationIdNums := toChaptersOptions(foundChapters, cols)
-			selectedChapters, _ := pterm.DefaultInteractiveMultiselect.
+			selectedOptions, _ := pterm.DefaultInteractiveMultiselect.
 				WithOptions(printChapterOptions).
 				WithMaxHeight(rows - 3).Show("Select chapters from list")
 
-			if len(selectedChapters) == 0 {
+			if len(selectedOptions) == 0 {
 				isContinue, _ := pterm.DefaultInteractiveConfirm.
 					Show("Chapters not selected, try again?")
 				if !isContinue {
@@ -699,49 +494,30 @@ func (p dlParam) RunInteractiveDownload() {
 
 			isSelected, _ = pterm.DefaultInteractiveConfirm.Show("Is correct chapters?")
 			if isSelected {
-				selectedChapterNums = append(selectedChapterNums, getChapterNumsFromOptions(selectedChapters)...)
-				maps.Copy(associationChapterIdNums, associationIdNums)
+				for _, num := range getChapterNumsFromOptions(selectedOptions) {
+					for _, chapter := range foundChapters {
+						if chapter.ID == associationIdNums[num] {
+							selectedChapters = append(selectedChapters, chapter)
+						}
+					}
+				}
 			}
 		}
 	}
 	clearOutput()
 
 	savingOption, _ := pterm.DefaultInteractiveSelect.
-		WithOptions(toSavingOptions(p.isVolume)).
+		WithOptions(toSavingOptions(p.req.IsVolume)).
 		WithMaxHeight(rows - 2).
 		Show("Select saving options")
 
 	outputExt, isMerge := getSavingOption(savingOption)
-	p.outputExt = outputExt
-	p.isMerge = isMerge
-	if p.isVolume {
-		p.isMerge = true
+	p.req.OutputExt = outputExt
+	p.req.IsMerge = isMerge
+	if p.req.IsVolume {
+		p.req.IsMerge = true
 	}
 
-	clearOutput()
-	chaptersFullInfo := []mangadexapi.ChapterFullInfo{}
-	for _, num := range selectedChapterNums {
-		chapterFullInfo := mangadexapi.ChapterFullInfo{}
-		for _, chapter := range foundChapters {
-			if chapter.ID == associationChapterIdNums[num] {
-				chapterFullInfo.Info = chapter
-			}
-		}
-
-		imageInfo, err := client.GetChapterImageList(associationChapterIdNums[num])
-		if err != nil {
-			e.Printf("%v", err)
-			os.Exit(1)
-		}
-		chapterFullInfo.DownloadBaseURL = imageInfo.BaseURL
-		chapterFullInfo.HashId = imageInfo.ChapterMetaInfo.Hash
-		chapterFullInfo.PngFiles = imageInfo.ChapterMetaInfo.Data
-		chapterFullInfo.JpgFiles = imageInfo.ChapterMetaInfo.DataSaver
-
-		chaptersFullInfo = append(chaptersFullInfo, chapterFullInfo)
-	}
-	p.chapters = chaptersFullInfo
-
 	clearOutput()
 	outputDir, _ := pterm.DefaultInteractiveTextInput.
 		WithTextStyle(field).
@@ -750,10 +526,16 @@ func (p dlParam) RunInteractiveDownload() {
 	if outputDir == "" {
 		outputDir = "."
 	}
-	p.outputDir = outputDir
+	p.req.OutputDir = outputDir
+
+	plan := downloader.New(client, nil).Plan(p.req, mangaInfo, selectedChapters)
+	if len(plan.Files) == 0 {
+		e.Println("None of the chapters can be downloaded from MangaDex.")
+		return
+	}
 
 	clearOutput()
-	p.printDlInteractiveParams()
+	p.printDlInteractiveParams(mangaInfo, plan)
 	isCorrectDlParams, _ := pterm.DefaultInteractiveConfirm.
 		Show("Is correct downloading parameters?")
 	if !isCorrectDlParams {
@@ -761,47 +543,11 @@ func (p dlParam) RunInteractiveDownload() {
 		return
 	}
 
-	field.Println("Downloading selections...")
-	if p.isMerge && p.isVolume {
-		p.downloadMergeVolumes()
-	} else if p.isMerge {
-		p.downloadMergeChapters()
-	} else {
-		p.downloadChapters()
-	}
-}
-
-func maxChapter(chapters []string) string {
-	if len(chapters) == 0 {
-		return ""
-	}
-	max := chapters[0]
-	for _, ch := range chapters {
-		if ch > max {
-			max = ch
-		}
-	}
-	return max
-}
-
-func minChapter(chapters []string) string {
-	if len(chapters) == 0 {
-		return ""
-	}
-	min := chapters[0]
-	for _, ch := range chapters {
-		if ch < min {
-			min = ch
-		}
+	if p.isDryRun {
+		p.printPlan(plan)
+		return
 	}
-	return min
-}
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
-	}
-	return false
+	field.Println("Downloading selections...")
+	p.download(mangaInfo, selectedChapters)
 }
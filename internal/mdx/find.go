@@ -1,10 +1,12 @@
 package mdx
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"time"
 
+	"github.com/arimatakao/mdx/downloader"
 	"github.com/pterm/pterm"
 )
 
@@ -26,72 +28,70 @@ func NewFindParams(title string, isDoujinshiAllow bool, outputToFile bool) findP
 	}
 }
 
+// Find prints manga found by the title, with outputToFile all results are
+// saved to a JSON file. Searching is done by downloader.Find.
 func (p findParams) Find() {
 	spinner, _ := pterm.DefaultSpinner.Start("Searching manga...")
-	response, err := client.Find(p.title, p.printedCount, p.offset, p.isDoujinshiAllow)
+	found, total, err := downloader.Find(context.Background(), client, downloader.FindRequest{
+		Title:            p.title,
+		Limit:            p.printedCount,
+		Offset:           p.offset,
+		IsAll:            p.outputToFile,
+		IsDoujinshiAllow: p.isDoujinshiAllow,
+	})
 	if err != nil {
 		spinner.Fail("Failed to search manga")
-		e.Printf("error while search manga: %v\n", err)
-		os.Exit(1)
+		e.Printfln("While searching manga: %v", err)
+		exit(1)
 	}
 
-	if response.Total == 0 {
+	if total == 0 {
 		spinner.Warning("Nothing found...")
-		os.Exit(0)
+		if isMachineOutput() {
+			printResults([]mangaResult{})
+		}
+		exit(0)
 	}
 	spinner.Success("Manga found!")
 
-	// If output file is specified, fetch all results and save to JSON
-	if p.outputToFile {
-		// If there are more results, fetch them all
-		allResults := response
-		currentOffset := p.printedCount
-
-		for currentOffset < response.Total {
-			spinner, _ := pterm.DefaultSpinner.Start(
-				pterm.Sprintf("Fetching more results (%d/%d)...",
-					currentOffset, response.Total))
-			moreResults, err := client.Find(p.title,
-				p.printedCount, currentOffset, p.isDoujinshiAllow)
-			if err != nil {
-				spinner.Fail("Failed to fetch additional results")
-				e.Printfln("error while fetching additional results: %v", err)
-				os.Exit(1)
-			}
-			allResults.Data = append(allResults.Data, moreResults.Data...)
-			currentOffset += p.printedCount
+	if isMachineOutput() && !p.outputToFile {
+		results := []mangaResult{}
+		for _, m := range found {
+			results = append(results, newMangaResult(m))
 		}
+		printResults(results)
+		return
+	}
 
-		jsonData, err := json.MarshalIndent(allResults.Data, "", "    ")
+	if p.outputToFile {
+		jsonData, err := json.MarshalIndent(found, "", "    ")
 		if err != nil {
-			e.Printf("error while marshaling JSON: %v\n", err)
-			os.Exit(1)
+			e.Printfln("While encoding results: %v", err)
+			exit(1)
 		}
 		timeStamp := time.Now().Format("01_02_2006")
 		fileName := pterm.Sprintf("Search-Results_%s.json", timeStamp)
 
 		err = os.WriteFile(fileName, jsonData, 0644)
 		if err != nil {
-			e.Printf("error while writing JSON file: %v\n", err)
-			os.Exit(1)
+			e.Printfln("While writing %s: %v", fileName, err)
+			exit(1)
 		}
 
-		spinner.Success(pterm.Sprintfln("All %d results saved to %s",
-			response.Total, fileName))
-
+		pterm.Success.Printfln("All %d results saved to %s", total, fileName)
 		return
 	}
 
-	for _, m := range response.List() {
+	for _, m := range found {
 		dp.Println("------------------------------")
 		printMangaInfo(m)
 	}
 
-	if response.Total > p.printedCount {
+	if total > p.printedCount {
 		dp.Println("==============================")
 		field.Printf("Full results: ")
 		dp.Printfln(" https://mangadex.org/search?q=%s", p.title)
 		field.Print("Total found: ")
-		dp.Println(response.Total)
+		dp.Println(total)
 	}
 }
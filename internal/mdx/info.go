@@ -1,43 +1,66 @@
 package mdx
 
 import (
-	"os"
-
+	"github.com/arimatakao/mdx/downloader"
 	"github.com/arimatakao/mdx/mangadexapi"
 	"github.com/pterm/pterm"
 )
 
 type infoParams struct {
-	mangaId  string
-	isRandom bool
+	mangaId    string
+	isRandom   bool
+	isChapters bool
+	language   string
 }
 
-func NewInfoParams(mangaId string, isRandom bool) infoParams {
+func NewInfoParams(mangaId string, isRandom, isChapters bool, language string) infoParams {
 	return infoParams{
-		mangaId:  mangaId,
-		isRandom: isRandom,
+		mangaId:    mangaId,
+		isRandom:   isRandom,
+		isChapters: isChapters,
+		language:   language,
 	}
 }
 
+// GetInfo prints information about the manga, fetched by downloader.GetInfo.
 func (p infoParams) GetInfo() {
 	spinner, _ := pterm.DefaultSpinner.Start("Fetching info...")
 
 	var (
-		resp mangadexapi.MangaInfoResponse
-		err  error
+		manga mangadexapi.MangaInfo
+		err   error
 	)
-
 	if p.isRandom {
-		resp, err = client.GetRandomMangaInfo()
+		manga, err = downloader.GetRandomInfo(client)
 	} else {
-		resp, err = client.GetMangaInfo(p.mangaId)
+		manga, err = downloader.GetInfo(client, p.mangaId)
 	}
-
 	if err != nil {
 		spinner.Fail("Failed to fetch manga info")
-		e.Printfln("While getting manga information: %v\n", err)
-		os.Exit(1)
+		e.Printfln("While getting manga information: %v", err)
+		exit(1)
+	}
+	result := infoResult{mangaResult: newMangaResult(manga)}
+	if p.isChapters {
+		report := NewCheckParam(manga.ID, p.language).fetchReport(manga)
+		result.Chapters = &report
 	}
 	spinner.Success("Fetched info")
-	printMangaInfo(resp.MangaInfo())
+
+	if isMachineOutput() {
+		printResult(result)
+		return
+	}
+	printMangaInfo(manga)
+	if result.Chapters != nil {
+		dp.Println()
+		printChapterReport(*result.Chapters)
+	}
+}
+
+// infoResult is the result of info in json and ndjson formats.
+type infoResult struct {
+	mangaResult
+	// Chapters is set with --chapters.
+	Chapters *chapterReport `json:"chapters,omitempty"`
 }
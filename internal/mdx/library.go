@@ -0,0 +1,516 @@
+package mdx
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/arimatakao/mdx/mangadexapi"
+	"github.com/pterm/pterm"
+)
+
+const libraryFileName = "library.json"
+
+// libraryExtensions are extensions of files indexed by library scan, page
+// directories are indexed too.
+var libraryExtensions = []string{filekit.CBZ_EXT, filekit.ZIP_EXT, filekit.CBT_EXT,
+	filekit.EPUB_EXT, filekit.PDF_EXT}
+
+// LibraryPath returns the path of the library index, it is kept in the user
+// config directory.
+func LibraryPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "mdx", libraryFileName)
+}
+
+// libraryIndex is the local index of downloaded files built from metadata
+// stored in them.
+type libraryIndex struct {
+	Files []libraryFile `json:"files"`
+}
+
+type libraryFile struct {
+	// Path is absolute.
+	Path     string `json:"path"`
+	Format   string `json:"format"`
+	Series   string `json:"series"`
+	MangaID  string `json:"mangaId,omitempty"`
+	Language string `json:"language,omitempty"`
+	// Chapters are chapters of merged files or the chapter of the file.
+	// Merged pdf files have a chapter with a range, e.g. "1-5".
+	Chapters   []libraryChapter `json:"chapters"`
+	ChapterIDs []string         `json:"chapterIds,omitempty"`
+	Size       int64            `json:"size"`
+}
+
+type libraryChapter struct {
+	Number string `json:"number,omitempty"`
+	Volume string `json:"volume,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Group  string `json:"group,omitempty"`
+}
+
+// seriesKey groups files of the same manga, files without IDs are grouped
+// by the series title.
+func (f libraryFile) seriesKey() string {
+	if f.MangaID != "" {
+		return f.MangaID
+	}
+	return strings.ToLower(f.Series)
+}
+
+// loadLibrary reads the library index or exits, a missing index is empty.
+func loadLibrary() libraryIndex {
+	index := libraryIndex{}
+	content, err := os.ReadFile(LibraryPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return index
+	}
+	if err == nil {
+		err = json.Unmarshal(content, &index)
+	}
+	if err != nil {
+		e.Printfln("While reading library index %s: %v", LibraryPath(), err)
+		exit(1)
+	}
+	return index
+}
+
+// save replaces the library index, an interrupted save keeps the old one.
+func (l libraryIndex) save() {
+	err := filekit.WriteFileAtomic(LibraryPath(), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(l)
+	})
+	if err != nil {
+		e.Printfln("While saving library index %s: %v", LibraryPath(), err)
+		exit(1)
+	}
+}
+
+// loadNonEmptyLibrary reads the library index or exits if nothing is
+// indexed yet.
+func loadNonEmptyLibrary() libraryIndex {
+	index := loadLibrary()
+	if len(index.Files) == 0 {
+		pterm.Info.Println("Library index is empty, index downloaded files with mdx library scan <root>")
+		if isMachineOutput() {
+			printResults([]any{})
+		}
+		exit(0)
+	}
+	return index
+}
+
+// RunLibraryScan indexes files and page directories under roots. Earlier
+// entries of the roots are replaced, entries of other roots are kept.
+func RunLibraryScan(roots []string) {
+	index := loadLibrary()
+
+	// scanResult is the result of scan in json and ndjson formats.
+	type scanResult struct {
+		Root  string `json:"root"`
+		Files int    `json:"files"`
+	}
+	results := []scanResult{}
+
+	for _, root := range roots {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			e.Printfln("While reading %s: %v", root, err)
+			exit(1)
+		}
+
+		spinner, _ := pterm.DefaultSpinner.Start("Scanning " + root)
+		files, err := scanLibrary(root)
+		if err != nil {
+			spinner.Fail("Failed to scan " + root)
+			e.Printfln("While reading %s: %v", root, err)
+			exit(1)
+		}
+
+		index.Files = slices.DeleteFunc(index.Files, func(f libraryFile) bool {
+			return f.Path == root || strings.HasPrefix(f.Path, root+string(filepath.Separator))
+		})
+		index.Files = append(index.Files, files...)
+		spinner.Success(pterm.Sprintf("Indexed %d files in %s", len(files), root))
+		results = append(results, scanResult{Root: root, Files: len(files)})
+	}
+
+	slices.SortFunc(index.Files, func(a, b libraryFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	index.save()
+
+	if isMachineOutput() {
+		printResults(results)
+	}
+}
+
+func scanLibrary(root string) ([]libraryFile, error) {
+	files := []libraryFile{}
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// hidden entries are temporary files of unfinished downloads
+		if filePath != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))
+		isBookDir := d.IsDir() && filekit.IsBookDir(filePath)
+		if !isBookDir && (d.IsDir() || !slices.Contains(libraryExtensions, ext)) {
+			return nil
+		}
+
+		file, err := indexFile(filePath)
+		if err != nil {
+			pterm.Warning.Printfln("Skipped %s: %v", filePath, err)
+		} else {
+			files = append(files, file)
+		}
+		if isBookDir {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return files, err
+}
+
+// indexFile reads metadata of a file or a page directory.
+func indexFile(filePath string) (libraryFile, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return libraryFile{}, err
+	}
+
+	var (
+		m        metadata.Metadata
+		chapters []filekit.BookChapter
+		format   string
+	)
+	if strings.EqualFold(filepath.Ext(filePath), "."+filekit.PDF_EXT) {
+		tagged, err := filekit.OpenTaggedFile(filePath)
+		if err != nil {
+			return libraryFile{}, err
+		}
+		m, format = tagged.Metadata, tagged.Format
+	} else {
+		book, err := filekit.OpenBook(filePath)
+		if err != nil {
+			return libraryFile{}, err
+		}
+		book.Close()
+		m, chapters, format = book.Metadata, book.Chapters, book.Format
+	}
+
+	ids := m.SourceIDs()
+	file := libraryFile{
+		Path:       filePath,
+		Format:     format,
+		Series:     m.CI.Series,
+		MangaID:    ids.Manga,
+		Language:   m.CI.LanguageISO,
+		Chapters:   []libraryChapter{},
+		ChapterIDs: ids.Chapters,
+	}
+	if !info.IsDir() {
+		file.Size = info.Size()
+	}
+	if file.Series == "" {
+		file.Series = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	}
+
+	for _, chapter := range chapters {
+		if chapter.HasBoundary {
+			file.Chapters = append(file.Chapters, libraryChapter{
+				Number: chapter.Chapter.Number,
+				Volume: chapter.Chapter.Volume,
+				Title:  chapter.Chapter.Title,
+				Group:  chapter.Chapter.Group,
+			})
+		}
+	}
+	if len(file.Chapters) == 0 && (m.CI.Number != "" || m.CI.Volume != "") {
+		file.Chapters = append(file.Chapters, libraryChapter{
+			Number: m.CI.Number,
+			Volume: m.CI.Volume,
+			Title:  m.CI.Title,
+			Group:  m.CI.Translator,
+		})
+	}
+	return file, nil
+}
+
+// librarySeries is an indexed series in one language.
+type librarySeries struct {
+	Series   string   `json:"series"`
+	MangaID  string   `json:"mangaId,omitempty"`
+	Language string   `json:"language,omitempty"`
+	Volumes  []string `json:"volumes"`
+	Chapters []string `json:"chapters"`
+	Groups   []string `json:"groups"`
+	Files    int      `json:"files"`
+}
+
+// RunLibraryList prints indexed series with their volumes, chapters and
+// groups.
+func RunLibraryList() {
+	index := loadNonEmptyLibrary()
+
+	entries := map[string]*librarySeries{}
+	keys := []string{}
+	for _, file := range index.Files {
+		key := file.seriesKey() + "|" + file.Language
+		entry, ok := entries[key]
+		if !ok {
+			entry = &librarySeries{
+				Series:   file.Series,
+				MangaID:  file.MangaID,
+				Language: file.Language,
+				Volumes:  []string{},
+				Chapters: []string{},
+				Groups:   []string{},
+			}
+			entries[key] = entry
+			keys = append(keys, key)
+		}
+		entry.Files++
+		for _, chapter := range file.Chapters {
+			entry.Volumes = appendUnique(entry.Volumes, chapter.Volume)
+			entry.Chapters = appendUnique(entry.Chapters, chapter.Number)
+			entry.Groups = appendUnique(entry.Groups, chapter.Group)
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return strings.Compare(strings.ToLower(entries[a].Series), strings.ToLower(entries[b].Series))
+	})
+
+	if isMachineOutput() {
+		results := []librarySeries{}
+		for _, key := range keys {
+			entry := entries[key]
+			slices.SortFunc(entry.Volumes, compareNumbers)
+			slices.SortFunc(entry.Chapters, compareNumbers)
+			results = append(results, *entry)
+		}
+		printResults(results)
+		return
+	}
+
+	tableData := pterm.TableData{{"Series", "Language", "Volumes", "Chapters", "Groups", "Files"}}
+	for _, key := range keys {
+		entry := entries[key]
+		tableData = append(tableData, []string{
+			entry.Series,
+			entry.Language,
+			numbersSummary(entry.Volumes),
+			numbersSummary(entry.Chapters),
+			strings.Join(entry.Groups, ", "),
+			strconv.Itoa(entry.Files),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// RunLibraryMissing prints chapters of the manga on MangaDex in language
+// that are not in the library. Chapters are matched by IDs and numbers.
+func RunLibraryMissing(mangaId, language string) {
+	index := loadNonEmptyLibrary()
+
+	spinner, _ := pterm.DefaultSpinner.Start("Fetching chapters...")
+	mangaResp, err := client.GetMangaInfo(mangaId)
+	if err != nil {
+		spinner.Fail("Failed to fetch manga info")
+		e.Printfln("While getting manga information: %v", err)
+		exit(1)
+	}
+	manga := mangaResp.MangaInfo()
+	chapters, err := client.GetAllChaptersInfo(mangaId, language, "")
+	if err != nil {
+		spinner.Fail("Failed to fetch chapters")
+		e.Printfln("While getting chapters: %v", err)
+		exit(1)
+	}
+	spinner.Success("Fetched chapters")
+
+	missing := index.missing(mangaId, manga.Title("en"), language, chapters)
+
+	if isMachineOutput() {
+		results := []reportChapter{}
+		for _, chapter := range missing {
+			results = append(results, newReportChapter(chapter, time.Now()))
+		}
+		printResults(results)
+		return
+	}
+
+	if len(missing) == 0 {
+		pterm.Success.Printfln("All %d chapters of %s are in the library", len(chapters), manga.Title("en"))
+		return
+	}
+
+	tableData := pterm.TableData{{"Volume", "Chapter", "Title", "Group"}}
+	for _, chapter := range missing {
+		tableData = append(tableData, []string{
+			chapter.Volume(), chapter.Number(), chapter.Title(), chapter.Translator(),
+		})
+	}
+	pterm.Info.Printfln("%d of %d chapters of %s are missing", len(missing), len(chapters),
+		manga.Title("en"))
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+// RunLibraryDuplicates prints chapters stored in more than one file, e.g.
+// a chapter file next to a merged volume or copies from different groups.
+func RunLibraryDuplicates() {
+	index := loadNonEmptyLibrary()
+
+	type chapterLabel struct {
+		series   string
+		language string
+		chapter  string
+	}
+	paths := map[string][]string{}
+	labels := map[string]chapterLabel{}
+	keys := []string{}
+	add := func(key string, label chapterLabel, path string) {
+		if _, ok := paths[key]; !ok {
+			keys = append(keys, key)
+			labels[key] = label
+		}
+		paths[key] = appendUnique(paths[key], path)
+	}
+	for _, file := range index.Files {
+		for _, chapter := range file.Chapters {
+			if chapter.Number == "" {
+				continue
+			}
+			key := file.seriesKey() + "|" + file.Language + "|" + chapter.Number
+			add(key, chapterLabel{file.Series, file.Language, chapter.Number}, file.Path)
+		}
+	}
+
+	// duplicateResult is a duplicate chapter in json and ndjson formats.
+	type duplicateResult struct {
+		Series   string   `json:"series"`
+		Language string   `json:"language,omitempty"`
+		Chapter  string   `json:"chapter"`
+		Paths    []string `json:"paths"`
+	}
+	results := []duplicateResult{}
+	for _, key := range keys {
+		if len(paths[key]) < 2 {
+			continue
+		}
+		results = append(results, duplicateResult{
+			Series:   labels[key].series,
+			Language: labels[key].language,
+			Chapter:  labels[key].chapter,
+			Paths:    paths[key],
+		})
+	}
+
+	if isMachineOutput() {
+		printResults(results)
+		return
+	}
+	for _, duplicate := range results {
+		dp.Printfln("%s [%s] ch. %s", duplicate.Series, duplicate.Language, duplicate.Chapter)
+		for _, path := range duplicate.Paths {
+			dp.Println("  " + path)
+		}
+	}
+	if len(results) == 0 {
+		pterm.Success.Println("No duplicate chapters in the library")
+	}
+}
+
+// missing returns chapters of the manga in language not found in the
+// index. Files without IDs are matched by the series title.
+func (l libraryIndex) missing(mangaId, title, language string,
+	chapters []mangadexapi.Chapter) []mangadexapi.Chapter {
+	localIDs := []string{}
+	localNumbers := []string{}
+	for _, file := range l.Files {
+		isSameManga := file.MangaID == mangaId ||
+			(file.MangaID == "" && strings.EqualFold(file.Series, title))
+		if !isSameManga || (file.Language != "" && file.Language != language) {
+			continue
+		}
+		localIDs = append(localIDs, file.ChapterIDs...)
+		for _, chapter := range file.Chapters {
+			localNumbers = append(localNumbers, chapter.Number)
+		}
+	}
+
+	missing := []mangadexapi.Chapter{}
+	for _, chapter := range chapters {
+		if !slices.Contains(localIDs, chapter.ID) && !isNumberCovered(chapter.Number(), localNumbers) {
+			missing = append(missing, chapter)
+		}
+	}
+	return missing
+}
+
+func appendUnique(values []string, value string) []string {
+	if value == "" || slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// numbersSummary returns the lowest and the highest of chapter or volume
+// numbers with their count, e.g. "1-12 (10)".
+func numbersSummary(numbers []string) string {
+	if len(numbers) == 0 {
+		return ""
+	}
+	sorted := slices.Clone(numbers)
+	slices.SortFunc(sorted, compareNumbers)
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	return pterm.Sprintf("%s-%s (%d)", sorted[0], sorted[len(sorted)-1], len(sorted))
+}
+
+// isNumberCovered reports whether chapter number is one of numbers or inside
+// a range of them, e.g. "3" is inside "1-5".
+func isNumberCovered(number string, numbers []string) bool {
+	if number == "" {
+		return false
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	for _, n := range numbers {
+		if n == number {
+			return true
+		}
+		low, high, ok := strings.Cut(n, "-")
+		if !ok || err != nil {
+			continue
+		}
+		lowValue, errLow := strconv.ParseFloat(low, 64)
+		highValue, errHigh := strconv.ParseFloat(high, 64)
+		if errLow == nil && errHigh == nil && lowValue <= value && value <= highValue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package mdx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+func TestIsNumberCovered(t *testing.T) {
+	numbers := []string{"1", "2.5", "4-6", "Extra"}
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"1", true},
+		{"2.5", true},
+		{"5", true},
+		{"6", true},
+		{"3", false},
+		{"7", false},
+		{"Extra", true},
+		{"", false},
+	}
+
+	for _, tc := range tests {
+		if got := isNumberCovered(tc.number, numbers); got != tc.want {
+			t.Errorf("Test Case: %q. Expected %v, but got %v", tc.number, tc.want, got)
+		}
+	}
+}
+
+func TestNumbersSummary(t *testing.T) {
+	tests := []struct {
+		numbers []string
+		want    string
+	}{
+		{[]string{}, ""},
+		{[]string{"3"}, "3"},
+		{[]string{"10", "2", "1"}, "1-10 (3)"},
+	}
+
+	for _, tc := range tests {
+		if got := numbersSummary(tc.numbers); got != tc.want {
+			t.Errorf("Test Case: %v. Expected %q, but got %q", tc.numbers, tc.want, got)
+		}
+	}
+}
+
+func TestLibraryIndexSave(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	if got := loadLibrary(); len(got.Files) != 0 {
+		t.Errorf("Expected an empty index without a file, but got %v", got.Files)
+	}
+
+	index := libraryIndex{Files: []libraryFile{{
+		Path:       "/manga/Series v01.cbz",
+		Format:     "cbz",
+		Series:     "Series",
+		MangaID:    "m1",
+		Language:   "en",
+		Chapters:   []libraryChapter{{Number: "1", Volume: "1"}, {Number: "2", Volume: "1", Group: "G"}},
+		ChapterIDs: []string{"c1", "c2"},
+		Size:       42,
+	}}}
+	index.save()
+	// the second save replaces the index
+	index.save()
+
+	got := loadLibrary()
+	if !reflect.DeepEqual(got, index) {
+		t.Errorf("Expected the saved index %v, but got %v", index, got)
+	}
+	entries, err := os.ReadDir(filepath.Dir(LibraryPath()))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected only %s in the config directory, but got %v (%v)", libraryFileName, entries, err)
+	}
+}
+
+func TestLibraryIndexMissing(t *testing.T) {
+	chapter := func(id, number string) mangadexapi.Chapter {
+		return mangadexapi.Chapter{ID: id, Attributes: mangadexapi.ChapterAttr{Chapter: number}}
+	}
+	feed := []mangadexapi.Chapter{
+		chapter("c1", "1"), chapter("c2", "2"), chapter("c3", "3"),
+		chapter("c4", "4"), chapter("c5", "5"), chapter("c6", "6"),
+	}
+	index := libraryIndex{Files: []libraryFile{
+		// matched by IDs, numbers changed on MangaDex
+		{MangaID: "m1", Language: "en", ChapterIDs: []string{"c1"}, Chapters: []libraryChapter{{Number: "0"}}},
+		// merged pdf with a range and no IDs, matched by the title
+		{Series: "series", Chapters: []libraryChapter{{Number: "2-3"}}},
+		// other language and other manga
+		{MangaID: "m1", Language: "de", ChapterIDs: []string{"c4"}, Chapters: []libraryChapter{{Number: "4"}}},
+		{MangaID: "m2", Language: "en", ChapterIDs: []string{"c5"}, Chapters: []libraryChapter{{Number: "5"}}},
+	}}
+
+	missing := index.missing("m1", "Series", "en", feed)
+	ids := []string{}
+	for _, c := range missing {
+		ids = append(ids, c.ID)
+	}
+	if want := []string{"c4", "c5", "c6"}; !slices.Equal(ids, want) {
+		t.Errorf("Expected missing chapters %v, but got %v", want, ids)
+	}
+}
@@ -0,0 +1,294 @@
+package mdx
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/pterm/pterm"
+)
+
+// openedBook is a book with the path it was read from.
+type openedBook struct {
+	path string
+	*filekit.Book
+}
+
+func openBooks(inputPaths []string) []openedBook {
+	books := []openedBook{}
+	for _, inputPath := range inputPaths {
+		book, err := filekit.OpenBook(inputPath)
+		if err != nil {
+			closeBooks(books)
+			e.Printfln("While reading %s: %v", inputPath, err)
+			exit(1)
+		}
+		books = append(books, openedBook{path: inputPath, Book: book})
+	}
+	return books
+}
+
+func closeBooks(books []openedBook) {
+	for _, book := range books {
+		book.Close()
+	}
+}
+
+// isMerged reports whether the book has chapters marked inside.
+func (b openedBook) isMerged() bool {
+	return slices.ContainsFunc(b.Chapters, func(c filekit.BookChapter) bool {
+		return c.HasBoundary
+	})
+}
+
+// mergedChapters returns chapters of the book for a merged file, a book of
+// one chapter becomes a chapter described by its metadata.
+func (b openedBook) mergedChapters() []filekit.BookChapter {
+	if b.isMerged() {
+		return b.Chapters
+	}
+
+	ci := b.Metadata.CI
+	chapter := filekit.BookChapter{
+		Chapter: filekit.Chapter{
+			Number: ci.Number,
+			Title:  ci.Title,
+			Volume: ci.Volume,
+			Group:  ci.Translator,
+		},
+		HasBoundary: true,
+	}
+	for _, c := range b.Chapters {
+		chapter.Pages = append(chapter.Pages, c.Pages...)
+	}
+	return []filekit.BookChapter{chapter}
+}
+
+// RunMerge merges files into one file at outputPath in the given order.
+func (p offlineParam) RunMerge(inputPaths []string, outputPath string) {
+	watchInterrupt()
+	beginFileResults()
+	defer flushFileResults()
+
+	for _, inputPath := range inputPaths {
+		if isSamePath(inputPath, outputPath) {
+			e.Printfln("Output %s is one of the merged files", outputPath)
+			exit(1)
+		}
+	}
+
+	books := openBooks(inputPaths)
+	defer closeBooks(books)
+
+	name := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath))
+	p.mergeBooks(books, filepath.Dir(outputPath), name)
+}
+
+// RunMergeByVolume merges chapter files of dir into a file per volume. The
+// volume and the chapter number are read from metadata of the files.
+func (p offlineParam) RunMergeByVolume(dir string) {
+	watchInterrupt()
+	beginFileResults()
+	defer flushFileResults()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		e.Printfln("While reading %s: %v", dir, err)
+		exit(1)
+	}
+
+	// books are opened again per volume, so only one volume is open at once
+	type chapterFile struct {
+		path   string
+		number string
+	}
+	volumes := map[string][]chapterFile{}
+	for _, entry := range entries {
+		inputPath := filepath.Join(dir, entry.Name())
+		book, err := filekit.OpenBook(inputPath)
+		if errors.Is(err, filekit.ErrBookNotSupported) {
+			continue
+		}
+		if err != nil {
+			e.Printfln("While reading %s: %v", inputPath, err)
+			exit(1)
+		}
+		isMerged := openedBook{path: inputPath, Book: book}.isMerged()
+		ci := book.Metadata.CI
+		book.Close()
+
+		switch {
+		case isMerged:
+			pterm.Warning.Printfln("Skipped %s, it has merged chapters", entry.Name())
+		case ci.Volume == "":
+			pterm.Warning.Printfln("Skipped %s, it has no volume", entry.Name())
+		default:
+			volumes[ci.Volume] = append(volumes[ci.Volume], chapterFile{path: inputPath, number: ci.Number})
+		}
+	}
+
+	if len(volumes) == 0 {
+		e.Printfln("No chapter files with volumes in %s", dir)
+		exit(0)
+	}
+
+	outputDir := p.outputDir
+	if outputDir == "" {
+		outputDir = dir
+	}
+	volumeNames := make([]string, 0, len(volumes))
+	for volume := range volumes {
+		volumeNames = append(volumeNames, volume)
+	}
+	slices.SortFunc(volumeNames, compareNumbers)
+
+	for _, volume := range volumeNames {
+		files := volumes[volume]
+		slices.SortFunc(files, func(a, b chapterFile) int {
+			return compareNumbers(a.number, b.number)
+		})
+		paths := make([]string, 0, len(files))
+		for _, file := range files {
+			paths = append(paths, file.path)
+		}
+
+		books := openBooks(paths)
+		volumeDir, name := p.naming.VolumeFile(outputDir, books[0].Metadata, mergedChapterRange(books))
+		p.mergeBooks(books, volumeDir, name)
+		closeBooks(books)
+	}
+}
+
+func (p offlineParam) mergeBooks(books []openedBook, outputDir, name string) {
+	chapters := []filekit.BookChapter{}
+	for _, book := range books {
+		chapters = append(chapters, book.mergedChapters()...)
+	}
+
+	// like merged downloads, metadata is taken from the first chapter
+	m := books[0].Metadata
+	m.SetSourceIDs(mergedSourceIDs(books))
+	err := p.writeBook(outputDir, name, chapters, m, mergedChapterRange(books), "Merging "+name)
+	if errors.Is(err, filekit.ErrOutputSkipped) {
+		pterm.Warning.Printfln("Skipped %s, it already exists", name)
+		return
+	}
+	if err != nil {
+		e.Printfln("While merging %s: %v", name, err)
+		exit(1)
+	}
+	pterm.Success.Printfln("Merged %d chapters into %s", len(chapters), name)
+}
+
+// mergedSourceIDs returns MangaDex IDs of all chapters of books, books of
+// another manga are left out.
+func mergedSourceIDs(books []openedBook) metadata.SourceIDs {
+	ids := books[0].Metadata.SourceIDs()
+	ids.Chapters = nil
+	for _, book := range books {
+		bookIDs := book.Metadata.SourceIDs()
+		if bookIDs.Manga == ids.Manga {
+			ids.Chapters = append(ids.Chapters, bookIDs.Chapters...)
+		}
+	}
+	return ids
+}
+
+// mergedChapterRange returns the range of chapter numbers, e.g. "1-5".
+func mergedChapterRange(books []openedBook) string {
+	numbers := []string{}
+	for _, book := range books {
+		for _, chapter := range book.mergedChapters() {
+			if chapter.Chapter.Number != "" {
+				numbers = append(numbers, chapter.Chapter.Number)
+			}
+		}
+	}
+	if len(numbers) == 0 {
+		return ""
+	}
+	if len(numbers) == 1 {
+		return numbers[0]
+	}
+	return numbers[0] + "-" + numbers[len(numbers)-1]
+}
+
+// RunSplit breaks merged files into a file per chapter. Chapters are found
+// by chapter folders, ComicInfo bookmarks or the EPUB table of contents.
+func (p offlineParam) RunSplit(inputPaths []string) {
+	watchInterrupt()
+	beginFileResults()
+	defer flushFileResults()
+
+	books := openBooks(inputPaths)
+	defer closeBooks(books)
+
+	for _, book := range books {
+		if !book.isMerged() {
+			e.Printfln("%s has no recorded chapters, only merged files can be split", book.path)
+			exit(1)
+		}
+
+		outputDir := p.outputDir
+		if outputDir == "" {
+			outputDir = filepath.Dir(book.path)
+		}
+		ids := book.Metadata.SourceIDs()
+		for i, chapter := range book.Chapters {
+			m := chapterMetadata(book.Metadata, chapter.Chapter)
+			// IDs of merged files are recorded in chapter order
+			chapterIDs := metadata.SourceIDs{Manga: ids.Manga}
+			if len(ids.Chapters) == len(book.Chapters) {
+				chapterIDs.Chapters = ids.Chapters[i : i+1]
+			}
+			m.SetSourceIDs(chapterIDs)
+			chapterDir, name := p.naming.ChapterFile(outputDir, m)
+
+			chapter.HasBoundary = false
+			err := p.writeBook(chapterDir, name, []filekit.BookChapter{chapter}, m, "", "Splitting "+name)
+			if errors.Is(err, filekit.ErrOutputSkipped) {
+				pterm.Warning.Printfln("Skipped %s, it already exists", name)
+				continue
+			}
+			if err != nil {
+				e.Printfln("While splitting %s: %v", book.path, err)
+				exit(1)
+			}
+			pterm.Success.Printfln("Saved %s", name)
+		}
+	}
+}
+
+// chapterMetadata returns metadata of a merged file for one of its chapters.
+func chapterMetadata(m metadata.Metadata, chapter filekit.Chapter) metadata.Metadata {
+	m.CI.Number = chapter.Number
+	m.CI.Volume = chapter.Volume
+	m.CI.Title = chapter.Title
+	if chapter.Group != "" {
+		m.CI.Translator = chapter.Group
+	}
+	m.CI.Pages = nil
+
+	info := &m.CBI.ComicBookInfoData
+	info.Issue = chapter.Number
+	info.Volume = chapter.Volume
+	info.Title = chapter.Title
+	return m
+}
+
+// compareNumbers compares chapter or volume numbers like "2" and "10.5",
+// other values are compared as text.
+func compareNumbers(a, b string) int {
+	numA, errA := strconv.ParseFloat(a, 64)
+	numB, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return cmp.Compare(numA, numB)
+	}
+	return strings.Compare(a, b)
+}
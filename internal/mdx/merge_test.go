@@ -0,0 +1,138 @@
+package mdx
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/arimatakao/mdx/downloader"
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/imaging"
+	"github.com/arimatakao/mdx/filekit/metadata"
+)
+
+// writeChapterFile writes a cbz chapter file of two pages into dir.
+func writeChapterFile(t *testing.T, dir, volume, number, chapterID string) string {
+	t.Helper()
+	page := new(bytes.Buffer)
+	if err := png.Encode(page, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+
+	m := metadata.Metadata{CI: metadata.ComicInfoMetadata{
+		Series: "Series", Volume: volume, Number: number, LanguageISO: "en",
+	}}
+	m.SetSourceIDs(metadata.SourceIDs{Manga: "m1", Chapters: []string{chapterID}})
+
+	c, err := filekit.NewContainer(filekit.CBZ_EXT, filekit.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := c.AddFile("png", page.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	name := "ch" + number
+	if err := c.WriteOnDiskAndClose(dir, name, m, ""); err != nil {
+		t.Fatal(err)
+	}
+	return filekit.OutputPath(dir, name, filekit.CBZ_EXT)
+}
+
+func openTestBook(t *testing.T, path string) *filekit.Book {
+	t.Helper()
+	book, err := filekit.OpenBook(path)
+	if err != nil {
+		t.Fatalf("Expected %s to be written: %v", path, err)
+	}
+	t.Cleanup(func() { book.Close() })
+	return book
+}
+
+func TestMergeAndSplit(t *testing.T) {
+	dir := t.TempDir()
+	ch1 := writeChapterFile(t, dir, "1", "1", "c1")
+	ch2 := writeChapterFile(t, dir, "1", "2", "c2")
+	writeChapterFile(t, dir, "2", "10", "c10")
+
+	params := NewOfflineParam(filekit.CBZ_EXT, "", filekit.Options{}, imaging.Options{}, imaging.WEBTOON_OFF,
+		downloader.Naming{})
+
+	// chapters are merged in the given order with their IDs
+	mergedPath := filepath.Join(t.TempDir(), "merged.cbz")
+	params.RunMerge([]string{ch1, ch2}, mergedPath)
+	merged := openTestBook(t, mergedPath)
+	if len(merged.Chapters) != 2 || merged.ChapterRange() != "1-2" {
+		t.Errorf("Expected 2 merged chapters 1-2, but got %d chapters %q", len(merged.Chapters), merged.ChapterRange())
+	}
+	if ids := merged.Metadata.SourceIDs(); !slices.Equal(ids.Chapters, []string{"c1", "c2"}) {
+		t.Errorf("Expected chapter IDs of merged files, but got %v", ids.Chapters)
+	}
+
+	// split chapters are named by the layout
+	splitDir := t.TempDir()
+	layout, err := downloader.ParseLayout(downloader.LAYOUT_KOMGA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.outputDir = splitDir
+	params.naming = downloader.Naming{Layout: layout}
+	params.RunSplit([]string{mergedPath})
+	for i, name := range []string{"Series v01 c001", "Series v01 c002"} {
+		book := openTestBook(t, filekit.OutputPath(filepath.Join(splitDir, "Series"), name, filekit.CBZ_EXT))
+		if ids := book.Metadata.SourceIDs(); len(ids.Chapters) != 1 || ids.Chapters[0] != []string{"c1", "c2"}[i] {
+			t.Errorf("Expected the chapter ID of %s, but got %v", name, ids.Chapters)
+		}
+		if book.PageCount() != 2 {
+			t.Errorf("Expected 2 pages in %s, but got %d", name, book.PageCount())
+		}
+	}
+
+	// volumes are merged in chapter order, 10 after 2
+	volumeDir := t.TempDir()
+	params.outputDir = volumeDir
+	params.naming = downloader.Naming{FileNameTemplate: "%3 v%4 c%5"}
+	params.RunMergeByVolume(dir)
+	for name, expectedRange := range map[string]string{"Series v1 c1-2": "1-2", "Series v2 c10": ""} {
+		book := openTestBook(t, filekit.OutputPath(volumeDir, name, filekit.CBZ_EXT))
+		if book.ChapterRange() != expectedRange {
+			t.Errorf("Expected chapters %s in %s, but got %q", expectedRange, name, book.ChapterRange())
+		}
+	}
+}
+
+func TestMergedSourceIDs(t *testing.T) {
+	newBook := func(mangaID string, chapterIDs ...string) openedBook {
+		m := metadata.Metadata{}
+		m.SetSourceIDs(metadata.SourceIDs{Manga: mangaID, Chapters: chapterIDs})
+		return openedBook{Book: &filekit.Book{Metadata: m}}
+	}
+
+	ids := mergedSourceIDs([]openedBook{newBook("m1", "c1"), newBook("m2", "x1"), newBook("m1", "c2", "c3")})
+	if ids.Manga != "m1" || !slices.Equal(ids.Chapters, []string{"c1", "c2", "c3"}) {
+		t.Errorf("Expected IDs of the first manga, but got %v", ids)
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2", "10", -1},
+		{"10.5", "10", 1},
+		{"1", "1.0", 0},
+		{"Extra", "1", 1},
+		{"A", "B", -1},
+	}
+
+	for _, tc := range testCases {
+		if got := compareNumbers(tc.a, tc.b); got != tc.expected {
+			t.Errorf("Test Case: %s vs %s. Expected %d, but got %d", tc.a, tc.b, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package mdx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/arimatakao/mdx/downloader"
+	"github.com/arimatakao/mdx/mangadexapi"
+	"github.com/pterm/pterm"
+)
+
+// Output formats of command results, see "Machine-readable output" in
+// README.md for the schemas of json and ndjson results.
+const (
+	// OUTPUT_FORMAT_TABLE prints styled text and tables.
+	OUTPUT_FORMAT_TABLE = "table"
+	// OUTPUT_FORMAT_PLAIN prints text without colour and animations.
+	OUTPUT_FORMAT_PLAIN = "plain"
+	// OUTPUT_FORMAT_JSON prints the result of a command as one JSON document.
+	OUTPUT_FORMAT_JSON = "json"
+	// OUTPUT_FORMAT_NDJSON prints a JSON document per line, lists are
+	// printed an element per line as soon as elements are known.
+	OUTPUT_FORMAT_NDJSON = "ndjson"
+)
+
+var outputFormat = OUTPUT_FORMAT_TABLE
+
+// resultOutput is where results of commands are printed.
+var resultOutput io.Writer = os.Stdout
+
+// OutputFormats returns supported output formats.
+func OutputFormats() []string {
+	return []string{OUTPUT_FORMAT_TABLE, OUTPUT_FORMAT_PLAIN, OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_NDJSON}
+}
+
+func IsNotSupportedOutputFormat(format string) bool {
+	return !slices.Contains(OutputFormats(), format)
+}
+
+// SetOutputFormat switches how results of commands are printed. Formats
+// other than table have no colour and progress bars. In json and ndjson
+// formats stdout only has results, messages go to stderr and spinners are off.
+func SetOutputFormat(format string) {
+	outputFormat = format
+	if format == OUTPUT_FORMAT_TABLE {
+		return
+	}
+
+	pterm.DisableStyling()
+	if isMachineOutput() {
+		pterm.SetDefaultOutput(os.Stderr)
+		pterm.DefaultSpinner.Writer = io.Discard
+	}
+}
+
+// startProgressbar starts a progress bar of total steps in the table format
+// and returns nil in other formats, pterm progress bars hide and show the
+// cursor on stdout even if they print nothing.
+func startProgressbar(total int, title string) *pterm.ProgressbarPrinter {
+	if outputFormat != OUTPUT_FORMAT_TABLE {
+		return nil
+	}
+	bar, _ := pterm.DefaultProgressbar.WithTotal(total).
+		WithTitle(title).
+		WithBarStyle(pterm.NewStyle(pterm.FgGreen)).Start()
+	return bar
+}
+
+// isMachineOutput reports whether results are printed as JSON.
+func isMachineOutput() bool {
+	return outputFormat == OUTPUT_FORMAT_JSON || outputFormat == OUTPUT_FORMAT_NDJSON
+}
+
+// printResult prints the result of a command to stdout, indented in json and
+// on one line in ndjson.
+func printResult(v any) {
+	var (
+		content []byte
+		err     error
+	)
+	if outputFormat == OUTPUT_FORMAT_NDJSON {
+		content, err = json.Marshal(v)
+	} else {
+		content, err = json.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		e.Printfln("While encoding output: %v", err)
+		exit(1)
+	}
+	fmt.Fprintln(resultOutput, string(content))
+}
+
+// printResults prints a list of results, a JSON array in json and an element
+// per line in ndjson.
+func printResults[T any](results []T) {
+	if outputFormat != OUTPUT_FORMAT_NDJSON {
+		printResult(results)
+		return
+	}
+	for _, result := range results {
+		printResult(result)
+	}
+}
+
+// File statuses of fileResult.
+const (
+	FILE_STATUS_SAVED       = downloader.FILE_SAVED
+	FILE_STATUS_SKIPPED     = downloader.FILE_SKIPPED
+	FILE_STATUS_FAILED      = downloader.FILE_FAILED
+	FILE_STATUS_UNAVAILABLE = downloader.FILE_UNAVAILABLE
+	// FILE_STATUS_UNCHANGED is a file retag has nothing to update in.
+	FILE_STATUS_UNCHANGED = "unchanged"
+	// FILE_STATUS_CHANGED is a file retag would update, it is only used with
+	// --dry-run.
+	FILE_STATUS_CHANGED = "changed"
+)
+
+// fileResult is the result of writing or retagging one file.
+type fileResult struct {
+	// Path is the target path, the rename policy for existing files may
+	// save the file under another name.
+	Path       string   `json:"path"`
+	Status     string   `json:"status"`
+	ChapterIDs []string `json:"chapterIds"`
+	// Reason is why the file is skipped or failed.
+	Reason string `json:"reason,omitempty"`
+	// Diff is the change of metadata of retagged files.
+	Diff []string `json:"diff,omitempty"`
+}
+
+// fileResults are collected for the json format, they are printed as one
+// array once the command ends. It is nil if the command writes no files.
+var fileResults []fileResult
+
+// beginFileResults starts collecting results of written files.
+func beginFileResults() {
+	fileResults = []fileResult{}
+}
+
+// reportFile prints the result in ndjson or keeps it for the json format.
+func reportFile(r fileResult) {
+	if r.ChapterIDs == nil {
+		r.ChapterIDs = []string{}
+	}
+	switch outputFormat {
+	case OUTPUT_FORMAT_NDJSON:
+		printResult(r)
+	case OUTPUT_FORMAT_JSON:
+		fileResults = append(fileResults, r)
+	}
+}
+
+// flushFileResults prints results collected for the json format. It runs on
+// exit too, so failed commands still print the results.
+func flushFileResults() {
+	if outputFormat == OUTPUT_FORMAT_JSON && fileResults != nil {
+		printResult(fileResults)
+	}
+	fileResults = nil
+}
+
+// mangaResult is manga information in json and ndjson formats.
+type mangaResult struct {
+	ID                  string     `json:"id"`
+	Link                string     `json:"link"`
+	Title               string     `json:"title"`
+	AltTitles           []altTitle `json:"altTitles"`
+	Authors             []string   `json:"authors"`
+	Artists             []string   `json:"artists"`
+	Year                int        `json:"year,omitempty"`
+	Status              string     `json:"status"`
+	ContentRating       string     `json:"contentRating"`
+	OriginalLanguage    string     `json:"originalLanguage"`
+	TranslatedLanguages []string   `json:"translatedLanguages"`
+	Tags                []string   `json:"tags"`
+	Description         string     `json:"description"`
+	Links               []string   `json:"links"`
+}
+
+// altTitle is an alternative title of manga in its language.
+type altTitle struct {
+	Language string `json:"language"`
+	Title    string `json:"title"`
+}
+
+func newMangaResult(m mangadexapi.MangaInfo) mangaResult {
+	altTitles := []altTitle{}
+	for _, titles := range m.Attributes.AltTitles {
+		for language, title := range titles {
+			altTitles = append(altTitles, altTitle{Language: language, Title: title})
+		}
+	}
+	// titles of one language keep the order of MangaDex
+	slices.SortStableFunc(altTitles, func(a, b altTitle) int {
+		return strings.Compare(a.Language, b.Language)
+	})
+	links := m.LinksArr()
+	slices.Sort(links)
+	translated := m.TranslatedLanguages()
+	if translated == nil {
+		translated = []string{}
+	}
+
+	return mangaResult{
+		ID:                  m.ID,
+		Link:                m.Link(),
+		Title:               m.Title("en"),
+		AltTitles:           altTitles,
+		Authors:             m.AuthorsArr(),
+		Artists:             m.ArtistsArr(),
+		Year:                m.Year(),
+		Status:              m.Status(),
+		ContentRating:       m.ContentRating(),
+		OriginalLanguage:    m.OriginalLanguage(),
+		TranslatedLanguages: translated,
+		Tags:                m.TagsArr(),
+		Description:         m.Description("en"),
+		Links:               links,
+	}
+}
@@ -0,0 +1,119 @@
+package mdx
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/arimatakao/mdx/mangadexapi"
+)
+
+func TestReportFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   int
+	}{
+		{"Table format", OUTPUT_FORMAT_TABLE, 0},
+		{"Json format", OUTPUT_FORMAT_JSON, 2},
+	}
+
+	defer func() { outputFormat = OUTPUT_FORMAT_TABLE }()
+	for _, tt := range tests {
+		outputFormat = tt.format
+		beginFileResults()
+		reportFile(fileResult{Path: "ch1.cbz", Status: FILE_STATUS_SAVED})
+		reportFile(fileResult{Path: "ch2.cbz", Status: FILE_STATUS_SKIPPED, ChapterIDs: []string{"c2"}})
+
+		if len(fileResults) != tt.want {
+			t.Errorf("Test Case: %s. Expected %d results, but got %d", tt.name, tt.want, len(fileResults))
+			continue
+		}
+		if tt.want != 0 && fileResults[0].ChapterIDs == nil {
+			t.Errorf("Test Case: %s. Expected empty chapter IDs, but got nil", tt.name)
+		}
+		fileResults = nil
+	}
+}
+
+func TestPrintResults(t *testing.T) {
+	type result struct {
+		ID string `json:"id"`
+	}
+	tests := []struct {
+		name    string
+		format  string
+		results []result
+		want    string
+	}{
+		{"Json list", OUTPUT_FORMAT_JSON, []result{{"a"}, {"b"}},
+			"[\n  {\n    \"id\": \"a\"\n  },\n  {\n    \"id\": \"b\"\n  }\n]\n"},
+		{"Json empty list", OUTPUT_FORMAT_JSON, []result{}, "[]\n"},
+		{"Ndjson list", OUTPUT_FORMAT_NDJSON, []result{{"a"}, {"b"}}, "{\"id\":\"a\"}\n{\"id\":\"b\"}\n"},
+		{"Ndjson empty list", OUTPUT_FORMAT_NDJSON, []result{}, ""},
+	}
+
+	defer func() { outputFormat, resultOutput = OUTPUT_FORMAT_TABLE, os.Stdout }()
+	for _, tt := range tests {
+		out := new(bytes.Buffer)
+		outputFormat, resultOutput = tt.format, out
+		printResults(tt.results)
+		if out.String() != tt.want {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", tt.name, tt.want, out.String())
+		}
+	}
+
+	// a single result is one document in both formats
+	for _, format := range []string{OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_NDJSON} {
+		out := new(bytes.Buffer)
+		outputFormat, resultOutput = format, out
+		printResult(result{"a"})
+		got := result{}
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil || got.ID != "a" {
+			t.Errorf("Test Case: %s result. Expected one document, but got %q (%v)", format, out.String(), err)
+		}
+	}
+}
+
+func TestNewMangaResult(t *testing.T) {
+	content, err := json.Marshal(newMangaResult(mangadexapi.MangaInfo{ID: "m1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	// lists are empty arrays instead of null and year is left out when unknown
+	want := []string{"altTitles", "artists", "authors", "contentRating", "description", "id", "link",
+		"links", "originalLanguage", "status", "tags", "title", "translatedLanguages"}
+	if got := slices.Sorted(maps.Keys(fields)); !slices.Equal(got, want) {
+		t.Errorf("Expected fields %v, but got %v", want, got)
+	}
+	for _, field := range []string{"altTitles", "artists", "authors", "links", "tags", "translatedLanguages"} {
+		if _, ok := fields[field].([]any); !ok {
+			t.Errorf("Test Case: %s. Expected an array, but got %v", field, fields[field])
+		}
+	}
+	if fields["id"] != "m1" {
+		t.Errorf("Expected id m1, but got %v", fields["id"])
+	}
+}
+
+func TestMangaResultAltTitles(t *testing.T) {
+	m := mangadexapi.MangaInfo{Attributes: mangadexapi.MangaAttrib{AltTitles: []map[string]string{
+		{"ja-ro": "Chensō Man"},
+		{"ja": "チェンソーマン"},
+		{"en": "Chainsaw Man"},
+		{"ja-ro": "Chainsaw"},
+	}}}
+
+	want := []altTitle{{"en", "Chainsaw Man"}, {"ja", "チェンソーマン"}, {"ja-ro", "Chensō Man"}, {"ja-ro", "Chainsaw"}}
+	if got := newMangaResult(m).AltTitles; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+}
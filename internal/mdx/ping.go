@@ -3,6 +3,13 @@ package mdx
 func Ping() {
 	isAlive := client.Ping()
 
+	if isMachineOutput() {
+		printResult(struct {
+			IsAlive bool `json:"alive"`
+		}{isAlive})
+		return
+	}
+
 	if isAlive {
 		dp.Println("MangaDex API is alive")
 	} else {
@@ -0,0 +1,51 @@
+package mdx
+
+import (
+	"strconv"
+
+	"github.com/arimatakao/mdx/downloader"
+	"github.com/pterm/pterm"
+)
+
+func printDownloadPlan(plan downloader.Plan) {
+	tableData := pterm.TableData{{"File", "Volume", "Chapter", "Group", "Pages", "Size"}}
+	for _, file := range plan.Files {
+		path := file.Path
+		if file.Exists {
+			path += " (exists)"
+		}
+		for i, chapter := range file.Chapters {
+			size := ""
+			if i == 0 {
+				size = "~" + formatSize(file.EstimatedSize)
+			} else {
+				path = ""
+			}
+			tableData = append(tableData, []string{
+				path, chapter.Volume, chapter.Number, chapter.Group, strconv.Itoa(chapter.Pages), size,
+			})
+		}
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	for _, skipped := range plan.Skipped {
+		pterm.Warning.Printfln("Chapter %s will be skipped: %s", skipped.Number, skipped.Reason)
+	}
+	pterm.Info.Printfln("%d files, %d pages, about %s", len(plan.Files), plan.Pages,
+		formatSize(plan.EstimatedSize))
+}
+
+// formatSize returns size in bytes as a short text, e.g. "12.5 MB".
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return pterm.Sprintf("%d B", size)
+	}
+	return pterm.Sprintf("%.1f %s", value, units[unit])
+}
@@ -0,0 +1,22 @@
+package mdx
+
+import (
+	"testing"
+)
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{512, "512 B"},
+		{1536, "1.5 KB"},
+		{25 * 1024 * 1024, "25.0 MB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSize(tt.size); got != tt.want {
+			t.Errorf("Test Case: %d. Expected %q, but got %q", tt.size, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package mdx
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arimatakao/mdx/app"
+	"github.com/arimatakao/mdx/filekit"
+	"github.com/arimatakao/mdx/filekit/metadata"
+	"github.com/arimatakao/mdx/mangadexapi"
+	"github.com/pterm/pterm"
+)
+
+// retagExtensions are extensions of files searched in directories to retag.
+var retagExtensions = []string{filekit.CBZ_EXT, filekit.CBT_EXT, filekit.EPUB_EXT, filekit.PDF_EXT}
+
+type retagParam struct {
+	isDryRun bool
+	mangas   map[string]mangadexapi.MangaInfo
+}
+
+func NewRetagParam(isDryRun bool) retagParam {
+	return retagParam{
+		isDryRun: isDryRun,
+		mangas:   map[string]mangadexapi.MangaInfo{},
+	}
+}
+
+// RunRetag rewrites metadata of downloaded files with current information
+// from MangaDex. Files are found by the manga and chapter IDs recorded in
+// them, directories are searched recursively. Pages are not changed.
+// Directory outputs store no metadata and can't be retagged.
+func (p retagParam) RunRetag(paths []string) {
+	beginFileResults()
+	defer flushFileResults()
+
+	files := findRetagFiles(paths)
+	if len(files) == 0 {
+		e.Printfln("No files to retag")
+		exit(0)
+	}
+
+	for _, filePath := range files {
+		result := fileResult{Path: filePath}
+		f, err := filekit.OpenTaggedFile(filePath)
+		if errors.Is(err, filekit.ErrBookNotSupported) {
+			pterm.Warning.Printfln("Skipped %s: %v", filePath, err)
+			result.Status, result.Reason = FILE_STATUS_SKIPPED, err.Error()
+			reportFile(result)
+			continue
+		}
+		if err != nil {
+			result.Status, result.Reason = FILE_STATUS_FAILED, err.Error()
+			reportFile(result)
+			e.Printfln("While reading %s: %v", filePath, err)
+			exit(1)
+		}
+
+		ids := f.Metadata.SourceIDs()
+		result.ChapterIDs = ids.Chapters
+		if ids.Manga == "" || len(ids.Chapters) == 0 {
+			pterm.Warning.Printfln("Skipped %s, it has no MangaDex IDs", filePath)
+			result.Status, result.Reason = FILE_STATUS_SKIPPED, "it has no MangaDex IDs"
+			reportFile(result)
+			continue
+		}
+
+		m, chapterRange, err := p.fetchMetadata(ids, f.Metadata.CI.Notes)
+		if errors.Is(err, mangadexapi.ErrConnection) {
+			result.Status, result.Reason = FILE_STATUS_FAILED, err.Error()
+			reportFile(result)
+			e.Printfln("While getting information of %s: %v", filePath, err)
+			exit(1)
+		}
+		if err != nil {
+			pterm.Warning.Printfln("Skipped %s: %v", filePath, err)
+			result.Status, result.Reason = FILE_STATUS_SKIPPED, err.Error()
+			reportFile(result)
+			continue
+		}
+
+		tags, isChanged, err := f.NewTags(m, chapterRange)
+		if err != nil {
+			result.Status, result.Reason = FILE_STATUS_FAILED, err.Error()
+			reportFile(result)
+			e.Printfln("While retagging %s: %v", filePath, err)
+			exit(1)
+		}
+		if !isChanged {
+			pterm.Info.Printfln("%s is up to date", filePath)
+			result.Status = FILE_STATUS_UNCHANGED
+			reportFile(result)
+			continue
+		}
+
+		if p.isDryRun {
+			current, _ := f.Tags()
+			diff := diffLines(strings.Split(current, "\n"), strings.Split(tags, "\n"))
+			result.Status, result.Diff = FILE_STATUS_CHANGED, diff
+			reportFile(result)
+			if !isMachineOutput() {
+				pterm.Info.Printfln("Changes of %s:", filePath)
+				printDiff(diff)
+			}
+			continue
+		}
+
+		if err := f.Retag(m, chapterRange); err != nil {
+			result.Status, result.Reason = FILE_STATUS_FAILED, err.Error()
+			reportFile(result)
+			e.Printfln("While retagging %s: %v", filePath, err)
+			exit(1)
+		}
+		pterm.Success.Printfln("Retagged %s", filePath)
+		result.Status = FILE_STATUS_SAVED
+		reportFile(result)
+	}
+}
+
+// findRetagFiles returns files of paths, directories are replaced with
+// files of retagExtensions inside them. Directories without such files, e.g.
+// directory outputs, are reported with a warning.
+func findRetagFiles(paths []string) []string {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			e.Printfln("While reading %s: %v", path, err)
+			exit(1)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		count := len(files)
+		err = filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))
+			if !d.IsDir() && slices.Contains(retagExtensions, ext) {
+				files = append(files, filePath)
+			}
+			return nil
+		})
+		if err != nil {
+			e.Printfln("While reading %s: %v", path, err)
+			exit(1)
+		}
+		if len(files) == count {
+			pterm.Warning.Printfln("Skipped %s, it has no %s files, directory outputs store no metadata "+
+				"and can't be retagged", path, strings.Join(retagExtensions, ", "))
+			reportFile(fileResult{Path: path, Status: FILE_STATUS_SKIPPED,
+				Reason: "directory outputs can't be retagged"})
+		}
+	}
+	return files
+}
+
+// fetchMetadata returns metadata built from current information of the
+// manga and chapters, like at download time. Metadata of merged files is
+// taken from the first chapter. Other lines of notes are kept.
+func (p retagParam) fetchMetadata(ids metadata.SourceIDs, notes string) (metadata.Metadata, string, error) {
+	spinner, _ := pterm.DefaultSpinner.Start("Fetching info...")
+
+	manga, ok := p.mangas[ids.Manga]
+	if !ok {
+		resp, err := client.GetMangaInfo(ids.Manga)
+		if err != nil {
+			spinner.Fail("Failed to fetch manga info")
+			return metadata.Metadata{}, "", err
+		}
+		manga = resp.MangaInfo()
+		p.mangas[ids.Manga] = manga
+	}
+
+	chapters, err := client.GetChaptersByIds(ids.Chapters)
+	for i := 0; err == nil && i < len(ids.Chapters); i++ {
+		if i >= len(chapters) || chapters[i].ID != ids.Chapters[i] {
+			err = fmt.Errorf("chapter %s is not found on MangaDex", ids.Chapters[i])
+		}
+	}
+	if err != nil {
+		spinner.Fail("Failed to fetch chapters info")
+		return metadata.Metadata{}, "", err
+	}
+	spinner.Success("Fetched info")
+
+	m := metadata.NewMetadata(app.USER_AGENT, manga, chapters[0])
+	m.CBI.LastModified = time.Now().UTC().String()
+	m.CI.Notes = notes
+	m.SetSourceIDs(ids)
+
+	chapterRange := ""
+	if len(chapters) > 1 {
+		chapterRange = chapters[0].Number() + "-" + chapters[len(chapters)-1].Number()
+	}
+	return m, chapterRange, nil
+}
+
+// diffLines returns lines of a missing in b with a "-" prefix and lines of b
+// missing in a with a "+" prefix, in the order of the longest common
+// subsequence.
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
+
+func printDiff(diff []string) {
+	for _, line := range diff {
+		if strings.HasPrefix(line, "-") {
+			pterm.FgRed.Println(line)
+		} else {
+			pterm.FgGreen.Println(line)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package mdx
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want []string
+	}{
+		{
+			name: "Same lines",
+			a:    []string{"<Series>A</Series>", "<Number>1</Number>"},
+			b:    []string{"<Series>A</Series>", "<Number>1</Number>"},
+			want: []string{},
+		},
+		{
+			name: "Changed line",
+			a:    []string{"<Title>T</Title>", "<Series>A</Series>", "<Number>1</Number>"},
+			b:    []string{"<Title>T</Title>", "<Series>B</Series>", "<Number>1</Number>"},
+			want: []string{"- <Series>A</Series>", "+ <Series>B</Series>"},
+		},
+		{
+			name: "Added and removed lines",
+			a:    []string{"<Series>A</Series>", "<Genre>Drama</Genre>"},
+			b:    []string{"<Series>A</Series>", "<Notes>ids</Notes>"},
+			want: []string{"- <Genre>Drama</Genre>", "+ <Notes>ids</Notes>"},
+		},
+	}
+
+	for _, tc := range tests {
+		got := diffLines(tc.a, tc.b)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Test Case: %s. Expected %q, but got %q", tc.name, tc.want, got)
+		}
+	}
+}
@@ -60,6 +60,19 @@ func CheckUpdate() {
 		isShouldUpdate = true
 	}
 
+	if isMachineOutput() {
+		info := updateResult{
+			Version:       app.VERSION,
+			LatestVersion: result.TagName,
+			IsOutdated:    isShouldUpdate,
+		}
+		if isShouldUpdate {
+			info.UpdateCommand = getUpdateCommand()
+		}
+		printResult(info)
+		return
+	}
+
 	tableData := pterm.TableData{
 		{field.Sprint("Your version"), dp.Sprint(app.VERSION)},
 		{field.Sprint("Latest version"), dp.Sprint(result.TagName)},
@@ -75,6 +88,15 @@ func CheckUpdate() {
 	}
 }
 
+// updateResult is the result of update in json and ndjson formats.
+type updateResult struct {
+	Version       string `json:"version"`
+	LatestVersion string `json:"latestVersion"`
+	IsOutdated    bool   `json:"isOutdated"`
+	// UpdateCommand is empty on systems without an installation script.
+	UpdateCommand string `json:"updateCommand,omitempty"`
+}
+
 func getUpdateCommand() string {
 	switch runtime.GOOS {
 	case "windows":
@@ -1,9 +1,11 @@
 package mangadexapi
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +22,8 @@ const (
 	random_manga_path          = "/manga/random"
 	specific_manga_path        = "/manga/{id}"
 	manga_feed_path            = "/manga/{id}/feed"
+	manga_aggregate_path       = "/manga/{id}/aggregate"
+	chapter_path               = "/chapter"
 	chapter_info_path          = "/chapter/{id}"
 	chapter_images_path        = "/at-home/server/{id}"
 	download_high_quility_path = "/data/{chapterHash}/{imageFilename}"
@@ -252,7 +256,60 @@ func (a Clientapi) GetChapterInfo(chapterId string) (ResponseChapter, error) {
 	return chapterInfo, nil
 }
 
+// GetChaptersByIds retrieves chapters with the given IDs in the order of
+// ids, chapters hosted on external sites and chapters published in the
+// future included. Chapters are requested by 100 IDs at once, IDs not found,
+// e.g. of deleted chapters, are left out.
+func (a Clientapi) GetChaptersByIds(ids []string) ([]Chapter, error) {
+	if len(ids) == 0 {
+		return []Chapter{}, ErrBadInput
+	}
+
+	limit := 100
+	found := map[string]Chapter{}
+	for start := 0; start < len(ids); start += limit {
+		query := url.Values{}
+		for _, id := range ids[start:min(start+limit, len(ids))] {
+			query.Add("ids[]", id)
+		}
+		query.Set("limit", strconv.Itoa(limit))
+		query.Add("includes[]", "scanlation_group")
+		query.Add("includes[]", "user")
+		query.Set("includeEmptyPages", "1")
+		query.Set("includeFuturePublishAt", "1")
+		query.Set("includeExternalUrl", "1")
+
+		list := ResponseChapterList{}
+		respErr := ErrorResponse{}
+
+		resp, err := a.c.R().
+			SetError(&respErr).
+			SetResult(&list).
+			SetQueryParamsFromValues(query).
+			Get(chapter_path)
+		if err != nil {
+			return []Chapter{}, ErrConnection
+		}
+		if resp.IsError() {
+			return []Chapter{}, &respErr
+		}
+
+		for _, chapter := range list.Data {
+			found[chapter.ID] = chapter
+		}
+	}
+
+	chapters := []Chapter{}
+	for _, id := range ids {
+		if chapter, ok := found[id]; ok {
+			chapters = append(chapters, chapter)
+		}
+	}
+	return chapters, nil
+}
+
 // GetChaptersList retrieves a list of chapters for a given manga, limit, offset, and language.
+// Chapters hosted on external sites and chapters published in the future are included.
 // Parameters:
 // - limit: the maximum number of chapters to retrieve
 // - offset: the number of chapters to skip before retrieving
@@ -273,7 +330,7 @@ func (a Clientapi) GetChaptersList(limit, offset int, mangaId, language string)
 	query := pterm.Sprintf(
 		"limit=%d&offset=%d&translatedLanguage[]=%s"+
 			"&includes[]=scanlation_group&order[volume]=asc&order[chapter]=asc"+
-			"&includeEmptyPages=0",
+			"&includeEmptyPages=1&includeFuturePublishAt=1&includeExternalUrl=1",
 		limit, offset, language)
 
 	resp, err := a.c.R().
@@ -334,6 +391,13 @@ func (a Clientapi) GetChapterImageList(chapterId string) (ResponseChapterImages,
 // - bool: is jpeg?
 // - error: an error if the download fails.
 func (a Clientapi) DownloadImage(baseUrl, chapterHash, imageFilename string,
+	isJpg bool) ([]byte, bool, error) {
+	return a.DownloadImageContext(context.Background(), baseUrl, chapterHash, imageFilename, isJpg)
+}
+
+// DownloadImageContext is DownloadImage, the request is canceled when ctx
+// is done.
+func (a Clientapi) DownloadImageContext(ctx context.Context, baseUrl, chapterHash, imageFilename string,
 	isJpg bool) ([]byte, bool, error) {
 	if baseUrl == "" || chapterHash == "" || imageFilename == "" {
 		return nil, false, ErrBadInput
@@ -348,6 +412,7 @@ func (a Clientapi) DownloadImage(baseUrl, chapterHash, imageFilename string,
 
 	resp, err := a.c.SetBaseURL(baseUrl).
 		R().
+		SetContext(ctx).
 		SetError(respErr).
 		SetPathParams(map[string]string{
 			"chapterHash":   chapterHash,
@@ -567,6 +632,9 @@ func (a Clientapi) GetLastChapterFullInfo(mangaId, language,
 	return fullInfo, nil
 }
 
+// GetAllChaptersInfo retrieves chapters of a manga in the language, chapters
+// hosted on external sites and chapters published in the future included.
+// Of chapters with the same number the first readable one is kept.
 func (a Clientapi) GetAllChaptersInfo(mangaId, language, translationGroup string) ([]Chapter, error) {
 	if mangaId == "" || language == "" {
 		return []Chapter{}, ErrBadInput
@@ -582,8 +650,8 @@ func (a Clientapi) GetAllChaptersInfo(mangaId, language, translationGroup string
 		query := pterm.Sprintf(
 			"limit=%d&offset=%d&translatedLanguage[]=%s"+
 				"&includes[]=scanlation_group&includes[]=user"+
-				"&order[volume]=asc&order[chapter]=asc&"+
-				"&includeEmptyPages=0",
+				"&order[volume]=asc&order[chapter]=asc"+
+				"&includeEmptyPages=1&includeFuturePublishAt=1&includeExternalUrl=1",
 			limit, offset, language)
 
 		list := ResponseChapterList{}
@@ -614,6 +682,81 @@ func (a Clientapi) GetAllChaptersInfo(mangaId, language, translationGroup string
 	return chapters, nil
 }
 
+// GetChapterFeed retrieves all chapters of a manga in the language,
+// including chapters hosted on external sites and chapters published in the
+// future. Chapters with the same number are all kept.
+// Parameters:
+// - mangaId: the ID of the manga.
+// - language: the language of the chapters.
+// Returns:
+// - []Chapter: chapters ordered by volume and chapter number.
+// - error: an error if there was a problem retrieving the information.
+func (a Clientapi) GetChapterFeed(mangaId, language string) ([]Chapter, error) {
+	if mangaId == "" || language == "" {
+		return []Chapter{}, ErrBadInput
+	}
+
+	limit := 500
+	chapters := []Chapter{}
+
+	for offset := 0; ; offset += limit {
+		query := pterm.Sprintf(
+			"limit=%d&offset=%d&translatedLanguage[]=%s"+
+				"&includes[]=scanlation_group&includes[]=user"+
+				"&order[volume]=asc&order[chapter]=asc"+
+				"&includeEmptyPages=1&includeFuturePublishAt=1&includeExternalUrl=1",
+			limit, offset, language)
+
+		list := ResponseChapterList{}
+		respErr := ErrorResponse{}
+
+		resp, err := a.c.R().
+			SetError(&respErr).
+			SetResult(&list).
+			SetPathParam("id", mangaId).
+			SetQueryString(query).
+			Get(manga_feed_path)
+		if err != nil {
+			return []Chapter{}, ErrConnection
+		}
+		if resp.IsError() {
+			return []Chapter{}, &respErr
+		}
+
+		chapters = append(chapters, list.Data...)
+		if len(list.Data) == 0 || offset+limit >= list.Total {
+			break
+		}
+	}
+
+	return chapters, nil
+}
+
+// GetChapterNumbers retrieves chapter numbers of a manga in all languages
+// with IDs of their chapters. It is one request, unlike the chapter feed.
+func (a Clientapi) GetChapterNumbers(mangaId string) ([]AggregateChapter, error) {
+	if mangaId == "" {
+		return []AggregateChapter{}, ErrBadInput
+	}
+
+	aggregate := ResponseAggregate{}
+	respErr := ErrorResponse{}
+
+	resp, err := a.c.R().
+		SetError(&respErr).
+		SetResult(&aggregate).
+		SetPathParam("id", mangaId).
+		Get(manga_aggregate_path)
+	if err != nil {
+		return []AggregateChapter{}, ErrConnection
+	}
+	if resp.IsError() {
+		return []AggregateChapter{}, &respErr
+	}
+
+	return aggregate.Chapters(), nil
+}
+
 // GetAllFullChaptersInfo retrieves the full information of all chapters of a manga.
 // Duplicate chapters are not added in the result.
 // Parameters:
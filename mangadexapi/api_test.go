@@ -1,7 +1,14 @@
 package mangadexapi
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
 	"testing"
+
+	"github.com/go-resty/resty/v2"
 )
 
 func TestGetMangaDexPaths(t *testing.T) {
@@ -107,3 +114,47 @@ func TestGetChapterIdFromUrl(t *testing.T) {
 		})
 	}
 }
+
+func TestGetChaptersByIds(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		query := r.URL.Query()
+		if r.URL.Path != chapter_path || query.Get("includeExternalUrl") != "1" {
+			t.Errorf("Expected chapters requested by IDs, but got %s", r.URL)
+		}
+		list := ResponseChapterList{}
+		// chapters are returned out of order
+		for _, id := range slices.Backward(query["ids[]"]) {
+			if id != "deleted" {
+				list.Data = append(list.Data, Chapter{ID: id})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+	defer server.Close()
+	a := Clientapi{c: resty.New().SetBaseURL(server.URL)}
+
+	ids := []string{}
+	for i := range 150 {
+		ids = append(ids, strconv.Itoa(i))
+	}
+	chapters, err := a.GetChaptersByIds(ids)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got := []string{}
+	for _, chapter := range chapters {
+		got = append(got, chapter.ID)
+	}
+	if !slices.Equal(got, ids) || requests != 2 {
+		t.Errorf("Expected 150 chapters in order by 2 requests, but got %d chapters by %d requests",
+			len(got), requests)
+	}
+
+	chapters, err = a.GetChaptersByIds([]string{"1", "deleted"})
+	if err != nil || len(chapters) != 1 || chapters[0].ID != "1" {
+		t.Errorf("Expected the deleted chapter left out, but got %v (%v)", chapters, err)
+	}
+}
@@ -1,6 +1,7 @@
 package mangadexapi
 
 import (
+	"encoding/json"
 	"strconv"
 	"strings"
 	"time"
@@ -43,6 +44,27 @@ func (c Chapter) PagesCount() int {
 	return c.Attributes.Pages
 }
 
+// ExternalUrl returns the official source of chapters hosted on other
+// sites, e.g. MangaPlus.
+func (c Chapter) ExternalUrl() string {
+	return c.Attributes.ExternalUrl
+}
+
+// IsExternal reports whether the chapter is hosted on another site, such
+// chapters have no pages on MangaDex.
+func (c Chapter) IsExternal() bool {
+	return c.Attributes.ExternalUrl != ""
+}
+
+// IsReadable reports whether pages of the chapter can be downloaded at now.
+// External chapters and chapters published or readable after now are not
+// readable.
+func (c Chapter) IsReadable(now time.Time) bool {
+	return !c.IsExternal() &&
+		!c.Attributes.PublishAt.After(now) &&
+		!c.Attributes.ReadableAt.After(now)
+}
+
 func (c Chapter) UploadedBy() string {
 	for _, rel := range c.Relationships {
 		if rel.Type == "user" {
@@ -69,6 +91,10 @@ func (c Chapter) GetTranslator() string {
 	return ""
 }
 
+func (c Chapter) Translator() string {
+	return c.GetTranslator()
+}
+
 func (c Chapter) GetMangaId() string {
 	for _, rel := range c.Relationships {
 		if rel.Type == "manga" {
@@ -97,11 +123,68 @@ func (r ResponseChapter) GetChapterInfo() Chapter {
 	return r.Data
 }
 
+// AggregateChapter is a chapter number of a manga in any language, ID is
+// one of the chapters with the number and Others are the rest of them.
+type AggregateChapter struct {
+	Chapter string   `json:"chapter"`
+	ID      string   `json:"id"`
+	Others  []string `json:"others"`
+	Count   int      `json:"count"`
+}
+
+// IDs returns IDs of all chapters with the number.
+func (c AggregateChapter) IDs() []string {
+	return append([]string{c.ID}, c.Others...)
+}
+
+type aggregateVolume struct {
+	Volume   string          `json:"volume"`
+	Chapters json.RawMessage `json:"chapters"`
+}
+
+type ResponseAggregate struct {
+	Result  string          `json:"result"`
+	Volumes json.RawMessage `json:"volumes"`
+}
+
+// Chapters returns chapter numbers of all volumes.
+func (r ResponseAggregate) Chapters() []AggregateChapter {
+	chapters := []AggregateChapter{}
+	for _, volume := range decodeAggregateList[aggregateVolume](r.Volumes) {
+		chapters = append(chapters, decodeAggregateList[AggregateChapter](volume.Chapters)...)
+	}
+	return chapters
+}
+
+// decodeAggregateList decodes values of an object or of an array, MangaDex
+// sends objects with keys 0, 1, ... and empty objects as arrays.
+func decodeAggregateList[T any](raw json.RawMessage) []T {
+	values := []T{}
+	if err := json.Unmarshal(raw, &values); err == nil {
+		return values
+	}
+	byKey := map[string]T{}
+	if err := json.Unmarshal(raw, &byKey); err != nil {
+		return []T{}
+	}
+	for _, value := range byKey {
+		values = append(values, value)
+	}
+	return values
+}
+
+// GetAllChapters returns chapters translated by the group, of chapters with
+// the same number the first one is kept unless a later one is readable.
 func (l ResponseChapterList) GetAllChapters(transgp string) []Chapter {
+	now := time.Now()
 	found := []Chapter{}
 	for _, c := range l.Data {
 		if len(found) != 0 {
-			if found[len(found)-1].Number() == c.Number() {
+			last := &found[len(found)-1]
+			if last.Number() == c.Number() {
+				if !last.IsReadable(now) && c.IsReadable(now) && c.isTranslatedByGroup(transgp) {
+					*last = c
+				}
 				continue
 			}
 		}
@@ -0,0 +1,95 @@
+package mangadexapi
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestChapterIsReadable(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	past := now.AddDate(0, 0, -1)
+	future := now.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name string
+		attr ChapterAttr
+		want bool
+	}{
+		{"Published", ChapterAttr{PublishAt: past, ReadableAt: past}, true},
+		{"No dates", ChapterAttr{}, true},
+		{"External", ChapterAttr{ExternalUrl: "https://mangaplus.shueisha.co.jp", PublishAt: past}, false},
+		{"Scheduled", ChapterAttr{PublishAt: future, ReadableAt: future}, false},
+		{"Readable later", ChapterAttr{PublishAt: past, ReadableAt: future}, false},
+	}
+
+	for _, tt := range tests {
+		c := Chapter{Attributes: tt.attr}
+		if got := c.IsReadable(now); got != tt.want {
+			t.Errorf("Test Case: %s. Expected %v, but got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGetAllChapters(t *testing.T) {
+	now := time.Now()
+	chapter := func(id, number, group string, attr ChapterAttr) Chapter {
+		attr.Chapter = number
+		return Chapter{ID: id, Attributes: attr,
+			Relationships: []Relationship{{Type: "scanlation_group", Attributes: RelAttribute{Name: group}}}}
+	}
+	external := ChapterAttr{ExternalUrl: "https://mangaplus.shueisha.co.jp"}
+	future := ChapterAttr{PublishAt: now.AddDate(0, 0, 1)}
+	list := ResponseChapterList{Data: []Chapter{
+		chapter("1a", "1", "A", ChapterAttr{}),
+		chapter("1b", "1", "B", ChapterAttr{}),
+		chapter("2a", "2", "A", external),
+		chapter("2b", "2", "B", ChapterAttr{}),
+		chapter("3a", "3", "A", future),
+		chapter("4a", "4", "A", external),
+	}}
+
+	tests := []struct {
+		name  string
+		group string
+		want  []string
+	}{
+		{"All groups", "", []string{"1a", "2b", "3a", "4a"}},
+		{"Group", "A", []string{"1a", "2a", "3a", "4a"}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, c := range list.GetAllChapters(tt.group) {
+			got = append(got, c.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Test Case: %s. Expected %v, but got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAggregateChapters(t *testing.T) {
+	tests := []struct {
+		name    string
+		volumes string
+		want    []string
+	}{
+		{"Objects", `{"1": {"volume": "1", "chapters": {"1": {"chapter": "1", "id": "a", "others": ["b"]},
+			"2": {"chapter": "2", "id": "c", "others": []}}}}`, []string{"a", "b", "c"}},
+		{"Arrays", `[{"volume": "none", "chapters": [{"chapter": "none", "id": "a", "others": []}]}]`,
+			[]string{"a"}},
+		{"Empty", `[]`, []string{}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, c := range (ResponseAggregate{Volumes: []byte(tt.volumes)}).Chapters() {
+			got = append(got, c.IDs()...)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Test Case: %s. Expected %v, but got %v", tt.name, tt.want, got)
+		}
+	}
+}
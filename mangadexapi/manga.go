@@ -85,6 +85,20 @@ func (mi MangaInfo) Title(language string) string {
 	return ""
 }
 
+// LocalizedTitle returns the title in language from main or alternative
+// titles. Unlike Title, it doesn't fall back to other languages.
+func (mi MangaInfo) LocalizedTitle(language string) string {
+	if title := mi.Attributes.Title[language]; title != "" {
+		return title
+	}
+	for _, m := range mi.Attributes.AltTitles {
+		if title := m[language]; title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
 func (mi MangaInfo) AltTitles() string {
 	altTitles := []string{}
 	for _, m := range mi.Attributes.AltTitles {
@@ -157,6 +171,32 @@ func (mi MangaInfo) OriginalLanguage() string {
 	return mi.Attributes.OriginalLanguage
 }
 
+func (mi MangaInfo) ContentRating() string {
+	return mi.Attributes.ContentRating
+}
+
+func (mi MangaInfo) LastVolume() string {
+	if mi.Attributes.LastVolume == nil {
+		return ""
+	}
+	return *mi.Attributes.LastVolume
+}
+
+func (mi MangaInfo) LastChapter() string {
+	if mi.Attributes.LastChapter == nil {
+		return ""
+	}
+	return *mi.Attributes.LastChapter
+}
+
+// Link returns the MangaDex page of the manga.
+func (mi MangaInfo) Link() string {
+	if mi.ID == "" {
+		return ""
+	}
+	return "https://mangadex.org/title/" + mi.ID
+}
+
 func (mi MangaInfo) TranslatedLanguages() []string {
 	return mi.Attributes.AvailableTranslatedLanguages
 }
@@ -185,6 +225,24 @@ func (mi MangaInfo) TagsArr() []string {
 	return tags
 }
 
+// IsLongStrip reports whether the manga is a webtoon drawn as one vertical
+// strip.
+func (mi MangaInfo) IsLongStrip() bool {
+	return slices.Contains(mi.TagsArr(), "Long Strip")
+}
+
+// TagsGroupArr returns names of tags from groups, e.g. "genre", "theme",
+// "format" or "content".
+func (mi MangaInfo) TagsGroupArr(groups ...string) []string {
+	tags := []string{}
+	for _, tagEntity := range mi.Attributes.Tags {
+		if tagEntity.Type == "tag" && slices.Contains(groups, tagEntity.Attributes.Group) {
+			tags = append(tags, tagEntity.Attributes.Name["en"])
+		}
+	}
+	return tags
+}
+
 func (mi MangaInfo) Links() string {
 	joinedLinks := strings.Join(mi.LinksArr(), "\n")
 	return joinedLinks